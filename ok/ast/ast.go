@@ -2,6 +2,7 @@ package ast
 
 import (
 	"bytes"
+	"math/big"
 	"strings"
 
 	"github.com/jesseduffield/OK/ok/token"
@@ -10,6 +11,8 @@ import (
 type Node interface {
 	TokenLiteral() string
 	GetToken() token.Token
+	// the region of source code the node was parsed from
+	Span() token.Span
 	String() string
 }
 
@@ -20,6 +23,9 @@ type Expression interface {
 
 type Program struct {
 	Statements []Statement
+	// block comments from inside expressions, which have no place in the tree.
+	// Only the formatter cares about these.
+	Comments []token.Token
 }
 
 type Statement interface {
@@ -43,6 +49,15 @@ func (self *Program) GetToken() token.Token {
 	}
 }
 
+func (self *Program) Span() token.Span {
+	if len(self.Statements) == 0 {
+		return token.Span{}
+	}
+
+	first := self.Statements[0].Span()
+	return first.To(self.Statements[len(self.Statements)-1].Span())
+}
+
 func (self *Program) String() string {
 	var out bytes.Buffer
 
@@ -61,18 +76,33 @@ type Identifier struct {
 func (self *Identifier) expressionNode()       {}
 func (self *Identifier) GetToken() token.Token { return self.Token }
 func (self *Identifier) TokenLiteral() string  { return self.Token.Literal }
+func (self *Identifier) Span() token.Span      { return self.Token.Span() }
 func (self *Identifier) String() string        { return self.Value }
 
 type IntegerLiteral struct {
 	Token token.Token // the token.IDENT token
 	Value int64
+	// only set if the literal is too large to fit in Value
+	BigValue *big.Int
 }
 
 func (self *IntegerLiteral) expressionNode()       {}
 func (self *IntegerLiteral) GetToken() token.Token { return self.Token }
 func (self *IntegerLiteral) TokenLiteral() string  { return self.Token.Literal }
+func (self *IntegerLiteral) Span() token.Span      { return self.Token.Span() }
 func (self *IntegerLiteral) String() string        { return self.Token.Literal }
 
+type FloatLiteral struct {
+	Token token.Token // the token.FLOAT token
+	Value float64
+}
+
+func (self *FloatLiteral) expressionNode()       {}
+func (self *FloatLiteral) GetToken() token.Token { return self.Token }
+func (self *FloatLiteral) TokenLiteral() string  { return self.Token.Literal }
+func (self *FloatLiteral) Span() token.Span      { return self.Token.Span() }
+func (self *FloatLiteral) String() string        { return self.Token.Literal }
+
 type NullLiteral struct {
 	Token token.Token // the token.NULL token
 }
@@ -80,6 +110,7 @@ type NullLiteral struct {
 func (self *NullLiteral) expressionNode()       {}
 func (self *NullLiteral) GetToken() token.Token { return self.Token }
 func (self *NullLiteral) TokenLiteral() string  { return self.Token.Literal }
+func (self *NullLiteral) Span() token.Span      { return self.Token.Span() }
 func (self *NullLiteral) String() string        { return self.Token.Literal }
 
 type StringLiteral struct {
@@ -90,8 +121,41 @@ type StringLiteral struct {
 func (self *StringLiteral) expressionNode()       {}
 func (self *StringLiteral) GetToken() token.Token { return self.Token }
 func (self *StringLiteral) TokenLiteral() string  { return self.Token.Literal }
+func (self *StringLiteral) Span() token.Span      { return self.Token.Span() }
 func (self *StringLiteral) String() string        { return self.Token.Literal }
 
+// e.g. "hello ${name}!". There is always one more segment than there are
+// expressions, so the above example has segments "hello " and "!"
+type InterpolatedString struct {
+	Token       token.Token // the token.INTERP_START token
+	EndToken    token.Token // the token.INTERP_END token
+	Segments    []string
+	Expressions []Expression
+}
+
+func (self *InterpolatedString) expressionNode()       {}
+func (self *InterpolatedString) GetToken() token.Token { return self.Token }
+func (self *InterpolatedString) TokenLiteral() string  { return self.Token.Literal }
+func (self *InterpolatedString) Span() token.Span {
+	return self.Token.Span().To(self.EndToken.Span())
+}
+func (self *InterpolatedString) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("\"")
+	for i, segment := range self.Segments {
+		out.WriteString(segment)
+		if i < len(self.Expressions) {
+			out.WriteString("${")
+			out.WriteString(self.Expressions[i].String())
+			out.WriteString("}")
+		}
+	}
+	out.WriteString("\"")
+
+	return out.String()
+}
+
 type PrefixExpression struct {
 	Token    token.Token // The prefix token, e.g. !
 	Operator string
@@ -101,6 +165,9 @@ type PrefixExpression struct {
 func (self *PrefixExpression) expressionNode()       {}
 func (self *PrefixExpression) GetToken() token.Token { return self.Token }
 func (self *PrefixExpression) TokenLiteral() string  { return self.Token.Literal }
+func (self *PrefixExpression) Span() token.Span {
+	return spanTo(self.Token.Span(), self.Right)
+}
 func (self *PrefixExpression) String() string {
 	var out bytes.Buffer
 
@@ -122,6 +189,12 @@ type InfixExpression struct {
 func (self *InfixExpression) expressionNode()       {}
 func (self *InfixExpression) GetToken() token.Token { return self.Token }
 func (self *InfixExpression) TokenLiteral() string  { return self.Token.Literal }
+func (self *InfixExpression) Span() token.Span {
+	if isNil(self.Left) {
+		return spanTo(self.Token.Span(), self.Right)
+	}
+	return spanTo(self.Left.Span(), self.Right)
+}
 func (self *InfixExpression) String() string {
 	var out bytes.Buffer
 
@@ -142,6 +215,7 @@ type Boolean struct {
 func (self *Boolean) expressionNode()       {}
 func (self *Boolean) GetToken() token.Token { return self.Token }
 func (self *Boolean) TokenLiteral() string  { return self.Token.Literal }
+func (self *Boolean) Span() token.Span      { return self.Token.Span() }
 func (self *Boolean) String() string        { return self.Token.Literal }
 
 type IfExpression struct {
@@ -154,6 +228,12 @@ type IfExpression struct {
 func (self *IfExpression) expressionNode()       {}
 func (self *IfExpression) GetToken() token.Token { return self.Token }
 func (self *IfExpression) TokenLiteral() string  { return self.Token.Literal }
+func (self *IfExpression) Span() token.Span {
+	if self.Alternative != nil {
+		return spanTo(self.Token.Span(), self.Alternative)
+	}
+	return spanTo(self.Token.Span(), self.Consequence)
+}
 func (self *IfExpression) String() string {
 	var out bytes.Buffer
 
@@ -170,21 +250,49 @@ func (self *IfExpression) String() string {
 	return out.String()
 }
 
+// SwitchCase matches if any of its values do. A value is compared with the
+// subject for equality, unless it's a pattern:
+//   - an array or hash literal matches by shape, binding any identifiers
+//     inside it, e.g. 'case [x, ""]:'
+//   - a RangeExpression matches integers within the range
+//   - the name of a nac matches instances of that nac, e.g. 'case person:'
 type SwitchCase struct {
-	Value Expression
-	Block *BlockStatement
+	Values []Expression
+	Block  *BlockStatement
+}
+
+// RangeExpression is an inclusive range of integers, e.g. '1..5'. For now
+// these can only appear as switch cases.
+type RangeExpression struct {
+	Token token.Token // the '..' token
+	Low   Expression
+	High  Expression
+}
+
+func (self *RangeExpression) expressionNode()       {}
+func (self *RangeExpression) GetToken() token.Token { return self.Token }
+func (self *RangeExpression) TokenLiteral() string  { return self.Token.Literal }
+func (self *RangeExpression) Span() token.Span {
+	return spanTo(spanFrom(self.Low, self.Token.Span()), self.High)
+}
+func (self *RangeExpression) String() string {
+	return self.Low.String() + ".." + self.High.String()
 }
 
 type SwitchExpression struct {
-	Token   token.Token // The 'switch' token
-	Subject Expression
-	Cases   []SwitchCase
-	Default *BlockStatement
+	Token    token.Token // The 'switch' token
+	EndToken token.Token // The closing '}' token
+	Subject  Expression
+	Cases    []SwitchCase
+	Default  *BlockStatement
 }
 
 func (self *SwitchExpression) expressionNode()       {}
 func (self *SwitchExpression) GetToken() token.Token { return self.Token }
 func (self *SwitchExpression) TokenLiteral() string  { return self.Token.Literal }
+func (self *SwitchExpression) Span() token.Span {
+	return self.Token.Span().To(self.EndToken.Span())
+}
 func (self *SwitchExpression) String() string {
 	var out bytes.Buffer
 
@@ -193,8 +301,12 @@ func (self *SwitchExpression) String() string {
 	out.WriteString(self.Subject.String())
 	out.WriteString(" {")
 	for _, e := range self.Cases {
+		values := []string{}
+		for _, value := range e.Values {
+			values = append(values, value.String())
+		}
 		out.WriteString(" case ")
-		out.WriteString(e.Value.String())
+		out.WriteString(strings.Join(values, ", "))
 		out.WriteString(": { ")
 		out.WriteString(e.Block.String())
 		out.WriteString(" }")
@@ -212,12 +324,20 @@ func (self *SwitchExpression) String() string {
 
 type BlockStatement struct {
 	Token      token.Token // the { token
+	EndToken   token.Token // the } token. Not set for switch case blocks
 	Statements []Statement
 }
 
 func (self *BlockStatement) statementNode()        {}
 func (self *BlockStatement) GetToken() token.Token { return self.Token }
 func (self *BlockStatement) TokenLiteral() string  { return self.Token.Literal }
+func (self *BlockStatement) Span() token.Span {
+	// switch case blocks don't have a closing brace of their own
+	if self.EndToken.Type == "" && len(self.Statements) > 0 {
+		return spanTo(self.Token.Span(), self.Statements[len(self.Statements)-1])
+	}
+	return self.Token.Span().To(self.EndToken.Span())
+}
 func (self *BlockStatement) String() string {
 	var out bytes.Buffer
 
@@ -230,13 +350,19 @@ func (self *BlockStatement) String() string {
 
 type FunctionLiteral struct {
 	Token      token.Token // The 'fn' token
-	Parameters []*Identifier
+	Parameters []*Parameter
 	Body       *BlockStatement
+	// the name the function was bound to with let, if any. Used for error
+	// messages
+	Name string
 }
 
 func (self *FunctionLiteral) expressionNode()       {}
 func (self *FunctionLiteral) GetToken() token.Token { return self.Token }
 func (self *FunctionLiteral) TokenLiteral() string  { return self.Token.Literal }
+func (self *FunctionLiteral) Span() token.Span {
+	return spanTo(self.Token.Span(), self.Body)
+}
 func (self *FunctionLiteral) String() string {
 	var out bytes.Buffer
 
@@ -255,8 +381,49 @@ func (self *FunctionLiteral) String() string {
 	return out.String()
 }
 
+// Parameter is one of a function's parameters. A parameter with a default is
+// optional, and a rest parameter (which must come last) collects any remaining
+// arguments into an array.
+type Parameter struct {
+	Token   token.Token // The '...' token for a rest parameter, otherwise the name
+	Name    *Identifier
+	Default Expression // nil if the parameter is required
+	Rest    bool
+}
+
+func (self *Parameter) GetToken() token.Token { return self.Token }
+func (self *Parameter) TokenLiteral() string  { return self.Token.Literal }
+func (self *Parameter) Span() token.Span {
+	return spanTo(spanTo(self.Token.Span(), self.Name), self.Default)
+}
+func (self *Parameter) String() string {
+	if self.Rest {
+		return "..." + self.Name.String()
+	}
+	if self.Default != nil {
+		return self.Name.String() + " = " + self.Default.String()
+	}
+	return self.Name.String()
+}
+
+// Arity returns the fewest and most arguments the parameters accept. max is -1
+// if there's a rest parameter.
+func Arity(params []*Parameter) (min int, max int) {
+	for _, param := range params {
+		if param.Rest {
+			return min, -1
+		}
+		if param.Default == nil {
+			min++
+		}
+		max++
+	}
+	return min, max
+}
+
 type CallExpression struct {
 	Token     token.Token // The '(' token
+	EndToken  token.Token // The ')' token
 	Function  Expression  // Identifier or FunctionLiteral
 	Arguments []Expression
 }
@@ -264,6 +431,9 @@ type CallExpression struct {
 func (self *CallExpression) expressionNode()       {}
 func (self *CallExpression) GetToken() token.Token { return self.Token }
 func (self *CallExpression) TokenLiteral() string  { return self.Token.Literal }
+func (self *CallExpression) Span() token.Span {
+	return spanFrom(self.Function, self.Token.Span()).To(self.EndToken.Span())
+}
 func (self *CallExpression) String() string {
 	var out bytes.Buffer
 
@@ -282,12 +452,16 @@ func (self *CallExpression) String() string {
 
 type ArrayLiteral struct {
 	Token    token.Token // the '[' token
+	EndToken token.Token // the ']' token
 	Elements []Expression
 }
 
 func (self *ArrayLiteral) expressionNode()       {}
 func (self *ArrayLiteral) GetToken() token.Token { return self.Token }
 func (self *ArrayLiteral) TokenLiteral() string  { return self.Token.Literal }
+func (self *ArrayLiteral) Span() token.Span {
+	return self.Token.Span().To(self.EndToken.Span())
+}
 func (self *ArrayLiteral) String() string {
 	var out bytes.Buffer
 
@@ -304,14 +478,18 @@ func (self *ArrayLiteral) String() string {
 }
 
 type IndexExpression struct {
-	Token token.Token // The [ token
-	Left  Expression
-	Index Expression
+	Token    token.Token // The [ token
+	EndToken token.Token // The ] token
+	Left     Expression
+	Index    Expression
 }
 
 func (self *IndexExpression) expressionNode()       {}
 func (self *IndexExpression) GetToken() token.Token { return self.Token }
 func (self *IndexExpression) TokenLiteral() string  { return self.Token.Literal }
+func (self *IndexExpression) Span() token.Span {
+	return spanFrom(self.Left, self.Token.Span()).To(self.EndToken.Span())
+}
 func (self *IndexExpression) String() string {
 	var out bytes.Buffer
 
@@ -324,20 +502,29 @@ func (self *IndexExpression) String() string {
 	return out.String()
 }
 
+type HashPair struct {
+	Key   Expression
+	Value Expression
+}
+
 type HashLiteral struct {
-	Token token.Token // the '{' token
-	Pairs map[Expression]Expression
+	Token    token.Token // the '{' token
+	EndToken token.Token // the '}' token
+	Pairs    []HashPair  // in source order
 }
 
 func (self *HashLiteral) expressionNode()       {}
 func (self *HashLiteral) GetToken() token.Token { return self.Token }
 func (self *HashLiteral) TokenLiteral() string  { return self.Token.Literal }
+func (self *HashLiteral) Span() token.Span {
+	return self.Token.Span().To(self.EndToken.Span())
+}
 func (self *HashLiteral) String() string {
 	var out bytes.Buffer
 
 	pairs := []string{}
-	for key, value := range self.Pairs {
-		pairs = append(pairs, key.String()+":"+value.String())
+	for _, pair := range self.Pairs {
+		pairs = append(pairs, pair.Key.String()+":"+pair.Value.String())
 	}
 
 	out.WriteString("{")
@@ -349,18 +536,35 @@ func (self *HashLiteral) String() string {
 
 type LetStatement struct {
 	Token token.Token // the token.LET token
-	Name  *Identifier
-	Value Expression
+	Name  *Identifier // nil if the let destructures its value
+	// set instead of Name when destructuring, e.g. 'let [val, err] = ...'
+	Pattern Pattern
+	Value   Expression
+	Doc     *DocComment // nil unless the statement is preceded by a doc comment
+}
+
+// Target returns the name or pattern that the value is bound to
+func (self *LetStatement) Target() Pattern {
+	if self.Pattern != nil {
+		return self.Pattern
+	}
+	return self.Name
 }
 
 func (self *LetStatement) statementNode()        {}
 func (self *LetStatement) GetToken() token.Token { return self.Token }
 func (self *LetStatement) TokenLiteral() string  { return self.Token.Literal }
+func (self *LetStatement) Span() token.Span {
+	if isNil(self.Value) {
+		return spanTo(self.Token.Span(), self.Target())
+	}
+	return spanTo(self.Token.Span(), self.Value)
+}
 func (self *LetStatement) String() string {
 	var out bytes.Buffer
 
 	out.WriteString(self.TokenLiteral() + " ")
-	out.WriteString(self.Name.String())
+	out.WriteString(self.Target().String())
 	out.WriteString(" = ")
 
 	if self.Value != nil {
@@ -380,6 +584,9 @@ type ReturnStatement struct {
 func (self *ReturnStatement) statementNode()        {}
 func (self *ReturnStatement) GetToken() token.Token { return self.Token }
 func (self *ReturnStatement) TokenLiteral() string  { return self.Token.Literal }
+func (self *ReturnStatement) Span() token.Span {
+	return spanTo(self.Token.Span(), self.ReturnValue)
+}
 func (self *ReturnStatement) String() string {
 	var out bytes.Buffer
 
@@ -402,6 +609,9 @@ type ExpressionStatement struct {
 func (self *ExpressionStatement) statementNode()        {}
 func (self *ExpressionStatement) GetToken() token.Token { return self.Token }
 func (self *ExpressionStatement) TokenLiteral() string  { return self.Token.Literal }
+func (self *ExpressionStatement) Span() token.Span {
+	return spanFrom(self.Expression, self.Token.Span())
+}
 func (self *ExpressionStatement) String() string {
 	if self.Expression != nil {
 		return self.Expression.String()
@@ -417,6 +627,9 @@ type LazyExpression struct {
 func (self *LazyExpression) expressionNode()       {}
 func (self *LazyExpression) GetToken() token.Token { return self.Token }
 func (self *LazyExpression) TokenLiteral() string  { return self.Token.Literal }
+func (self *LazyExpression) Span() token.Span {
+	return spanTo(self.Token.Span(), self.Right)
+}
 func (self *LazyExpression) String() string {
 	var out bytes.Buffer
 
@@ -430,16 +643,53 @@ func (self *LazyExpression) String() string {
 type CommentStatement struct {
 	Token token.Token
 	Text  string
+	Block bool // true for '/* ... */' comments
 }
 
 func (self *CommentStatement) statementNode()        {}
 func (self *CommentStatement) GetToken() token.Token { return self.Token }
 func (self *CommentStatement) TokenLiteral() string  { return self.Token.Literal }
+func (self *CommentStatement) Span() token.Span      { return self.Token.Span() }
 func (self *CommentStatement) String() string {
 	var out bytes.Buffer
 
-	out.WriteString("// ")
-	out.WriteString(self.Text)
+	if self.Block {
+		out.WriteString("/* ")
+		out.WriteString(self.Text)
+		out.WriteString(" */")
+	} else {
+		out.WriteString("// ")
+		out.WriteString(self.Text)
+	}
 
 	return out.String()
 }
+
+// DocComment is a run of consecutive '///' comments. If it directly precedes a
+// let statement or a nac definition, it's attached to that node's Doc field.
+// Otherwise it's left as a statement of its own.
+type DocComment struct {
+	Token    token.Token // the first '///' token
+	EndToken token.Token // the last '///' token
+	Lines    []string
+}
+
+func (self *DocComment) statementNode()        {}
+func (self *DocComment) GetToken() token.Token { return self.Token }
+func (self *DocComment) TokenLiteral() string  { return self.Token.Literal }
+func (self *DocComment) Span() token.Span {
+	return self.Token.Span().To(self.EndToken.Span())
+}
+func (self *DocComment) String() string {
+	lines := make([]string, len(self.Lines))
+	for i, line := range self.Lines {
+		lines[i] = strings.TrimRight("/// "+line, " ")
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// Text returns the documentation without the leading slashes
+func (self *DocComment) Text() string {
+	return strings.Join(self.Lines, "\n")
+}
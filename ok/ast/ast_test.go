@@ -1,6 +1,7 @@
 package ast
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/jesseduffield/OK/ok/token"
@@ -27,3 +28,49 @@ func TestString(t *testing.T) {
 		t.Errorf("program.String() wrong. got=%q", program.String())
 	}
 }
+
+func TestInspect(t *testing.T) {
+	ident := func(name string) *Identifier {
+		return &Identifier{Token: token.Token{Type: token.IDENT, Literal: name}, Value: name}
+	}
+
+	// let x = fn(a) { b };
+	program := &Program{
+		Statements: []Statement{
+			&LetStatement{
+				Token: token.Token{Type: token.LET, Literal: "let"},
+				Name:  ident("x"),
+				Value: &FunctionLiteral{
+					Token:      token.Token{Type: token.FUNCTION, Literal: "fn"},
+					Parameters: []*Parameter{{Token: token.Token{Type: token.IDENT, Literal: "a"}, Name: ident("a")}},
+					Body: &BlockStatement{
+						Statements: []Statement{&ExpressionStatement{Expression: ident("b")}},
+					},
+				},
+			},
+		},
+	}
+
+	identifiers := []string{}
+	Inspect(program, func(node Node) bool {
+		if node, ok := node.(*Identifier); ok {
+			identifiers = append(identifiers, node.Value)
+		}
+		return true
+	})
+	if strings.Join(identifiers, ",") != "x,a,b" {
+		t.Errorf("wrong identifiers visited. got=%q", identifiers)
+	}
+
+	identifiers = []string{}
+	Inspect(program, func(node Node) bool {
+		if node, ok := node.(*Identifier); ok {
+			identifiers = append(identifiers, node.Value)
+		}
+		_, isFunction := node.(*FunctionLiteral)
+		return !isFunction
+	})
+	if strings.Join(identifiers, ",") != "x" {
+		t.Errorf("function literal's children should have been skipped. got=%q", identifiers)
+	}
+}
@@ -0,0 +1,37 @@
+package ast
+
+import (
+	"bytes"
+	"strconv"
+
+	"github.com/jesseduffield/OK/ok/token"
+)
+
+// ImportStatement loads another file as a module, e.g.
+// 'import helpers "lib/helpers.ok"'. If no name is given, the module is named
+// after its file.
+type ImportStatement struct {
+	Token token.Token // the 'import' token
+	// the namespace the module's bindings live under. If the import wasn't
+	// named, this is derived from the path and its token is the path's token
+	Name *Identifier
+	Path *StringLiteral
+}
+
+func (self *ImportStatement) statementNode()        {}
+func (self *ImportStatement) GetToken() token.Token { return self.Token }
+func (self *ImportStatement) TokenLiteral() string  { return self.Token.Literal }
+func (self *ImportStatement) Span() token.Span {
+	return spanTo(self.Token.Span(), self.Path)
+}
+func (self *ImportStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("import ")
+	out.WriteString(self.Name.String())
+	out.WriteString(" ")
+	out.WriteString(strconv.Quote(self.Path.Value))
+	out.WriteString(";")
+
+	return out.String()
+}
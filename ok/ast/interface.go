@@ -0,0 +1,72 @@
+package ast
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/jesseduffield/OK/ok/token"
+)
+
+// Interface declares a set of public methods that a nac can claim to
+// implement, e.g.
+//
+//	notaninterface greeter {
+//	  greet(name)
+//	}
+type Interface struct {
+	Token    token.Token // the 'notaninterface' token
+	EndToken token.Token // the closing '}' token
+	Name     string
+	Doc      *DocComment // nil unless the interface is preceded by a doc comment
+
+	Methods []*InterfaceMethod
+	// Members holds the methods and comments in the order they appear in the
+	// source. Methods is what you want for lookups.
+	Members []Node
+}
+
+func (self *Interface) statementNode()        {}
+func (self *Interface) GetToken() token.Token { return self.Token }
+func (self *Interface) TokenLiteral() string  { return self.Token.Literal }
+func (self *Interface) Span() token.Span {
+	return self.Token.Span().To(self.EndToken.Span())
+}
+func (self *Interface) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("notaninterface ")
+	out.WriteString(self.Name)
+	out.WriteString(" {\n")
+	for _, member := range self.Members {
+		out.WriteString("\t")
+		out.WriteString(member.String())
+		out.WriteString("\n")
+	}
+	out.WriteString("}")
+
+	return out.String()
+}
+
+// InterfaceMethod is a method's name and parameters, without a body. The
+// parameters are only there to say how many arguments the method takes, so
+// they can't have defaults, and 'selfish' is left out.
+type InterfaceMethod struct {
+	Token      token.Token // the method's name token
+	EndToken   token.Token // the closing ')' token
+	Name       string
+	Parameters []*Parameter
+}
+
+func (self *InterfaceMethod) GetToken() token.Token { return self.Token }
+func (self *InterfaceMethod) TokenLiteral() string  { return self.Token.Literal }
+func (self *InterfaceMethod) Span() token.Span {
+	return self.Token.Span().To(self.EndToken.Span())
+}
+func (self *InterfaceMethod) String() string {
+	params := []string{}
+	for _, p := range self.Parameters {
+		params = append(params, p.String())
+	}
+
+	return self.Name + "(" + strings.Join(params, ", ") + ")"
+}
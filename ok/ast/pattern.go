@@ -0,0 +1,70 @@
+package ast
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/jesseduffield/OK/ok/token"
+)
+
+// Pattern is what a destructuring let binds values to, e.g. the '[val, err]'
+// in 'let [val, err] = divide(5, 0)'. An identifier is the simplest pattern.
+type Pattern interface {
+	Node
+	patternNode()
+}
+
+func (self *Identifier) patternNode() {}
+
+type ArrayPattern struct {
+	Token    token.Token // the '[' token
+	EndToken token.Token // the ']' token
+	Elements []Pattern
+}
+
+func (self *ArrayPattern) patternNode()          {}
+func (self *ArrayPattern) GetToken() token.Token { return self.Token }
+func (self *ArrayPattern) TokenLiteral() string  { return self.Token.Literal }
+func (self *ArrayPattern) Span() token.Span {
+	return self.Token.Span().To(self.EndToken.Span())
+}
+func (self *ArrayPattern) String() string {
+	elements := []string{}
+	for _, el := range self.Elements {
+		elements = append(elements, el.String())
+	}
+
+	return "[" + strings.Join(elements, ", ") + "]"
+}
+
+type HashPatternPair struct {
+	Key   Expression // always a literal
+	Value Pattern
+}
+
+type HashPattern struct {
+	Token    token.Token // the '{' token
+	EndToken token.Token // the '}' token
+	Pairs    []HashPatternPair
+}
+
+func (self *HashPattern) patternNode()          {}
+func (self *HashPattern) GetToken() token.Token { return self.Token }
+func (self *HashPattern) TokenLiteral() string  { return self.Token.Literal }
+func (self *HashPattern) Span() token.Span {
+	return self.Token.Span().To(self.EndToken.Span())
+}
+func (self *HashPattern) String() string {
+	var out bytes.Buffer
+
+	pairs := []string{}
+	for _, pair := range self.Pairs {
+		pairs = append(pairs, pair.Key.String()+": "+pair.Value.String())
+	}
+
+	out.WriteString("{")
+	out.WriteString(strings.Join(pairs, ", "))
+	out.WriteString("}")
+
+	return out.String()
+}
@@ -0,0 +1,38 @@
+package ast
+
+import (
+	"reflect"
+
+	"github.com/jesseduffield/OK/ok/token"
+)
+
+// spanTo extends the given span to the end of the node. If the parser failed
+// to produce the node, the span is returned as is.
+func spanTo(start token.Span, end Node) token.Span {
+	if isNil(end) {
+		return start
+	}
+
+	return start.To(end.Span())
+}
+
+// spanFrom returns the node's span, falling back to the given span if the
+// parser failed to produce the node.
+func spanFrom(start Node, fallback token.Span) token.Span {
+	if isNil(start) {
+		return fallback
+	}
+
+	return start.Span()
+}
+
+// our parse functions sometimes return typed nil pointers, which don't equal
+// nil once they've been stored in an interface
+func isNil(node Node) bool {
+	if node == nil {
+		return true
+	}
+
+	value := reflect.ValueOf(node)
+	return value.Kind() == reflect.Ptr && value.IsNil()
+}
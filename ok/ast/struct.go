@@ -3,39 +3,171 @@ package ast
 import (
 	"bytes"
 	"fmt"
+	"strings"
 
 	"github.com/jesseduffield/OK/ok/token"
 )
 
+// StructMember is anything that can appear in the body of a nac after its
+// privacy acknowledgement: a field, a method, or a comment.
+type StructMember interface {
+	Node
+	structMemberNode()
+}
+
 type StructField struct {
-	Name   string
-	Public bool
+	Token    token.Token // The 'field' token
+	EndToken token.Token // The field's name token
+	Name     string
+	Public   bool
+	// evaluated each time the nac is instantiated. nil if the field starts
+	// out as NO!
+	Default Expression
+}
+
+func (self *StructField) structMemberNode()     {}
+func (self *StructField) GetToken() token.Token { return self.Token }
+func (self *StructField) TokenLiteral() string  { return self.Token.Literal }
+func (self *StructField) Span() token.Span {
+	return spanTo(self.Token.Span().To(self.EndToken.Span()), self.Default)
+}
+func (self *StructField) String() string {
+	var out bytes.Buffer
+
+	if self.Public {
+		out.WriteString("public ")
+	}
+	out.WriteString("field ")
+	out.WriteString(self.Name)
+	if self.Default != nil {
+		out.WriteString(" = ")
+		out.WriteString(self.Default.String())
+	}
+
+	return out.String()
 }
 
 type StructMethod struct {
+	Token           token.Token // The 'public' token, or the method's name token
+	Name            string
 	FunctionLiteral *FunctionLiteral
 	Public          bool
 }
 
+func (self *StructMethod) structMemberNode()     {}
+func (self *StructMethod) GetToken() token.Token { return self.Token }
+func (self *StructMethod) TokenLiteral() string  { return self.Token.Literal }
+func (self *StructMethod) Span() token.Span {
+	return spanTo(self.Token.Span(), self.FunctionLiteral)
+}
+func (self *StructMethod) String() string {
+	if self.Public {
+		return "public " + self.Name + " " + self.FunctionLiteral.String()
+	}
+	return self.Name + " " + self.FunctionLiteral.String()
+}
+
+// StructCarry declares which of a nac's fields survive evolving into another
+// nac, e.g. 'carry name, email into brgousie'
+type StructCarry struct {
+	Token    token.Token // the 'carry' token
+	EndToken token.Token // the target nac's name token
+	Fields   []*Identifier
+	Target   string
+}
+
+func (self *StructCarry) structMemberNode()     {}
+func (self *StructCarry) GetToken() token.Token { return self.Token }
+func (self *StructCarry) TokenLiteral() string  { return self.Token.Literal }
+func (self *StructCarry) Span() token.Span {
+	return self.Token.Span().To(self.EndToken.Span())
+}
+func (self *StructCarry) String() string {
+	fields := make([]string, len(self.Fields))
+	for i, field := range self.Fields {
+		fields[i] = field.String()
+	}
+	return "carry " + strings.Join(fields, ", ") + " into " + self.Target
+}
+
+// StructPack gives one of a nac's fields a privacy acknowledgement of its own,
+// e.g. 'pack email "I am reading somebody else's email"'. Accessing that field
+// from outside the nac needs this acknowledgement instead of the nac's.
+type StructPack struct {
+	Token           token.Token // the 'pack' token
+	EndToken        token.Token // the acknowledgement's string token
+	Field           *Identifier
+	Acknowledgement string
+}
+
+func (self *StructPack) structMemberNode()     {}
+func (self *StructPack) GetToken() token.Token { return self.Token }
+func (self *StructPack) TokenLiteral() string  { return self.Token.Literal }
+func (self *StructPack) Span() token.Span {
+	return self.Token.Span().To(self.EndToken.Span())
+}
+func (self *StructPack) String() string {
+	return "pack " + self.Field.String() + " \"" + self.Acknowledgement + "\""
+}
+
+func (self *CommentStatement) structMemberNode() {}
+func (self *DocComment) structMemberNode()       {}
+
 type Struct struct {
-	Token token.Token // The 'struct' token
-	Name  string
+	Token    token.Token // The 'struct' token
+	EndToken token.Token // The closing '}' token
+	Name     string
+	Doc      *DocComment // nil unless the nac is preceded by a doc comment
 
 	// will be empty if no privacy acknowledgement is set
 	PrivacyAcknowledgement string
 
+	// the notaninterfaces the nac claims to implement, e.g. 'greeter' or
+	// 'helpers.greeter'
+	Interfaces []Expression
+
 	Fields  []StructField
 	Methods map[string]StructMethod
+	Carries []*StructCarry
+	// fields with their own privacy acknowledgement
+	Packs []*StructPack
+
+	// Members holds the fields, methods and comments in the order they appear
+	// in the source. Fields and Methods are what you want for lookups.
+	Members []StructMember
+}
+
+// AcknowledgementFor returns the acknowledgement needed to access one of the
+// nac's private members from outside: the member's own pack if it has one,
+// otherwise the nac's. Empty if there's neither.
+func (self *Struct) AcknowledgementFor(member string) string {
+	for _, pack := range self.Packs {
+		if pack.Field.Value == member {
+			return pack.Acknowledgement
+		}
+	}
+	return self.PrivacyAcknowledgement
 }
 
 func (self *Struct) statementNode()        {}
 func (self *Struct) GetToken() token.Token { return self.Token }
 func (self *Struct) TokenLiteral() string  { return self.Token.Literal }
+func (self *Struct) Span() token.Span {
+	return self.Token.Span().To(self.EndToken.Span())
+}
 func (self *Struct) String() string {
 	var out bytes.Buffer
 
 	out.WriteString("notaclass ")
 	out.WriteString(self.Name)
+	if len(self.Interfaces) > 0 {
+		names := make([]string, len(self.Interfaces))
+		for i, name := range self.Interfaces {
+			names[i] = name.String()
+		}
+		out.WriteString(" implements ")
+		out.WriteString(strings.Join(names, ", "))
+	}
 	out.WriteString(" {\n")
 
 	if self.PrivacyAcknowledgement != "" {
@@ -44,29 +176,14 @@ func (self *Struct) String() string {
 		out.WriteString("\"\n\n")
 	}
 
-	for _, f := range self.Fields {
-		out.WriteString("\t")
-		if f.Public {
-			out.WriteString("public ")
+	for i, member := range self.Members {
+		if _, ok := member.(*StructMethod); ok && i > 0 {
+			if _, ok := self.Members[i-1].(*StructField); ok {
+				out.WriteString("\n")
+			}
 		}
-		out.WriteString("field ")
-		out.WriteString(f.Name)
-		out.WriteString("\n")
-	}
-
-	if len(self.Fields) > 0 && len(self.Methods) > 0 {
-		out.WriteString("\n")
-	}
-
-	for methodName, method := range self.Methods {
 		out.WriteString("\t")
-		if method.Public {
-			out.WriteString("public ")
-		}
-		out.WriteString(methodName)
-		out.WriteString(" ")
-
-		out.WriteString(method.FunctionLiteral.String())
+		out.WriteString(member.String())
 		out.WriteString("\n")
 	}
 	out.WriteString("}")
@@ -76,17 +193,26 @@ func (self *Struct) String() string {
 
 type StructInstantiation struct {
 	Token      token.Token
+	EndToken   token.Token // The closing ')' token
+	Namespace  string      // the module the nac comes from, e.g. 'helpers' in 'new helpers.person()'. Empty for local nacs
 	StructName string
-	Arguments  []Expression
+	Arguments  []Expression // passed to the nac's init method, if there are any
 }
 
 func (self *StructInstantiation) expressionNode()       {}
 func (self *StructInstantiation) GetToken() token.Token { return self.Token }
 func (self *StructInstantiation) TokenLiteral() string  { return self.Token.Literal }
+func (self *StructInstantiation) Span() token.Span {
+	return self.Token.Span().To(self.EndToken.Span())
+}
 func (self *StructInstantiation) String() string {
 	var out bytes.Buffer
 
 	out.WriteString("new ")
+	if self.Namespace != "" {
+		out.WriteString(self.Namespace)
+		out.WriteString(".")
+	}
 	out.WriteString(self.StructName)
 	out.WriteString("(")
 	for i, arg := range self.Arguments {
@@ -102,6 +228,7 @@ func (self *StructInstantiation) String() string {
 
 type StructMemberAccessExpression struct {
 	Token      token.Token // The . token
+	EndToken   token.Token // The member name token
 	Left       Expression
 	MemberName string
 }
@@ -109,6 +236,9 @@ type StructMemberAccessExpression struct {
 func (self *StructMemberAccessExpression) expressionNode()       {}
 func (self *StructMemberAccessExpression) GetToken() token.Token { return self.Token }
 func (self *StructMemberAccessExpression) TokenLiteral() string  { return self.Token.Literal }
+func (self *StructMemberAccessExpression) Span() token.Span {
+	return spanFrom(self.Left, self.Token.Span()).To(self.EndToken.Span())
+}
 func (self *StructMemberAccessExpression) String() string {
 	return fmt.Sprintf("%s.%s", self.Left.String(), self.MemberName)
 }
@@ -0,0 +1,129 @@
+package ast
+
+// Inspect traverses the tree rooted at node in depth-first order, calling f
+// for each node. If f returns false, the node's children are skipped. Nodes
+// the parser failed to produce are skipped.
+func Inspect(node Node, f func(Node) bool) {
+	if isNil(node) || !f(node) {
+		return
+	}
+
+	for _, child := range children(node) {
+		Inspect(child, f)
+	}
+}
+
+// children returns a node's direct children, in source order
+func children(node Node) []Node {
+	nodes := []Node{}
+	add := func(children ...Node) {
+		for _, child := range children {
+			if !isNil(child) {
+				nodes = append(nodes, child)
+			}
+		}
+	}
+
+	switch node := node.(type) {
+	case *Program:
+		for _, statement := range node.Statements {
+			add(statement)
+		}
+	case *BlockStatement:
+		for _, statement := range node.Statements {
+			add(statement)
+		}
+	case *ExpressionStatement:
+		add(node.Expression)
+	case *LetStatement:
+		add(node.Target(), node.Value)
+	case *ReturnStatement:
+		add(node.ReturnValue)
+	case *ImportStatement:
+		add(node.Name, node.Path)
+	case *PrefixExpression:
+		add(node.Right)
+	case *InfixExpression:
+		add(node.Left, node.Right)
+	case *LazyExpression:
+		add(node.Right)
+	case *InterpolatedString:
+		for _, exp := range node.Expressions {
+			add(exp)
+		}
+	case *IfExpression:
+		add(node.Condition, node.Consequence, node.Alternative)
+	case *SwitchExpression:
+		add(node.Subject)
+		for _, c := range node.Cases {
+			for _, value := range c.Values {
+				add(value)
+			}
+			add(c.Block)
+		}
+		add(node.Default)
+	case *RangeExpression:
+		add(node.Low, node.High)
+	case *FunctionLiteral:
+		for _, param := range node.Parameters {
+			add(param)
+		}
+		add(node.Body)
+	case *Parameter:
+		add(node.Name, node.Default)
+	case *CallExpression:
+		add(node.Function)
+		for _, arg := range node.Arguments {
+			add(arg)
+		}
+	case *ArrayLiteral:
+		for _, element := range node.Elements {
+			add(element)
+		}
+	case *IndexExpression:
+		add(node.Left, node.Index)
+	case *HashLiteral:
+		for _, pair := range node.Pairs {
+			add(pair.Key, pair.Value)
+		}
+	case *ArrayPattern:
+		for _, element := range node.Elements {
+			add(element)
+		}
+	case *HashPattern:
+		for _, pair := range node.Pairs {
+			add(pair.Key, pair.Value)
+		}
+	case *Struct:
+		for _, iface := range node.Interfaces {
+			add(iface)
+		}
+		for _, member := range node.Members {
+			add(member)
+		}
+	case *StructField:
+		add(node.Default)
+	case *StructMethod:
+		add(node.FunctionLiteral)
+	case *StructCarry:
+		for _, field := range node.Fields {
+			add(field)
+		}
+	case *StructPack:
+		add(node.Field)
+	case *StructInstantiation:
+		for _, arg := range node.Arguments {
+			add(arg)
+		}
+	case *StructMemberAccessExpression:
+		add(node.Left)
+	case *Interface:
+		add(node.Members...)
+	case *InterfaceMethod:
+		for _, param := range node.Parameters {
+			add(param)
+		}
+	}
+
+	return nodes
+}
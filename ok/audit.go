@@ -0,0 +1,45 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/jesseduffield/OK/ok/audit"
+)
+
+// runAudit implements 'ok audit', which lists every access of a nac's private
+// members and whether a privacy acknowledgement covers it. Returns the exit
+// code: 1 if any file fails to parse or any access would fail at runtime.
+func runAudit(args []string) int {
+	flags := flag.NewFlagSet("audit", flag.ContinueOnError)
+	asJSON := flags.Bool("json", false, "print the report as JSON instead of text")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: ok audit [--json] file ...")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+
+	programs, _, ok := parseFiles(flags.Args())
+	if !ok {
+		return 1
+	}
+
+	report := audit.Audit(programs...)
+	if *asJSON {
+		fmt.Print(report.JSON())
+	} else {
+		fmt.Print(report.Text())
+	}
+
+	if report.Failures() > 0 {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,493 @@
+// Package audit finds every access of a nac's private fields and methods from
+// outside the nac, without running anything, and works out whether a privacy
+// acknowledgement covers it.
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jesseduffield/OK/ok/ast"
+	"github.com/jesseduffield/OK/ok/token"
+)
+
+// the same prefix the evaluator looks for
+const acknowledgePrefix = "I acknowledge that "
+
+// Report lists the private accesses found in a set of programs
+type Report struct {
+	// in source order
+	Accesses []Access
+}
+
+// Access is a single 'x.member' expression, read or assignment, where member
+// is private on the nac x is (or might be) an instance of
+type Access struct {
+	Span       token.Span
+	Expression string
+	Member     string
+	Kind       string // "field" or "method"
+	// the nacs x might be an instance of. Usually there's just one, but if we
+	// can't tell what x is we list every nac with a private member of that name
+	Nacs []string
+	// the acknowledgements that cover the access, one for each nac. Empty if
+	// the access isn't covered
+	Acknowledgements []Acknowledgement
+	// true if the access would fail at runtime, in which case Reason says why
+	Fails  bool
+	Reason string
+}
+
+// Acknowledgement is an 'I acknowledge that ...' comment
+type Acknowledgement struct {
+	Span token.Span
+	Text string
+	// the statement after the comment, which is all the acknowledgement
+	// covers
+	Covers token.Span
+}
+
+// scope tracks what we know about the variables of a function body (or
+// program), which nested functions can see too
+type scope struct {
+	outer *scope
+	// the nac each variable was instantiated as, where we can tell
+	nacs map[string]string
+	// the variables whose private members are always accessible: selfish in
+	// a method, and the fossil passed to onevolve
+	owned map[string]bool
+	// the namespaces of imported modules, whose members are never private
+	modules map[string]bool
+}
+
+func newScope(outer *scope) *scope {
+	return &scope{outer: outer, nacs: map[string]string{}, owned: map[string]bool{}, modules: map[string]bool{}}
+}
+
+func (self *scope) nacOf(name string) (string, bool) {
+	for current := self; current != nil; current = current.outer {
+		if nac, ok := current.nacs[name]; ok {
+			return nac, true
+		}
+	}
+	return "", false
+}
+
+func (self *scope) isModule(name string) bool {
+	for current := self; current != nil; current = current.outer {
+		if _, ok := current.nacs[name]; ok {
+			return false
+		}
+		if current.modules[name] {
+			return true
+		}
+	}
+	return false
+}
+
+func (self *scope) owns(name string) bool {
+	for current := self; current != nil; current = current.outer {
+		if _, ok := current.nacs[name]; ok {
+			// shadowed by a variable of our own
+			return false
+		}
+		if current.owned[name] {
+			return true
+		}
+	}
+	return false
+}
+
+type auditor struct {
+	nacs             map[string]*ast.Struct
+	acknowledgements []Acknowledgement
+	accesses         []Access
+}
+
+// Audit lists the private accesses in the given programs. Nacs are identified
+// by name, so nacs from different files with the same name are treated as one.
+func Audit(programs ...*ast.Program) *Report {
+	a := &auditor{nacs: map[string]*ast.Struct{}}
+	for _, program := range programs {
+		ast.Inspect(program, func(node ast.Node) bool {
+			switch node := node.(type) {
+			case *ast.Struct:
+				if _, exists := a.nacs[node.Name]; !exists {
+					a.nacs[node.Name] = node
+				}
+			case *ast.Program:
+				a.collectAcknowledgements(node.Statements)
+			case *ast.BlockStatement:
+				a.collectAcknowledgements(node.Statements)
+			}
+			return true
+		})
+	}
+
+	for _, program := range programs {
+		a.visit(program, newScope(nil))
+	}
+
+	sort.SliceStable(a.accesses, func(i, j int) bool {
+		x, y := a.accesses[i].Span, a.accesses[j].Span
+		if x.File != y.File {
+			return x.File < y.File
+		}
+		return x.Start < y.Start
+	})
+
+	return &Report{Accesses: a.accesses}
+}
+
+// collectAcknowledgements finds the acknowledgements in a list of statements,
+// each covering the first statement after it that isn't a comment
+func (self *auditor) collectAcknowledgements(statements []ast.Statement) {
+	pending := []Acknowledgement{}
+	for _, statement := range statements {
+		comment, ok := statement.(*ast.CommentStatement)
+		if !ok {
+			for _, ack := range pending {
+				ack.Covers = statement.Span()
+				self.acknowledgements = append(self.acknowledgements, ack)
+			}
+			pending = pending[:0]
+			continue
+		}
+
+		if strings.HasPrefix(comment.Text, acknowledgePrefix) {
+			pending = append(pending, Acknowledgement{
+				Span: comment.Span(),
+				Text: strings.TrimPrefix(comment.Text, acknowledgePrefix),
+			})
+		}
+	}
+}
+
+// visit walks the tree in source order, keeping track of which variables hold
+// which nacs
+func (self *auditor) visit(node ast.Node, sc *scope) {
+	ast.Inspect(node, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.ImportStatement:
+			if node.Name != nil {
+				sc.modules[node.Name.Value] = true
+				delete(sc.nacs, node.Name.Value)
+			}
+		case *ast.LetStatement:
+			if node.Name != nil {
+				delete(sc.modules, node.Name.Value)
+				if instantiation, ok := node.Value.(*ast.StructInstantiation); ok {
+					sc.nacs[node.Name.Value] = instantiation.StructName
+				} else {
+					// could be anything, but it's not whatever it was before
+					sc.nacs[node.Name.Value] = ""
+				}
+			}
+		case *ast.FunctionLiteral:
+			fnScope := newScope(sc)
+			for _, param := range node.Parameters {
+				fnScope.nacs[param.Name.Value] = ""
+			}
+			self.visit(node.Body, fnScope)
+			return false
+		case *ast.Struct:
+			for _, member := range node.Members {
+				if method, ok := member.(*ast.StructMethod); ok {
+					self.visitMethod(method, sc)
+				} else {
+					self.visit(member, sc)
+				}
+			}
+			return false
+		case *ast.StructMemberAccessExpression:
+			self.check(node, sc)
+		}
+		return true
+	})
+}
+
+func (self *auditor) visitMethod(method *ast.StructMethod, sc *scope) {
+	methodScope := newScope(sc)
+	params := method.FunctionLiteral.Parameters
+	for _, param := range params {
+		methodScope.nacs[param.Name.Value] = ""
+	}
+	if len(params) > 0 && params[0].Name.Value == "selfish" {
+		delete(methodScope.nacs, "selfish")
+		methodScope.owned["selfish"] = true
+		params = params[1:]
+	}
+	// onevolve is allowed to rummage through the instance's former self
+	if method.Name == "onevolve" && len(params) > 0 {
+		delete(methodScope.nacs, params[0].Name.Value)
+		methodScope.owned[params[0].Name.Value] = true
+	}
+
+	self.visit(method.FunctionLiteral.Body, methodScope)
+}
+
+func (self *auditor) check(node *ast.StructMemberAccessExpression, sc *scope) {
+	candidates := []string{}
+
+	switch left := node.Left.(type) {
+	case *ast.Identifier:
+		// a module's bindings are all there for the taking
+		if sc.owns(left.Value) || sc.isModule(left.Value) {
+			return
+		}
+		if nac, ok := sc.nacOf(left.Value); ok && self.nacs[nac] != nil {
+			candidates = append(candidates, nac)
+		}
+	case *ast.StructInstantiation:
+		// we don't look inside modules, so we can't say anything about their
+		// nacs, even if one of ours has the same name
+		if left.Namespace != "" {
+			return
+		}
+		// the name could be a variable holding a nac, in which case we don't
+		// know which one
+		if self.nacs[left.StructName] != nil {
+			candidates = append(candidates, left.StructName)
+		}
+	}
+
+	if len(candidates) == 0 {
+		// we don't know what we're looking at, so any nac with a member of
+		// that name is a suspect
+		for _, str := range self.sortedNacs() {
+			if kind, _ := memberKind(str, node.MemberName); kind != "" {
+				candidates = append(candidates, str.Name)
+			}
+		}
+	}
+
+	access := Access{
+		Span:       node.Span(),
+		Expression: node.String(),
+		Member:     node.MemberName,
+	}
+	for _, name := range candidates {
+		str, ok := self.nacs[name]
+		if !ok {
+			continue
+		}
+		kind, private := memberKind(str, node.MemberName)
+		if !private {
+			continue
+		}
+		if access.Kind == "" {
+			access.Kind = kind
+		}
+		access.Nacs = append(access.Nacs, name)
+	}
+	if len(access.Nacs) == 0 {
+		return
+	}
+
+	reasons := []string{}
+	for _, name := range access.Nacs {
+		str := self.nacs[name]
+		if ack, ok := self.acknowledgementFor(str, node.MemberName, access.Span); ok {
+			access.Acknowledgements = append(access.Acknowledgements, ack)
+		} else {
+			reasons = append(reasons, self.uncoveredReason(str, node.MemberName, access.Span))
+		}
+	}
+	if len(reasons) > 0 {
+		access.Acknowledgements = nil
+		access.Fails = true
+		access.Reason = strings.Join(reasons, "; ")
+	}
+
+	self.accesses = append(self.accesses, access)
+}
+
+func (self *auditor) sortedNacs() []*ast.Struct {
+	result := []*ast.Struct{}
+	for _, str := range self.nacs {
+		result = append(result, str)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
+
+// memberKind returns whether name is a field or a method on the nac, and
+// whether it's private. The kind is empty if the nac has no such member.
+func memberKind(str *ast.Struct, name string) (string, bool) {
+	for _, field := range str.Fields {
+		if field.Name == name {
+			return "field", !field.Public
+		}
+	}
+	if method, ok := str.Methods[name]; ok {
+		return "method", !method.Public
+	}
+	return "", false
+}
+
+// acknowledgementFor returns the acknowledgement that lets code at the given
+// span access the nac's member. Like the evaluator, an acknowledgement covers
+// everything inside the statement after it, including functions defined there.
+func (self *auditor) acknowledgementFor(str *ast.Struct, member string, at token.Span) (Acknowledgement, bool) {
+	wanted := str.AcknowledgementFor(member)
+	if wanted == "" {
+		return Acknowledgement{}, false
+	}
+
+	for _, ack := range self.acknowledgements {
+		if ack.Text == wanted && ack.Covers.Contains(at) {
+			return ack, true
+		}
+	}
+	return Acknowledgement{}, false
+}
+
+func (self *auditor) uncoveredReason(str *ast.Struct, member string, at token.Span) string {
+	wanted := str.AcknowledgementFor(member)
+	if wanted == "" {
+		return fmt.Sprintf("%s has no pack, so its private members can't be acknowledged", str.Name)
+	}
+
+	pack := str.Name + "'s pack"
+	if wanted != str.PrivacyAcknowledgement {
+		pack = str.Name + "'s pack for " + member
+	}
+
+	for _, ack := range self.acknowledgements {
+		if ack.Covers.Contains(at) {
+			return fmt.Sprintf("the acknowledgement at %s doesn't match %s %q", ack.Span.Location(), pack, wanted)
+		}
+	}
+
+	// the nearest matching acknowledgement before the access, in case it's
+	// just a statement too far away
+	var nearest *Acknowledgement
+	for i, ack := range self.acknowledgements {
+		if ack.Text == wanted && ack.Span.File == at.File && ack.Span.Start < at.Start {
+			if nearest == nil || ack.Span.Start > nearest.Span.Start {
+				nearest = &self.acknowledgements[i]
+			}
+		}
+	}
+	if nearest != nil {
+		return fmt.Sprintf(
+			"the acknowledgement at %s only covers the statement after it",
+			nearest.Span.Location(),
+		)
+	}
+
+	return fmt.Sprintf("nothing acknowledges %s", pack)
+}
+
+// Failures returns how many of the accesses would fail at runtime
+func (self *Report) Failures() int {
+	count := 0
+	for _, access := range self.Accesses {
+		if access.Fails {
+			count++
+		}
+	}
+	return count
+}
+
+// Text renders the report for humans: one access per line, each followed by
+// what covers it or why it would fail
+func (self *Report) Text() string {
+	var out bytes.Buffer
+
+	for _, access := range self.Accesses {
+		fmt.Fprintf(
+			&out,
+			"%s: %s: private %s %s on %s\n",
+			access.Span.Location(),
+			access.Expression,
+			access.Kind,
+			access.Member,
+			strings.Join(access.Nacs, " or "),
+		)
+		if access.Fails {
+			fmt.Fprintf(&out, "  fails: %s\n", access.Reason)
+			continue
+		}
+		for _, ack := range access.Acknowledgements {
+			fmt.Fprintf(&out, "  acknowledged at %s: %q\n", ack.Span.Location(), ack.Text)
+		}
+	}
+
+	fmt.Fprintf(
+		&out,
+		"%d private %s, %d acknowledged, %d would fail\n",
+		len(self.Accesses),
+		plural(len(self.Accesses), "access", "accesses"),
+		len(self.Accesses)-self.Failures(),
+		self.Failures(),
+	)
+
+	return out.String()
+}
+
+func plural(count int, singular string, plural string) string {
+	if count == 1 {
+		return singular
+	}
+	return plural
+}
+
+type jsonReport struct {
+	Accesses     []jsonAccess `json:"accesses"`
+	Acknowledged int          `json:"acknowledged"`
+	Failures     int          `json:"failures"`
+}
+
+type jsonAccess struct {
+	Location         string                `json:"location"`
+	Expression       string                `json:"expression"`
+	Member           string                `json:"member"`
+	Kind             string                `json:"kind"`
+	Nacs             []string              `json:"nacs"`
+	Acknowledgements []jsonAcknowledgement `json:"acknowledgements"`
+	Fails            bool                  `json:"fails"`
+	Reason           string                `json:"reason,omitempty"`
+}
+
+type jsonAcknowledgement struct {
+	Location string `json:"location"`
+	Text     string `json:"text"`
+}
+
+// JSON renders the report as indented JSON
+func (self *Report) JSON() string {
+	report := jsonReport{
+		Accesses:     []jsonAccess{},
+		Acknowledged: len(self.Accesses) - self.Failures(),
+		Failures:     self.Failures(),
+	}
+	for _, access := range self.Accesses {
+		acks := []jsonAcknowledgement{}
+		for _, ack := range access.Acknowledgements {
+			acks = append(acks, jsonAcknowledgement{Location: ack.Span.Location(), Text: ack.Text})
+		}
+		report.Accesses = append(report.Accesses, jsonAccess{
+			Location:         access.Span.Location(),
+			Expression:       access.Expression,
+			Member:           access.Member,
+			Kind:             access.Kind,
+			Nacs:             access.Nacs,
+			Acknowledgements: acks,
+			Fails:            access.Fails,
+			Reason:           access.Reason,
+		})
+	}
+
+	var out bytes.Buffer
+	encoder := json.NewEncoder(&out)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	// we're only encoding strings, ints and bools, so this can't fail
+	_ = encoder.Encode(report)
+
+	return out.String()
+}
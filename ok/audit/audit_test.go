@@ -0,0 +1,271 @@
+package audit
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jesseduffield/OK/ok/ast"
+	"github.com/jesseduffield/OK/ok/lexer"
+	"github.com/jesseduffield/OK/ok/parser"
+)
+
+func TestAudit(t *testing.T) {
+	nacs := `
+notaclass person {
+  pack "this is bad"
+  field email
+  secret fn() { 1 }
+  public greet fn() { 2 }
+}
+notaclass rock {
+  field email
+}
+`
+
+	tests := []struct {
+		name     string
+		input    string
+		expected []string
+	}{
+		{
+			"acknowledged accesses",
+			`let p = new person();
+// I acknowledge that this is bad
+p.email = "x";
+// I acknowledge that this is bad
+p.secret();`,
+			[]string{
+				`line 13, column 1: p.email on person: acknowledged at line 12, column 1`,
+				`line 15, column 1: p.secret on person: acknowledged at line 14, column 1`,
+			},
+		},
+		{
+			"an acknowledgement only covers the statement after it",
+			`let p = new person();
+// I acknowledge that this is bad
+p.email = "x";
+p.email;`,
+			[]string{
+				`line 13, column 1: p.email on person: acknowledged at line 12, column 1`,
+				`line 14, column 1: p.email on person: fails: the acknowledgement at line 12, column 1 only covers the statement after it`,
+			},
+		},
+		{
+			"module members aren't private",
+			`import helpers "helpers.ok";
+helpers.email;
+let f = fn() { helpers.email };
+new helpers.person().email;`,
+			[]string{},
+		},
+		{
+			"a variable shadowing a module is checked as usual",
+			`import helpers "helpers.ok";
+let helpers = new person();
+helpers.email;`,
+			[]string{
+				`line 13, column 1: helpers.email on person: fails: nothing acknowledges person's pack`,
+			},
+		},
+		{
+			"public methods aren't listed",
+			`let p = new person();
+p.greet();`,
+			[]string{},
+		},
+		{
+			"an acknowledgement doesn't cover what comes before it",
+			`let p = new person();
+p.email;
+// I acknowledge that this is bad`,
+			[]string{
+				`line 12, column 1: p.email on person: fails: nothing acknowledges person's pack`,
+			},
+		},
+		{
+			"the wrong acknowledgement",
+			`let p = new person();
+// I acknowledge that this is fine
+p.email;`,
+			[]string{
+				`line 13, column 1: p.email on person: fails: the acknowledgement at line 12, column 1 doesn't match person's pack "this is bad"`,
+			},
+		},
+		{
+			"functions defined in the acknowledged statement are covered",
+			`let p = new person();
+// I acknowledge that this is bad
+let f = fn() { p.email };`,
+			[]string{
+				`line 13, column 16: p.email on person: acknowledged at line 12, column 1`,
+			},
+		},
+		{
+			"fields with their own pack need their own acknowledgement",
+			`notaclass spy {
+  pack "this is bad"
+  field name
+  field secrets
+  pack secrets "I am a traitor"
+}
+let s = new spy();
+// I acknowledge that this is bad
+s.name;
+// I acknowledge that this is bad
+s.secrets;
+// I acknowledge that I am a traitor
+s.secrets;`,
+			[]string{
+				`line 19, column 1: s.name on spy: acknowledged at line 18, column 1`,
+				`line 21, column 1: s.secrets on spy: fails: the acknowledgement at line 20, column 1 doesn't match spy's pack for secrets "I am a traitor"`,
+				`line 23, column 1: s.secrets on spy: acknowledged at line 22, column 1`,
+			},
+		},
+		{
+			"a nac without a pack",
+			`let r = new rock();
+r.email;`,
+			[]string{
+				`line 12, column 1: r.email on rock: fails: rock has no pack, so its private members can't be acknowledged`,
+			},
+		},
+		{
+			"if we can't tell what the nac is, every nac with that private member is a suspect",
+			`let f = fn(x) {
+  // I acknowledge that this is bad
+  x.email
+};`,
+			[]string{
+				`line 13, column 3: x.email on person or rock: fails: rock has no pack, so its private members can't be acknowledged`,
+			},
+		},
+		{
+			"instantiating a nac held in a variable",
+			`let kind = person;
+let p = new kind();
+p.email;`,
+			[]string{
+				`line 13, column 1: p.email on person or rock: fails: nothing acknowledges person's pack; rock has no pack, so its private members can't be acknowledged`,
+			},
+		},
+		{
+			"selfish and the fossil passed to onevolve are fair game",
+			`notaclass brgousie {
+  field email
+  evolve fn(selfish) { selfish.email; NO! }
+  onevolve fn(selfish, old) { let f = fn() { old.email }; selfish.email = f() }
+}`,
+			[]string{},
+		},
+		{
+			"other instances of the same nac aren't",
+			`notaclass twin {
+  pack "twins are weird"
+  field email
+  public copy fn(selfish, other) { selfish.email = other.email }
+}`,
+			[]string{
+				`line 14, column 52: other.email on person or rock or twin: fails: nothing acknowledges person's pack; rock has no pack, so its private members can't be acknowledged; nothing acknowledges twin's pack`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := Audit(parse(t, nacs+tt.input))
+
+			got := []string{}
+			for _, access := range report.Accesses {
+				line := access.Span.Location() + ": " + access.Expression + " on " + strings.Join(access.Nacs, " or ") + ": "
+				if access.Fails {
+					line += "fails: " + access.Reason
+				} else {
+					locations := []string{}
+					for _, ack := range access.Acknowledgements {
+						locations = append(locations, ack.Span.Location())
+					}
+					line += "acknowledged at " + strings.Join(locations, ", ")
+				}
+				got = append(got, line)
+			}
+
+			if strings.Join(got, "\n") != strings.Join(tt.expected, "\n") {
+				t.Errorf("wrong accesses. expected=\n%s\ngot=\n%s", strings.Join(tt.expected, "\n"), strings.Join(got, "\n"))
+			}
+		})
+	}
+}
+
+func TestText(t *testing.T) {
+	input := `
+notaclass person {
+  pack "this is bad"
+  field email
+}
+let p = new person();
+// I acknowledge that this is bad
+p.email;
+let r = new person();
+let f = fn() { r.email };
+`
+
+	expected := `line 8, column 1: p.email: private field email on person
+  acknowledged at line 7, column 1: "this is bad"
+line 10, column 16: r.email: private field email on person
+  fails: the acknowledgement at line 7, column 1 only covers the statement after it
+2 private accesses, 1 acknowledged, 1 would fail
+`
+
+	got := Audit(parse(t, input)).Text()
+	if got != expected {
+		t.Errorf("wrong text. expected=\n%s\ngot=\n%s", expected, got)
+	}
+}
+
+func TestJSON(t *testing.T) {
+	input := `
+notaclass person {
+  pack "this is bad"
+  field email
+}
+let p = new person();
+p.email;
+`
+
+	expected := `{
+  "accesses": [
+    {
+      "location": "line 7, column 1",
+      "expression": "p.email",
+      "member": "email",
+      "kind": "field",
+      "nacs": [
+        "person"
+      ],
+      "acknowledgements": [],
+      "fails": true,
+      "reason": "nothing acknowledges person's pack"
+    }
+  ],
+  "acknowledged": 0,
+  "failures": 1
+}
+`
+
+	got := Audit(parse(t, input)).JSON()
+	if got != expected {
+		t.Errorf("wrong JSON. expected=\n%s\ngot=\n%s", expected, got)
+	}
+}
+
+func parse(t *testing.T, input string) *ast.Program {
+	t.Helper()
+
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	for _, d := range p.Diagnostics() {
+		t.Fatalf("parser error: %s", d.Message)
+	}
+
+	return program
+}
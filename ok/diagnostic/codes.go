@@ -0,0 +1,77 @@
+package diagnostic
+
+// Code identifies the kind of problem a diagnostic describes. Codes are stable:
+// once published, a code keeps its meaning, so tools can match on it.
+type Code string
+
+// Syntax errors, found by the parser
+const (
+	UnexpectedToken       Code = "OK001"
+	ExpectedToken         Code = "OK002"
+	ComparisonOperator    Code = "OK003"
+	NonASCIICharacter     Code = "OK004"
+	InvalidEscapeSequence Code = "OK005"
+	UnterminatedComment   Code = "OK006"
+	InvalidNumber         Code = "OK007"
+	IdentifierTooLong     Code = "OK008"
+	IdentifierUppercase   Code = "OK009"
+	IdentifierUnderscore  Code = "OK010"
+	LogicalOperand        Code = "OK011"
+	SwitchBlockTooLong    Code = "OK012"
+	PublicField           Code = "OK013"
+	InvalidImport         Code = "OK014"
+	InvalidPattern        Code = "OK015"
+	InvalidParameter      Code = "OK016"
+)
+
+// Runtime errors, found by the evaluator
+const (
+	UndeclaredIdentifier Code = "OK100"
+	TypeMismatch         Code = "OK101"
+	UnknownOperator      Code = "OK102"
+	DivisionByZero       Code = "OK103"
+	IndexOutOfBounds     Code = "OK104"
+	InvalidIndex         Code = "OK105"
+	NotAFunction         Code = "OK106"
+	InvalidArgument      Code = "OK107"
+	PrivateAccess        Code = "OK108"
+	UndefinedMember      Code = "OK109"
+	UndefinedNac         Code = "OK110"
+	InvalidAssignment    Code = "OK111"
+	InvalidEvolution     Code = "OK112"
+	ModuleNotFound       Code = "OK113"
+	ImportCycle          Code = "OK114"
+	PatternMismatch      Code = "OK115"
+	UnsatisfiedInterface Code = "OK116"
+	InvalidPack          Code = "OK117"
+	ForbiddenImport      Code = "OK118"
+	Redeclaration        Code = "OK119"
+	Internal             Code = "OK199"
+)
+
+// Warnings about a program's design, found without running it, e.g. by
+// 'ok evolutions'
+const (
+	EvolutionCycle   Code = "OK200"
+	UnreachableNac   Code = "OK201"
+	UnknownEvolution Code = "OK202"
+)
+
+var links = map[Code]string{
+	ComparisonOperator:   README + "#one-comparison-operator",
+	IdentifierTooLong:    README + "#familiarity-admits-brevity",
+	IdentifierUppercase:  README + "#familiarity-admits-brevity",
+	IdentifierUnderscore: README + "#familiarity-admits-brevity",
+	SwitchBlockTooLong:   README + "#readable-switches",
+	PrivateAccess:        README + "#all-fields-are-private",
+	InvalidPack:          README + "#all-fields-are-private",
+	EvolutionCycle:       README + "#evolution-over-composition",
+	UnreachableNac:       README + "#evolution-over-composition",
+	UnknownEvolution:     README + "#evolution-over-composition",
+}
+
+// Link returns the section of the README explaining the rule behind the code,
+// or an empty string if there isn't one
+func (self Code) Link() string {
+	return links[self]
+}
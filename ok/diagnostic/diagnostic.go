@@ -0,0 +1,155 @@
+package diagnostic
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/jesseduffield/OK/ok/token"
+)
+
+type Severity int
+
+const (
+	Error Severity = iota
+	Warning
+)
+
+func (self Severity) String() string {
+	switch self {
+	case Warning:
+		return "warning"
+	default:
+		return "error"
+	}
+}
+
+const README = "https://github.com/jesseduffield/ok"
+
+// Fix is a suggested change that can be applied to the source without any
+// human judgement: replacing the code in Span with Replacement.
+type Fix struct {
+	Span        token.Span
+	Replacement string
+}
+
+// Diagnostic describes a problem found in a program, either while parsing it
+// or while running it.
+type Diagnostic struct {
+	Severity Severity
+	Code     Code
+	// the offending source code
+	Span token.Span
+	// human-readable description of where the problem is, e.g.
+	// 'line 1, column 3 (foo)'
+	Location string
+	Message  string
+	// link to the relevant section of the README. May be empty
+	Link string
+	// may be nil
+	Fix *Fix
+}
+
+// String returns the diagnostic in the single-line format we use for error
+// messages, followed by the README link if there is one.
+func (self Diagnostic) String() string {
+	var out strings.Builder
+
+	if self.Location != "" {
+		out.WriteString(self.Location)
+		out.WriteString(": ")
+	}
+	out.WriteString(self.Message)
+
+	if self.Link != "" {
+		out.WriteString("\nSee ")
+		out.WriteString(self.Link)
+	}
+
+	return out.String()
+}
+
+// Render formats the diagnostic for display to a human, including an excerpt
+// of the given source with the problem underlined, e.g.
+//
+//	error[OK008]: line 1, column 5 (REALLY_LONG): Identifier must be ...
+//	  |
+//	1 | let REALLY_LONG = 1;
+//	  |     ^^^^^^^^^^^
+//	  = help: replace with 'rl'
+//	  = see https://github.com/jesseduffield/ok#familiarity-admits-brevity
+//
+// The source may be empty, in which case no excerpt is shown.
+func (self Diagnostic) Render(source string) string {
+	var out strings.Builder
+
+	fmt.Fprintf(&out, "%s[%s]: ", self.Severity, self.Code)
+	if self.Location != "" {
+		out.WriteString(self.Location)
+		out.WriteString(": ")
+	}
+	out.WriteString(self.Message)
+
+	excerpt := ""
+	if source != "" && self.Span != (token.Span{}) {
+		excerpt = self.Span.Excerpt(source)
+	}
+	if excerpt != "" {
+		out.WriteString("\n")
+		out.WriteString(excerpt)
+	}
+
+	// lining the notes up with the excerpt's gutter
+	gutter := strings.Repeat(" ", len(fmt.Sprint(self.Span.Line+1)))
+	if self.Fix != nil {
+		fmt.Fprintf(&out, "\n%s = help: replace with '%s'", gutter, self.Fix.Replacement)
+	}
+	if self.Link != "" {
+		fmt.Fprintf(&out, "\n%s = see %s", gutter, self.Link)
+	}
+
+	return out.String()
+}
+
+// Print renders each diagnostic, separated by blank lines
+func Print(w io.Writer, diagnostics []Diagnostic, source string) {
+	for i, d := range diagnostics {
+		if i > 0 {
+			io.WriteString(w, "\n")
+		}
+		io.WriteString(w, d.Render(source))
+		io.WriteString(w, "\n")
+	}
+}
+
+// ApplyFixes returns the source with the fixes of all the given diagnostics
+// applied. Fixes that overlap an earlier fix are skipped.
+func ApplyFixes(source string, diagnostics []Diagnostic) string {
+	fixes := []*Fix{}
+	for _, d := range diagnostics {
+		if d.Fix != nil {
+			fixes = append(fixes, d.Fix)
+		}
+	}
+
+	// sorting by start offset. Insertion sort because there'll only be a few
+	for i := 1; i < len(fixes); i++ {
+		for j := i; j > 0 && fixes[j].Span.Start < fixes[j-1].Span.Start; j-- {
+			fixes[j], fixes[j-1] = fixes[j-1], fixes[j]
+		}
+	}
+
+	var out strings.Builder
+	position := 0
+	for _, fix := range fixes {
+		if fix.Span.Start < position || fix.Span.End > len(source) {
+			continue
+		}
+		out.WriteString(source[position:fix.Span.Start])
+		out.WriteString(fix.Replacement)
+		position = fix.Span.End
+	}
+	out.WriteString(source[position:])
+
+	return out.String()
+}
@@ -0,0 +1,74 @@
+package diagnostic
+
+import (
+	"testing"
+
+	"github.com/jesseduffield/OK/ok/token"
+)
+
+func TestString(t *testing.T) {
+	d := Diagnostic{
+		Code:     ComparisonOperator,
+		Location: "line 1, column 3 (>)",
+		Message:  "Unexpected token '>'.",
+		Link:     ComparisonOperator.Link(),
+	}
+
+	expected := "line 1, column 3 (>): Unexpected token '>'.\nSee https://github.com/jesseduffield/ok#one-comparison-operator"
+	if d.String() != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, d.String())
+	}
+}
+
+func TestRender(t *testing.T) {
+	source := "let x = 1;\nlet REALLY_LONG = 2;"
+	d := Diagnostic{
+		Code:     IdentifierTooLong,
+		Span:     token.Span{File: "main.ok", Start: 15, End: 26, Line: 1, Column: 5},
+		Location: "line 2, column 5 (REALLY_LONG)",
+		Message:  "Identifier must be at most eight characters long; consider using 'rllylong' instead.",
+		Link:     IdentifierTooLong.Link(),
+		Fix: &Fix{
+			Span:        token.Span{File: "main.ok", Start: 15, End: 26, Line: 1, Column: 5},
+			Replacement: "rllylong",
+		},
+	}
+
+	expected := `error[OK008]: line 2, column 5 (REALLY_LONG): Identifier must be at most eight characters long; consider using 'rllylong' instead.
+ --> main.ok:2:5
+  |
+2 | let REALLY_LONG = 2;
+  |     ^^^^^^^^^^^
+  = help: replace with 'rllylong'
+  = see https://github.com/jesseduffield/ok#familiarity-admits-brevity`
+
+	if d.Render(source) != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, d.Render(source))
+	}
+
+	// without the source we just get the header and notes
+	expected = `error[OK008]: line 2, column 5 (REALLY_LONG): Identifier must be at most eight characters long; consider using 'rllylong' instead.
+  = help: replace with 'rllylong'
+  = see https://github.com/jesseduffield/ok#familiarity-admits-brevity`
+
+	if d.Render("") != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, d.Render(""))
+	}
+}
+
+func TestApplyFixes(t *testing.T) {
+	source := "let MY_VAR = OTHER_VAR;"
+	diagnostics := []Diagnostic{
+		{Fix: &Fix{Span: token.Span{Start: 13, End: 22}, Replacement: "othervar"}},
+		{Message: "no fix here"},
+		{Fix: &Fix{Span: token.Span{Start: 4, End: 10}, Replacement: "myvar"}},
+		// overlaps the previous fix so is skipped
+		{Fix: &Fix{Span: token.Span{Start: 4, End: 6}, Replacement: "oops"}},
+	}
+
+	expected := "let myvar = othervar;"
+	actual := ApplyFixes(source, diagnostics)
+	if actual != expected {
+		t.Errorf("expected %q, got %q", expected, actual)
+	}
+}
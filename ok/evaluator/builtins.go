@@ -5,7 +5,9 @@ import (
 	"io"
 	"sync"
 	"time"
+	"unicode/utf8"
 
+	"github.com/jesseduffield/OK/ok/diagnostic"
 	"github.com/jesseduffield/OK/ok/object"
 )
 
@@ -14,7 +16,7 @@ func (e *Evaluator) getBuiltins(out io.Writer) map[string]*object.Builtin {
 		"len": {
 			Fn: func(args ...object.Object) object.Object {
 				if len(args) != 1 {
-					return e.newError("wrong number of arguments. got=%d, want=1",
+					return e.newError(diagnostic.InvalidArgument, "wrong number of arguments. got=%d, want=1",
 						len(args))
 				}
 
@@ -22,22 +24,22 @@ func (e *Evaluator) getBuiltins(out io.Writer) map[string]*object.Builtin {
 				case *object.Array:
 					return &object.Integer{Value: int64(len(arg.Elements))}
 				case *object.String:
-					return &object.Integer{Value: int64(len(arg.Value))}
+					return &object.Integer{Value: int64(utf8.RuneCountInString(arg.Value))}
 				default:
-					return e.newError("argument to `len` not supported, got %s",
-						args[0].Type())
+					return e.newError(diagnostic.InvalidArgument, "argument to `len` not supported, got %s",
+						typeName(args[0]))
 				}
 			},
 		},
 		"first": {
 			Fn: func(args ...object.Object) object.Object {
 				if len(args) != 1 {
-					return e.newError("wrong number of arguments. got=%d, want=1",
+					return e.newError(diagnostic.InvalidArgument, "wrong number of arguments. got=%d, want=1",
 						len(args))
 				}
 				if args[0].Type() != object.ARRAY_OBJ {
-					return e.newError("argument to `first` must be ARRAY, got %s",
-						args[0].Type())
+					return e.newError(diagnostic.InvalidArgument, "argument to `first` must be ARRAY, got %s",
+						typeName(args[0]))
 				}
 
 				arr := args[0].(*object.Array)
@@ -51,12 +53,12 @@ func (e *Evaluator) getBuiltins(out io.Writer) map[string]*object.Builtin {
 		"last": {
 			Fn: func(args ...object.Object) object.Object {
 				if len(args) != 1 {
-					return e.newError("wrong number of arguments. got=%d, want=1",
+					return e.newError(diagnostic.InvalidArgument, "wrong number of arguments. got=%d, want=1",
 						len(args))
 				}
 				if args[0].Type() != object.ARRAY_OBJ {
-					return e.newError("argument to `last` must be ARRAY, got %s",
-						args[0].Type())
+					return e.newError(diagnostic.InvalidArgument, "argument to `last` must be ARRAY, got %s",
+						typeName(args[0]))
 				}
 
 				arr := args[0].(*object.Array)
@@ -71,12 +73,12 @@ func (e *Evaluator) getBuiltins(out io.Writer) map[string]*object.Builtin {
 		"rest": {
 			Fn: func(args ...object.Object) object.Object {
 				if len(args) != 1 {
-					return e.newError("wrong number of arguments. got=%d, want=1",
+					return e.newError(diagnostic.InvalidArgument, "wrong number of arguments. got=%d, want=1",
 						len(args))
 				}
 				if args[0].Type() != object.ARRAY_OBJ {
-					return e.newError("argument to `rest` must be ARRAY, got %s",
-						args[0].Type())
+					return e.newError(diagnostic.InvalidArgument, "argument to `rest` must be ARRAY, got %s",
+						typeName(args[0]))
 				}
 
 				arr := args[0].(*object.Array)
@@ -93,12 +95,12 @@ func (e *Evaluator) getBuiltins(out io.Writer) map[string]*object.Builtin {
 		"push": {
 			Fn: func(args ...object.Object) object.Object {
 				if len(args) != 2 {
-					return e.newError("wrong number of arguments. got=%d, want=2",
+					return e.newError(diagnostic.InvalidArgument, "wrong number of arguments. got=%d, want=2",
 						len(args))
 				}
 				if args[0].Type() != object.ARRAY_OBJ {
-					return e.newError("argument to `push` must be ARRAY, got %s",
-						args[0].Type())
+					return e.newError(diagnostic.InvalidArgument, "argument to `push` must be ARRAY, got %s",
+						typeName(args[0]))
 				}
 
 				arr := args[0].(*object.Array)
@@ -125,19 +127,77 @@ func (e *Evaluator) getBuiltins(out io.Writer) map[string]*object.Builtin {
 				return nativeBoolToBooleanObject(args[0] != object.NULL)
 			},
 		},
+		"history": {
+			Fn: func(args ...object.Object) object.Object {
+				if len(args) != 1 {
+					return e.newError(diagnostic.InvalidArgument, "wrong number of arguments. got=%d, want=1", len(args))
+				}
+
+				instance, ok := args[0].(*object.StructInstance)
+				if !ok {
+					return e.newError(
+						diagnostic.InvalidArgument,
+						"argument to `history` must be a nac instance, got %s",
+						typeName(args[0]),
+					)
+				}
+
+				elements := make([]object.Object, len(instance.History))
+				for i, evolution := range instance.History {
+					hash := object.NewHash()
+					for _, pair := range [][2]string{
+						{"from", evolution.From},
+						{"to", evolution.To},
+						{"at", evolution.Location},
+					} {
+						key := &object.String{Value: pair[0]}
+						hash.Set(key.HashKey(), object.HashPair{Key: key, Value: &object.String{Value: pair[1]}})
+					}
+					elements[i] = hash
+				}
+
+				return &object.Array{Elements: elements}
+			},
+		},
+		"implements?": {
+			Fn: func(args ...object.Object) object.Object {
+				if len(args) != 2 {
+					return e.newError(diagnostic.InvalidArgument, "wrong number of arguments. got=%d, want=2", len(args))
+				}
+
+				iface, ok := args[1].(*object.Interface)
+				if !ok {
+					return e.newError(
+						diagnostic.InvalidArgument,
+						"second argument to `implements?` must be NOTANINTERFACE, got %s",
+						typeName(args[1]),
+					)
+				}
+
+				// we ask about the nac the instance is now, which won't be the
+				// one it started out as if it's since evolved
+				instance, ok := args[0].(*object.StructInstance)
+				if !ok {
+					return object.FALSE
+				}
+
+				return nativeBoolToBooleanObject(unsatisfied(instance.Struct, iface.Definition) == "")
+			},
+		},
 		"sleep": {
 			Fn: func(args ...object.Object) object.Object {
 				if len(args) != 1 {
-					return e.newError("wrong number of arguments. got=%d, want=1", len(args))
+					return e.newError(diagnostic.InvalidArgument, "wrong number of arguments. got=%d, want=1", len(args))
 				}
-				if args[0].Type() != object.INTEGER_OBJ {
+				if !isNumeric(args[0]) {
 					return e.newError(
-						"argument to `sleep` must be INTEGER, got %s",
-						args[0].Type(),
+						diagnostic.InvalidArgument,
+						"argument to `sleep` must be INTEGER or FLOAT, got %s",
+						typeName(args[0]),
 					)
 				}
 
-				time.Sleep(time.Duration(args[0].(*object.Integer).Value) * time.Second)
+				time.Sleep(time.Duration(toFloat(args[0]) * float64(time.Second)))
 
 				return object.NULL
 			},
@@ -145,22 +205,24 @@ func (e *Evaluator) getBuiltins(out io.Writer) map[string]*object.Builtin {
 		"map": {
 			Fn: func(args ...object.Object) object.Object {
 				if len(args) != 2 {
-					return e.newError("wrong number of arguments. got=%d, want=2", len(args))
+					return e.newError(diagnostic.InvalidArgument, "wrong number of arguments. got=%d, want=2", len(args))
 				}
 
 				arr := args[0]
 				if arr.Type() != object.ARRAY_OBJ {
 					return e.newError(
+						diagnostic.InvalidArgument,
 						"First argument to `map` must be ARRAY, got %s",
-						arr.Type(),
+						typeName(arr),
 					)
 				}
 
 				fn := args[1]
 				if fn.Type() != object.FUNCTION_OBJ {
 					return e.newError(
+						diagnostic.InvalidArgument,
 						"Second argument to `map` must be FUNCTION, got %s",
-						fn.Type(),
+						typeName(fn),
 					)
 				}
 
@@ -168,6 +230,7 @@ func (e *Evaluator) getBuiltins(out io.Writer) map[string]*object.Builtin {
 				fnObj := fn.(*object.Function)
 				if len(fnObj.Parameters) > 2 || len(fnObj.Parameters) < 1 {
 					return e.newError(
+						diagnostic.InvalidArgument,
 						"Function must have 1 or 2 parameters, got %d",
 						len(fnObj.Parameters),
 					)
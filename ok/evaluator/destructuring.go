@@ -0,0 +1,107 @@
+package evaluator
+
+import (
+	"github.com/jesseduffield/OK/ok/ast"
+	"github.com/jesseduffield/OK/ok/diagnostic"
+	"github.com/jesseduffield/OK/ok/object"
+)
+
+type binding struct {
+	name  string
+	value object.Object
+}
+
+// evalDestructuring binds the parts of the value to the names in the pattern.
+// Nothing is bound unless the whole pattern matches.
+func (e *Evaluator) evalDestructuring(
+	pattern ast.Pattern,
+	value object.Object,
+	env *object.Environment,
+) object.Object {
+	bindings, err := e.destructure(pattern, value, env, nil)
+	if err != nil {
+		return err
+	}
+
+	for _, b := range bindings {
+		if err := e.checkNotANac(pattern, b.name, env); err != nil {
+			return err
+		}
+	}
+	for _, b := range bindings {
+		env.Set(b.name, b.value)
+	}
+
+	return nil
+}
+
+func (e *Evaluator) destructure(
+	pattern ast.Pattern,
+	value object.Object,
+	env *object.Environment,
+	bindings []binding,
+) ([]binding, *object.Error) {
+	switch pattern := pattern.(type) {
+	case *ast.Identifier:
+		if pattern.Value == "_" {
+			return bindings, nil
+		}
+		return append(bindings, binding{name: pattern.Value, value: value}), nil
+
+	case *ast.ArrayPattern:
+		array, ok := value.(*object.Array)
+		if !ok {
+			return nil, e.at(pattern).newError(diagnostic.PatternMismatch, "cannot destructure %s into %s: it's not an array", typeName(value), pattern.String())
+		}
+		if len(array.Elements) != len(pattern.Elements) {
+			return nil, e.at(pattern).newError(
+				diagnostic.PatternMismatch,
+				"cannot destructure an array of length %d into %s, which expects length %d",
+				len(array.Elements), pattern.String(), len(pattern.Elements),
+			)
+		}
+
+		for i, element := range pattern.Elements {
+			var err *object.Error
+			bindings, err = e.destructure(element, array.Elements[i], env, bindings)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		return bindings, nil
+
+	case *ast.HashPattern:
+		hash, ok := value.(*object.Hash)
+		if !ok {
+			return nil, e.at(pattern).newError(diagnostic.PatternMismatch, "cannot destructure %s into a hash pattern: it's not a hash", typeName(value))
+		}
+
+		for _, pair := range pattern.Pairs {
+			key := e.Eval(pair.Key, env)
+			if isError(key) {
+				return nil, key.(*object.Error)
+			}
+			hashKey, ok := key.(object.Hashable)
+			if !ok {
+				return nil, e.at(pair.Key).newError(diagnostic.InvalidIndex, "unusable as hash key: %s", typeName(key))
+			}
+
+			found, ok := hash.Get(hashKey.HashKey())
+			if !ok {
+				return nil, e.at(pair.Key).newError(diagnostic.PatternMismatch, "cannot destructure hash: it has no key %s", pair.Key.String())
+			}
+
+			var err *object.Error
+			bindings, err = e.destructure(pair.Value, found.Value, env, bindings)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		return bindings, nil
+
+	default:
+		return nil, e.newError(diagnostic.Internal, "unknown pattern: %T", pattern)
+	}
+}
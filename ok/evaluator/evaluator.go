@@ -3,20 +3,26 @@ package evaluator
 import (
 	"fmt"
 	"io"
+	"math"
+	"math/big"
 	"strings"
 
 	"github.com/jesseduffield/OK/ok/ast"
+	"github.com/jesseduffield/OK/ok/diagnostic"
 	"github.com/jesseduffield/OK/ok/object"
+	"github.com/jesseduffield/OK/ok/token"
 )
 
 type Evaluator struct {
-	out io.Writer
+	out     io.Writer
+	modules *modules
 
 	location string
+	span     token.Span
 }
 
 func New(out io.Writer) *Evaluator {
-	return &Evaluator{out: out}
+	return &Evaluator{out: out, modules: newModules()}
 }
 
 func (e *Evaluator) Eval(node ast.Node, env *object.Environment) object.Object {
@@ -25,7 +31,14 @@ func (e *Evaluator) Eval(node ast.Node, env *object.Environment) object.Object {
 	// I need to call Eval on the left and right side, but I don't want that to
 	// affect my location if I'm reporting an error for the infix expression as
 	// a whole
-	newEvaluator := &Evaluator{out: e.out}
+	newEvaluator := e.at(node)
+
+	return newEvaluator.evalAux(node, env)
+}
+
+// at returns a copy of the evaluator which reports errors at the given node
+func (e *Evaluator) at(node ast.Node) *Evaluator {
+	newEvaluator := &Evaluator{out: e.out, modules: e.modules}
 
 	if node != nil {
 		newEvaluator.location = fmt.Sprintf(
@@ -33,9 +46,10 @@ func (e *Evaluator) Eval(node ast.Node, env *object.Environment) object.Object {
 			node.GetToken().Location(),
 			node.GetToken().Literal,
 		)
+		newEvaluator.span = node.Span()
 	}
 
-	return newEvaluator.evalAux(node, env)
+	return newEvaluator
 }
 
 func (e *Evaluator) evalAux(node ast.Node, env *object.Environment) object.Object {
@@ -50,8 +64,14 @@ func (e *Evaluator) evalAux(node ast.Node, env *object.Environment) object.Objec
 
 	// Expressions
 	case *ast.IntegerLiteral:
+		if node.BigValue != nil {
+			return &object.BigInteger{Value: node.BigValue}
+		}
 		return &object.Integer{Value: node.Value}
 
+	case *ast.FloatLiteral:
+		return &object.Float{Value: node.Value}
+
 	case *ast.Boolean:
 		return nativeBoolToBooleanObject(node.Value)
 
@@ -129,15 +149,30 @@ func (e *Evaluator) evalAux(node ast.Node, env *object.Environment) object.Objec
 		if isError(val) {
 			return val
 		}
+		if node.Pattern != nil {
+			return e.evalDestructuring(node.Pattern, val, env)
+		}
+		if err := e.checkNotANac(node.Name, node.Name.Value, env); err != nil {
+			return err
+		}
 		env.Set(node.Name.Value, val)
 
+	case *ast.ImportStatement:
+		return e.evalImportStatement(node, env)
+
 	case *ast.Identifier:
 		return e.evalIdentifier(node, env)
 
 	case *ast.FunctionLiteral:
 		params := node.Parameters
 		body := node.Body
-		return &object.Function{Parameters: params, Env: env, Body: body}
+		return &object.Function{
+			Parameters: params,
+			Env:        env,
+			Body:       body,
+			Name:       node.Name,
+			Token:      node.Token,
+		}
 
 	case *ast.CallExpression:
 		function := e.Eval(node.Function, env)
@@ -154,6 +189,9 @@ func (e *Evaluator) evalAux(node ast.Node, env *object.Environment) object.Objec
 	case *ast.StringLiteral:
 		return &object.String{Value: node.Value}
 
+	case *ast.InterpolatedString:
+		return e.evalInterpolatedString(node, env)
+
 	case *ast.ArrayLiteral:
 		elements := e.evalExpressions(node.Elements, env)
 		if len(elements) == 1 && isError(elements[0]) {
@@ -178,6 +216,9 @@ func (e *Evaluator) evalAux(node ast.Node, env *object.Environment) object.Objec
 	case *ast.Struct:
 		return e.evalStructDefinition(node, env)
 
+	case *ast.Interface:
+		return e.evalInterfaceDefinition(node, env)
+
 	case *ast.StructInstantiation:
 		return e.evalStructInstantiation(node, env)
 
@@ -191,7 +232,12 @@ func (e *Evaluator) evalAux(node ast.Node, env *object.Environment) object.Objec
 		return e.evalLazyExpression(node)
 
 	case *ast.CommentStatement:
-		return e.evalCommentStatement(node, env)
+		// acknowledgements are picked up by whoever evaluates the statement
+		// after the comment. See acknowledge
+		return object.NULL
+
+	case *ast.DocComment:
+		return object.NULL
 
 	case nil:
 		// TODO: I'm not actually sure why this would ever be nil. Might need to investigate
@@ -204,17 +250,50 @@ func (e *Evaluator) evalAux(node ast.Node, env *object.Environment) object.Objec
 	return nil
 }
 
-func (e *Evaluator) evalCommentStatement(
-	node *ast.CommentStatement,
+// acknowledge records the 'I acknowledge that ...' comments directly above the
+// statement at the given index, so that they cover that statement alone
+func (e *Evaluator) acknowledge(
+	statements []ast.Statement,
+	index int,
 	env *object.Environment,
-) object.Object {
+) {
+	statement := statements[index]
+	if _, ok := statement.(*ast.CommentStatement); ok {
+		return
+	}
+
 	acknowledgePrefix := "I acknowledge that "
-	if strings.HasPrefix(node.Text, acknowledgePrefix) {
-		text := strings.TrimPrefix(node.Text, acknowledgePrefix)
-		env.AddAcknowledgement(text)
+	for i := index - 1; i >= 0; i-- {
+		comment, ok := statements[i].(*ast.CommentStatement)
+		if !ok {
+			break
+		}
+		if strings.HasPrefix(comment.Text, acknowledgePrefix) {
+			text := strings.TrimPrefix(comment.Text, acknowledgePrefix)
+			env.AddAcknowledgement(text, statement.Span())
+		}
 	}
+}
 
-	return object.NULL
+func (e *Evaluator) evalInterpolatedString(
+	node *ast.InterpolatedString,
+	env *object.Environment,
+) object.Object {
+	var out strings.Builder
+
+	for i, segment := range node.Segments {
+		out.WriteString(segment)
+
+		if i < len(node.Expressions) {
+			value := e.Eval(node.Expressions[i], env)
+			if isError(value) {
+				return value
+			}
+			out.WriteString(value.Inspect())
+		}
+	}
+
+	return &object.String{Value: out.String()}
 }
 
 func (e *Evaluator) evalLazyExpression(node *ast.LazyExpression) object.Object {
@@ -229,43 +308,115 @@ func (e *Evaluator) evalStructMemberAccess(
 	env *object.Environment,
 ) object.Object {
 	left := e.Eval(node.Left, env)
+	if isError(left) {
+		return left
+	}
+	if module, ok := left.(*object.Module); ok {
+		return e.evalModuleMemberAccess(module, node)
+	}
+
 	structInstance, ok := left.(*object.StructInstance)
 	if !ok {
-		return e.newError(fmt.Sprintf("`%s` is not a nac", node.Left.String()))
+		return e.newError(diagnostic.UndefinedMember, "`%s` is not a nac", node.Left.String())
 	}
 
 	if structInstance.IsField(node.MemberName) {
 		if !structInstance.IsPublicField(node.MemberName) &&
 			!env.IsCurrentStructInstance(structInstance) &&
-			!env.AllowsPrivateAccess(structInstance.Struct) {
+			!env.AllowsPrivateAccess(structInstance.Struct, node.MemberName, node.Span()) {
 			return e.newError(
-				fmt.Sprintf(
-					"`%s` is a private field on nac %s",
-					node.MemberName,
-					structInstance.Struct.Name,
-				),
+				diagnostic.PrivateAccess,
+				"`%s` is a private field on nac %s",
+				node.MemberName,
+				structInstance.Struct.Name,
 			)
 		}
 		return structInstance.GetFieldValue(node.MemberName)
 	} else if structInstance.IsMethod(node.MemberName) {
-		if !structInstance.IsPublicMethod(node.MemberName) && !env.IsCurrentStructInstance(structInstance) && !env.AllowsPrivateAccess(structInstance.Struct) {
-			return e.newError(fmt.Sprintf("`%s` is a private method on nac %s", node.MemberName, structInstance.Struct.Name))
+		if !structInstance.IsPublicMethod(node.MemberName) && !env.IsCurrentStructInstance(structInstance) && !env.AllowsPrivateAccess(structInstance.Struct, node.MemberName, node.Span()) {
+			return e.newError(diagnostic.PrivateAccess, "`%s` is a private method on nac %s", node.MemberName, structInstance.Struct.Name)
 		}
 		return structInstance.GetMethod(node.MemberName)
 	} else {
-		return e.newError(fmt.Sprintf("undefined field for nac %s: %s", structInstance.Struct.Name, node.MemberName))
+		return e.newError(diagnostic.UndefinedMember, "undefined field for nac %s: %s", structInstance.Struct.Name, node.MemberName)
 	}
 }
 
-// TODO: support referring to global variables from within a struct
 func (e *Evaluator) evalStructDefinition(
 	structDef *ast.Struct,
 	env *object.Environment,
 ) object.Object {
+	if err := e.checkImplements(structDef, env); err != nil {
+		return err
+	}
+	if err := e.checkCarries(structDef, env); err != nil {
+		return err
+	}
+	if err := e.checkPacks(structDef); err != nil {
+		return err
+	}
+	if err := e.checkPendingCarries(structDef, env); err != nil {
+		return err
+	}
+	// otherwise the nac would shadow the variable or the variable would shadow
+	// the nac, depending on where you looked from, and neither is any good
+	if env.HasVariable(structDef.Name) {
+		return e.at(structDef).newError(
+			diagnostic.Redeclaration,
+			"cannot define notaclass %s: %s has already been declared",
+			structDef.Name,
+			structDef.Name,
+		)
+	}
+
 	env.SetStruct(structDef)
 	return object.NULL
 }
 
+// checkNotANac makes sure we're not declaring a variable with the name of a nac
+// defined in the same scope. See evalStructDefinition.
+func (e *Evaluator) checkNotANac(node ast.Node, name string, env *object.Environment) object.Object {
+	if !env.HasStruct(name) {
+		return nil
+	}
+
+	return e.at(node).newError(
+		diagnostic.Redeclaration,
+		"cannot declare %s: notaclass %s has already been defined",
+		name,
+		name,
+	)
+}
+
+// checkPacks makes sure each of a nac's field packs is for a field that
+// exists, and that no field has two
+func (e *Evaluator) checkPacks(structDef *ast.Struct) object.Object {
+	seen := map[string]bool{}
+	for _, pack := range structDef.Packs {
+		field := pack.Field.Value
+		if !hasField(structDef, field) {
+			return e.at(pack.Field).newError(
+				diagnostic.InvalidPack,
+				"notaclass %s has a pack for %s, but no field %s",
+				structDef.Name,
+				field,
+				field,
+			)
+		}
+		if seen[field] {
+			return e.at(pack.Field).newError(
+				diagnostic.InvalidPack,
+				"notaclass %s has more than one pack for %s",
+				structDef.Name,
+				field,
+			)
+		}
+		seen[field] = true
+	}
+
+	return nil
+}
+
 func (e *Evaluator) evalStructInstantiation(
 	node *ast.StructInstantiation,
 	env *object.Environment,
@@ -273,14 +424,48 @@ func (e *Evaluator) evalStructInstantiation(
 	instance := &object.StructInstance{}
 	instance.Fields = make(map[string]object.Object)
 	// need to find the struct in our env
-	tmp, ok := env.GetStruct(node.StructName)
-	if !ok {
-		return e.newError(fmt.Sprintf("undefined nac %s", node.StructName))
+	str, err := e.lookupStruct(node, env)
+	if err != nil {
+		return err
+	}
+
+	instance.Struct = str.Definition
+	instance.Env = str.Env
+
+	for _, field := range str.Definition.Fields {
+		if field.Default == nil {
+			continue
+		}
+		value := e.Eval(field.Default, str.Env)
+		if isError(value) {
+			return value
+		}
+		instance.Fields[field.Name] = value
 	}
 
-	instance.Struct = tmp
+	// we only call init if we've been given arguments for it, so that
+	// 'new person()' followed by 'p.init(...)' still works
+	if len(node.Arguments) == 0 {
+		return instance
+	}
 
-	// ignoring fields for now
+	if !instance.IsMethod("init") {
+		return e.newError(
+			diagnostic.InvalidArgument,
+			"notaclass %s has no init method to pass arguments to. Either define one, or call new %s() with no arguments",
+			instance.Struct.Name,
+			instance.Struct.Name,
+		)
+	}
+
+	args := e.evalExpressions(node.Arguments, env)
+	if len(args) == 1 && isError(args[0]) {
+		return args[0]
+	}
+
+	if result := e.applyFunction(instance.GetMethod("init"), args, env); isError(result) {
+		return result
+	}
 
 	return instance
 }
@@ -305,17 +490,25 @@ func (e *Evaluator) evalExpressions(
 func (e *Evaluator) evalProgram(program *ast.Program, env *object.Environment) object.Object {
 	var result object.Object
 
-	for _, statement := range program.Statements {
+	for i, statement := range program.Statements {
+		e.acknowledge(program.Statements, i, env)
 		result = e.Eval(statement, env)
 
 		switch result := result.(type) {
 		case *object.ReturnValue:
+			if err := e.checkUnresolvedCarries(env); err != nil {
+				return err
+			}
 			return result.Value
 		case *object.Error:
 			return result
 		}
 	}
 
+	if err := e.checkUnresolvedCarries(env); err != nil {
+		return err
+	}
+
 	return result
 }
 
@@ -325,17 +518,25 @@ func (e *Evaluator) evalBlockStatement(
 ) object.Object {
 	var result object.Object
 
-	for _, statement := range block.Statements {
+	for i, statement := range block.Statements {
+		e.acknowledge(block.Statements, i, env)
 		result = e.Eval(statement, env)
 
 		if result != nil {
 			rt := result.Type()
-			if rt == object.RETURN_VALUE_OBJ || rt == object.ERROR_OBJ {
+			if rt == object.ERROR_OBJ {
 				return result
 			}
+			if rt == object.RETURN_VALUE_OBJ {
+				break
+			}
 		}
 	}
 
+	if err := e.checkUnresolvedCarries(env); err != nil {
+		return err
+	}
+
 	return result
 }
 
@@ -367,7 +568,7 @@ func (e *Evaluator) evalPrefixExpression(
 	case "lazy":
 		return &object.LazyObject{Right: rightNode}
 	default:
-		return e.newError("unknown operator: %s for %s", operator, rightNode.String())
+		return e.newError(diagnostic.UnknownOperator, "unknown operator: %s for %s", operator, rightNode.String())
 	}
 }
 
@@ -385,12 +586,19 @@ func (e *Evaluator) evalBangOperatorExpression(right object.Object) object.Objec
 }
 
 func (e *Evaluator) evalMinusPrefixOperatorExpression(right object.Object) object.Object {
-	if right.Type() != object.INTEGER_OBJ {
-		return e.newError("unknown operator: -%s", right.Type())
+	switch right := right.(type) {
+	case *object.Integer:
+		if right.Value == math.MinInt64 {
+			return object.NewInteger(new(big.Int).Neg(object.ToBigInt(right)))
+		}
+		return &object.Integer{Value: -right.Value}
+	case *object.BigInteger:
+		return object.NewInteger(new(big.Int).Neg(right.Value))
+	case *object.Float:
+		return &object.Float{Value: -right.Value}
+	default:
+		return e.newError(diagnostic.UnknownOperator, "unknown operator: -%s", typeName(right))
 	}
-
-	value := right.(*object.Integer).Value
-	return &object.Integer{Value: -value}
 }
 
 func (e *Evaluator) evalInfixExpression(
@@ -400,6 +608,9 @@ func (e *Evaluator) evalInfixExpression(
 	switch {
 	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
 		return e.evalIntegerInfixExpression(operator, left, right)
+	case isNumeric(left) && isNumeric(right):
+		// at least one side is a float so we'll treat both as floats
+		return e.evalFloatInfixExpression(operator, left, right)
 	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
 		return e.evalStringInfixExpression(operator, left, right)
 	case operator == ">=":
@@ -410,11 +621,11 @@ func (e *Evaluator) evalInfixExpression(
 		// this is allowed internally but illegal in the lexer
 		return nativeBoolToBooleanObject(left == right)
 	case left.Type() != right.Type():
-		return e.newError("type mismatch: %s %s %s",
-			left.Type(), operator, right.Type())
+		return e.newError(diagnostic.TypeMismatch, "type mismatch: %s %s %s",
+			typeName(left), operator, typeName(right))
 	default:
-		return e.newError("unknown operator: %s %s %s",
-			left.Type(), operator, right.Type())
+		return e.newError(diagnostic.UnknownOperator, "unknown operator: %s %s %s",
+			typeName(left), operator, typeName(right))
 	}
 }
 
@@ -432,7 +643,7 @@ func (e *Evaluator) evalAssignmentExpression(
 	case *ast.Identifier:
 		obj, err := env.Assign(v.Value, val)
 		if err != nil {
-			return e.newError(err.Error())
+			return e.newError(diagnostic.UndeclaredIdentifier, "%s", err.Error())
 		}
 		return obj
 	case *ast.IndexExpression:
@@ -450,26 +661,32 @@ func (e *Evaluator) evalAssignmentExpression(
 		case *object.Array:
 			indexVal, ok := key.(*object.Integer)
 			if !ok {
-				return e.newError("Index must be an integer")
+				if key.Type() == object.INTEGER_OBJ {
+					// must be a BigInteger, which is never going to be in bounds
+					return e.newError(diagnostic.IndexOutOfBounds, "Index %s is out of bounds (array length %d)", key.Inspect(), len(l.Elements))
+				}
+				return e.newError(diagnostic.InvalidIndex, "Index must be an integer")
 			}
 			if indexVal.Value < 0 {
-				return e.newError("Index must be positive")
+				return e.newError(diagnostic.InvalidIndex, "Index must be positive")
 			}
 			if int(indexVal.Value) > len(l.Elements)-1 {
-				return e.newError(fmt.Sprintf("Index %d is out of bounds (array length %d)", indexVal.Value, len(l.Elements)))
+				return e.newError(diagnostic.IndexOutOfBounds, "Index %d is out of bounds (array length %d)", indexVal.Value, len(l.Elements))
 			}
 			l.Elements[indexVal.Value] = val
 		case *object.Hash:
 			hashKey, ok := key.(object.Hashable)
 			if !ok {
-				return e.newError("Unusable as hash key: %s", key.Type())
+				return e.newError(diagnostic.InvalidIndex, "Unusable as hash key: %s", typeName(key))
 			}
 
-			l.Pairs[hashKey.HashKey()] = object.HashPair{Key: key, Value: val}
+			l.Set(hashKey.HashKey(), object.HashPair{Key: key, Value: val})
+		case *object.StructInstance:
+			return e.newError(diagnostic.InvalidIndex, "cannot index into %s: nacs don't have keys, so use its methods instead", typeName(l))
 		case *object.Null:
-			return e.newError("Attempted index of NULL object")
+			return e.newError(diagnostic.InvalidIndex, "Attempted index of NULL object")
 		default:
-			return e.newError(fmt.Sprintf("`%s` is neither a hash nor array so you cannot index into it", v.Left.String()))
+			return e.newError(diagnostic.InvalidIndex, "`%s` is neither a hash nor array so you cannot index into it", v.Left.String())
 		}
 	case *ast.StructMemberAccessExpression:
 		leftVal := e.Eval(v.Left, env)
@@ -477,22 +694,26 @@ func (e *Evaluator) evalAssignmentExpression(
 			return leftVal
 		}
 
+		if module, ok := leftVal.(*object.Module); ok {
+			return e.newError(diagnostic.InvalidAssignment, "cannot assign to `%s`: bindings in module %s can only be changed from inside the module", v.MemberName, module.Name)
+		}
+
 		structInstance, ok := leftVal.(*object.StructInstance)
 		if !ok {
-			return e.newError(fmt.Sprintf("`%s` is not a nac instance", v.Left.String()))
+			return e.newError(diagnostic.UndefinedMember, "`%s` is not a nac instance", v.Left.String())
 		}
 
 		if structInstance.IsMethod(v.MemberName) {
-			return e.newError(fmt.Sprintf("`%s` is a method, not a field, on nac %s. You cannot reassign it", v.MemberName, structInstance.Struct.Name))
+			return e.newError(diagnostic.InvalidAssignment, "`%s` is a method, not a field, on nac %s. You cannot reassign it", v.MemberName, structInstance.Struct.Name)
 		}
-		if !structInstance.IsPublicField(v.MemberName) && !env.IsCurrentStructInstance(structInstance) && !env.AllowsPrivateAccess(structInstance.Struct) {
-			return e.newError(fmt.Sprintf("`%s` is a private field on nac %s", v.MemberName, structInstance.Struct.Name))
+		if !structInstance.IsPublicField(v.MemberName) && !env.IsCurrentStructInstance(structInstance) && !env.AllowsPrivateAccess(structInstance.Struct, v.MemberName, v.Span()) {
+			return e.newError(diagnostic.PrivateAccess, "`%s` is a private field on nac %s", v.MemberName, structInstance.Struct.Name)
 		}
 
 		structInstance.SetFieldValue(v.MemberName, val)
 
 	default:
-		return e.newError("LHS must be an identifier or index expression")
+		return e.newError(diagnostic.InvalidAssignment, "LHS must be an identifier or index expression")
 	}
 
 	return val
@@ -517,8 +738,8 @@ func (e *Evaluator) evalStringInfixExpression(
 		// this is allowed internally but illegal in the lexer
 		return nativeBoolToBooleanObject(leftVal == rightVal)
 	default:
-		return e.newError("unknown operator: %s %s %s",
-			left.Type(), operator, right.Type())
+		return e.newError(diagnostic.UnknownOperator, "unknown operator: %s %s %s",
+			typeName(left), operator, typeName(right))
 	}
 }
 
@@ -526,32 +747,164 @@ func (e *Evaluator) evalIntegerInfixExpression(
 	operator string,
 	left, right object.Object,
 ) object.Object {
-	leftVal := left.(*object.Integer).Value
-	rightVal := right.(*object.Integer).Value
+	leftInt, leftOk := left.(*object.Integer)
+	rightInt, rightOk := right.(*object.Integer)
+	if !leftOk || !rightOk {
+		return e.evalBigIntegerInfixExpression(operator, left, right)
+	}
+
+	leftVal := leftInt.Value
+	rightVal := rightInt.Value
+
+	switch operator {
+	case "+":
+		result := leftVal + rightVal
+		// overflow has occurred if both operands have the same sign and the result doesn't
+		if (leftVal >= 0) == (rightVal >= 0) && (result >= 0) != (leftVal >= 0) {
+			return e.evalBigIntegerInfixExpression(operator, left, right)
+		}
+		return &object.Integer{Value: result}
+	case "-":
+		result := leftVal - rightVal
+		if (leftVal >= 0) != (rightVal >= 0) && (result >= 0) != (leftVal >= 0) {
+			return e.evalBigIntegerInfixExpression(operator, left, right)
+		}
+		return &object.Integer{Value: result}
+	case "*":
+		result := leftVal * rightVal
+		if leftVal != 0 && (result/leftVal != rightVal || (leftVal == -1 && rightVal == math.MinInt64)) {
+			return e.evalBigIntegerInfixExpression(operator, left, right)
+		}
+		return &object.Integer{Value: result}
+	case "/", "%":
+		if rightVal == 0 {
+			return e.newDivisionByZeroError(operator)
+		}
+		if leftVal == math.MinInt64 && rightVal == -1 {
+			return e.evalBigIntegerInfixExpression(operator, left, right)
+		}
+		if operator == "/" {
+			return &object.Integer{Value: leftVal / rightVal}
+		}
+		return &object.Integer{Value: leftVal % rightVal}
+	case ">=":
+		return nativeBoolToBooleanObject(leftVal >= rightVal)
+	case "==":
+		// this is allowed internally but illegal in the lexer
+		return nativeBoolToBooleanObject(leftVal == rightVal)
+	default:
+		return e.newError(diagnostic.UnknownOperator, "unknown operator: %s %s %s",
+			typeName(left), operator, typeName(right))
+	}
+}
+
+// this is for when either side is too big for an int64, or would be after
+// the operation is applied
+func (e *Evaluator) evalBigIntegerInfixExpression(
+	operator string,
+	left, right object.Object,
+) object.Object {
+	leftVal := object.ToBigInt(left)
+	rightVal := object.ToBigInt(right)
 
 	switch operator {
 	case "+":
-		return &object.Integer{Value: leftVal + rightVal}
+		return object.NewInteger(new(big.Int).Add(leftVal, rightVal))
 	case "-":
-		return &object.Integer{Value: leftVal - rightVal}
+		return object.NewInteger(new(big.Int).Sub(leftVal, rightVal))
 	case "*":
-		return &object.Integer{Value: leftVal * rightVal}
+		return object.NewInteger(new(big.Int).Mul(leftVal, rightVal))
+	case "/", "%":
+		if rightVal.Sign() == 0 {
+			return e.newDivisionByZeroError(operator)
+		}
+		// Quo and Rem truncate towards zero, same as Go's int64 operators
+		if operator == "/" {
+			return object.NewInteger(new(big.Int).Quo(leftVal, rightVal))
+		}
+		return object.NewInteger(new(big.Int).Rem(leftVal, rightVal))
+	case ">=":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) >= 0)
+	case "==":
+		// this is allowed internally but illegal in the lexer
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) == 0)
+	default:
+		return e.newError(diagnostic.UnknownOperator, "unknown operator: %s %s %s",
+			typeName(left), operator, typeName(right))
+	}
+}
+
+func (e *Evaluator) newDivisionByZeroError(operator string) *object.Error {
+	if operator == "%" {
+		return e.newError(diagnostic.DivisionByZero, "modulo by zero")
+	}
+
+	return e.newError(diagnostic.DivisionByZero, "division by zero")
+}
+
+func (e *Evaluator) evalFloatInfixExpression(
+	operator string,
+	left, right object.Object,
+) object.Object {
+	leftVal := toFloat(left)
+	rightVal := toFloat(right)
+
+	switch operator {
+	case "+":
+		return &object.Float{Value: leftVal + rightVal}
+	case "-":
+		return &object.Float{Value: leftVal - rightVal}
+	case "*":
+		return &object.Float{Value: leftVal * rightVal}
 	case "/":
-		return &object.Integer{Value: leftVal / rightVal}
+		if rightVal == 0 {
+			return e.newDivisionByZeroError(operator)
+		}
+		return &object.Float{Value: leftVal / rightVal}
+	case "%":
+		if rightVal == 0 {
+			return e.newDivisionByZeroError(operator)
+		}
+		return &object.Float{Value: math.Mod(leftVal, rightVal)}
 	case ">=":
 		return nativeBoolToBooleanObject(leftVal >= rightVal)
 	case "==":
 		// this is allowed internally but illegal in the lexer
 		return nativeBoolToBooleanObject(leftVal == rightVal)
 	default:
-		return e.newError("unknown operator: %s %s %s",
-			left.Type(), operator, right.Type())
+		return e.newError(diagnostic.UnknownOperator, "unknown operator: %s %s %s",
+			typeName(left), operator, typeName(right))
+	}
+}
+
+func isNumeric(obj object.Object) bool {
+	return obj.Type() == object.INTEGER_OBJ || obj.Type() == object.FLOAT_OBJ
+}
+
+// assumes we've already checked that the object is numeric
+func toFloat(obj object.Object) float64 {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return float64(obj.Value)
+	case *object.BigInteger:
+		value, _ := new(big.Float).SetInt(obj.Value).Float64()
+		return value
+	case *object.Float:
+		return obj.Value
+	default:
+		return 0
 	}
 }
 
-func (e *Evaluator) newError(format string, a ...interface{}) *object.Error {
-	str := fmt.Sprintf(format, a...)
-	return object.NewError(fmt.Sprintf("%s: %s", e.location, str))
+func (e *Evaluator) newError(code diagnostic.Code, format string, a ...interface{}) *object.Error {
+	return object.NewDiagnosticError(diagnostic.Diagnostic{
+		Severity: diagnostic.Error,
+		Code:     code,
+		Span:     e.span,
+		Location: e.location,
+		Message:  fmt.Sprintf(format, a...),
+		Link:     code.Link(),
+	})
 }
 
 func (e *Evaluator) evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Object {
@@ -561,9 +914,9 @@ func (e *Evaluator) evalIfExpression(ie *ast.IfExpression, env *object.Environme
 	}
 
 	if isTruthy(condition) {
-		return e.Eval(ie.Consequence, env)
+		return e.Eval(ie.Consequence, object.NewBlockEnvironment(env))
 	} else if ie.Alternative != nil {
-		return e.Eval(ie.Alternative, env)
+		return e.Eval(ie.Alternative, object.NewBlockEnvironment(env))
 	} else {
 		return object.NULL
 	}
@@ -579,19 +932,26 @@ func (e *Evaluator) evalSwitchExpression(
 	}
 
 	for _, c := range se.Cases {
-		value := e.Eval(c.Value, env)
-		if value.Type() != subject.Type() {
-			return e.newError("mismatched types in switch statement: %s %s",
-				subject.Type(), value.Type())
-		}
-		test := e.evalInfixExpression("==", subject, value)
-		if test == object.TRUE {
-			return e.Eval(c.Block, env)
+		for _, value := range c.Values {
+			bindings, matched, err := e.matchCase(value, subject, env)
+			if err != nil {
+				return err
+			}
+			if !matched {
+				continue
+			}
+
+			// bindings only exist for the case's statement
+			blockEnv := object.NewBlockEnvironment(env)
+			for _, b := range bindings {
+				blockEnv.Bind(b.name, b.value)
+			}
+			return e.Eval(c.Block, blockEnv)
 		}
 	}
 
 	if se.Default != nil {
-		return e.Eval(se.Default, env)
+		return e.Eval(se.Default, object.NewBlockEnvironment(env))
 	}
 
 	return object.NULL
@@ -610,6 +970,22 @@ func isTruthy(obj object.Object) bool {
 	}
 }
 
+// typeName describes an object's type for error messages. Instances are
+// described by their nac, because 'NAC' on its own doesn't tell you much, and
+// nacs and notaninterfaces are described by name.
+func typeName(obj object.Object) string {
+	switch obj := obj.(type) {
+	case *object.StructInstance:
+		return "notaclass " + obj.Struct.Name
+	case *object.Struct:
+		return "notaclass " + obj.Definition.Name + " (the nac itself)"
+	case *object.Interface:
+		return "notaninterface " + obj.Definition.Name
+	default:
+		return string(obj.Type())
+	}
+}
+
 func isError(obj object.Object) bool {
 	if obj != nil {
 		return obj.Type() == object.ERROR_OBJ
@@ -635,11 +1011,14 @@ func (e *Evaluator) evalIdentifier(
 		return builtin
 	}
 
-	return e.newError("identifier not found: " + node.Value)
+	return e.newError(diagnostic.UndeclaredIdentifier, "identifier not found: "+node.Value)
 }
 
 func (e *Evaluator) applyUserFunction(fn *object.Function, args []object.Object) object.Object {
-	extendedEnv := e.extendFunctionEnv(fn, args)
+	extendedEnv, err := e.extendFunctionEnv(fn, args)
+	if err != nil {
+		return err
+	}
 	evaluated := e.Eval(fn.Body, extendedEnv)
 	return unwrapReturnValue(evaluated)
 }
@@ -655,10 +1034,13 @@ func (e *Evaluator) applyFunction(
 		return e.applyUserFunction(fn, args)
 
 	case *object.Method:
-		newEnv := e.createMethodEnv(fn, args, env)
+		newEnv, err := e.createMethodEnv(fn, args)
+		if err != nil {
+			return err
+		}
 		evaluated := e.Eval(fn.StructMethod.FunctionLiteral.Body, newEnv)
 
-		if err := e.handleEvolve(fn.StructInstance, env); err != nil {
+		if err := e.handleEvolve(fn.StructInstance); err != nil {
 			return err
 		}
 
@@ -668,72 +1050,181 @@ func (e *Evaluator) applyFunction(
 		return fn.Fn(args...)
 
 	default:
-		return e.newError("not a function: %s", fn.Type())
+		return e.newError(diagnostic.NotAFunction, "not a function: %s", typeName(fn))
 	}
 }
 
-func (e *Evaluator) handleEvolve(
-	instance *object.StructInstance,
-	env *object.Environment,
-) object.Object {
-	if instance.IsMethod("evolve") {
+func (e *Evaluator) handleEvolve(instance *object.StructInstance) object.Object {
+	if instance.IsMethod("evolve") && !instance.Fossil {
 		evolveMethod := instance.GetMethod("evolve").(*object.Method)
-		newEnv := e.createMethodEnv(evolveMethod, []object.Object{}, env)
+		newEnv, err := e.createMethodEnv(evolveMethod, []object.Object{})
+		if err != nil {
+			return err
+		}
 		other := e.Eval(evolveMethod.StructMethod.FunctionLiteral.Body, newEnv)
 		other = unwrapReturnValue(other)
-		if other.Type() != object.NULL_OBJ {
+		// a bare 'return;' or an empty body gives us nothing at all, which we
+		// take to mean the same as NO!
+		if other != nil && other.Type() != object.NULL_OBJ {
 			new, ok := other.(*object.StructInstance)
 			if !ok {
 				return e.newError(
+					diagnostic.InvalidEvolution,
 					"evolve method must return NO! or a nac instance, returned %s: %s",
 					other.Type(),
 					other.Inspect(),
 				)
 			}
-			instance.EvolveInto(new)
+			if err := e.carryFields(instance, new); err != nil {
+				return err
+			}
+
+			fossil := instance.EvolveInto(new, e.span.Location())
+
+			if err := e.callOnEvolve(instance, fossil); err != nil {
+				return err
+			}
 		}
 	}
 
 	return nil
 }
 
+// callOnEvolve calls the onevolve method of the nac we've just evolved into, if
+// it has one, passing what we were before so that it can bring state across
+func (e *Evaluator) callOnEvolve(
+	instance *object.StructInstance,
+	fossil *object.StructInstance,
+) object.Object {
+	if !instance.IsMethod("onevolve") {
+		return nil
+	}
+
+	onEvolve := instance.GetMethod("onevolve").(*object.Method)
+	newEnv, err := e.createMethodEnv(onEvolve, []object.Object{fossil})
+	if err != nil {
+		return err
+	}
+	// the fossil is who we used to be, so its private fields aren't off limits
+	newEnv = object.NewEnclosedEnvironment(newEnv)
+	newEnv.SetCurrentStructInstance(fossil)
+
+	result := e.Eval(onEvolve.StructMethod.FunctionLiteral.Body, newEnv)
+	if isError(result) {
+		return result
+	}
+
+	return nil
+}
+
+// createMethodEnv is the method equivalent of extendFunctionEnv: the method
+// closes over the scope its nac was defined in
 func (e *Evaluator) createMethodEnv(
 	method *object.Method,
 	args []object.Object,
-	env *object.Environment,
-) *object.Environment {
-	newEnv := object.OnlyStructs(env)
+) (*object.Environment, *object.Error) {
+	newEnv := object.NewEnclosedEnvironment(method.Env)
 
 	functionLiteral := method.StructMethod.FunctionLiteral
+	params := functionLiteral.Parameters
 	// if the first arg is 'selfish' we need to pass in the struct instance for that
-	if len(functionLiteral.Parameters) > 0 && functionLiteral.Parameters[0].Value == "selfish" {
+	if hasSelfish(params) {
 		newEnv.Set("selfish", method.StructInstance)
-
-		for paramIdx, param := range functionLiteral.Parameters[1:] {
-			newEnv.Set(param.Value, args[paramIdx])
-		}
-	} else {
-		for paramIdx, param := range functionLiteral.Parameters {
-			newEnv.Set(param.Value, args[paramIdx])
-		}
+		params = params[1:]
 	}
 
 	newEnv.SetCurrentStructInstance(method.StructInstance)
 
-	return newEnv
+	name := fmt.Sprintf("%s.%s", method.StructInstance.Struct.Name, method.Name)
+	if err := e.bindArguments(name, functionLiteral.Token, params, args, newEnv); err != nil {
+		return nil, err
+	}
+
+	return newEnv, nil
+}
+
+func hasSelfish(params []*ast.Parameter) bool {
+	return len(params) > 0 && params[0].Name.Value == "selfish"
 }
 
 func (e *Evaluator) extendFunctionEnv(
 	fn *object.Function,
 	args []object.Object,
-) *object.Environment {
+) (*object.Environment, *object.Error) {
 	env := object.NewEnclosedEnvironment(fn.Env)
 
-	for paramIdx, param := range fn.Parameters {
-		env.Set(param.Value, args[paramIdx])
+	if err := e.bindArguments(fn.Name, fn.Token, fn.Parameters, args, env); err != nil {
+		return nil, err
 	}
 
-	return env
+	return env, nil
+}
+
+// bindArguments sets each parameter in env to its argument. Defaults are
+// evaluated in env, so a default can refer to the parameters before it. A rest
+// parameter gets an array of whatever arguments are left over. name and
+// definedAt are only used to explain a wrong number of arguments.
+func (e *Evaluator) bindArguments(
+	name string,
+	definedAt token.Token,
+	params []*ast.Parameter,
+	args []object.Object,
+	env *object.Environment,
+) *object.Error {
+	min, max := ast.Arity(params)
+	if len(args) < min || (max != -1 && len(args) > max) {
+		return e.arityError(name, definedAt, min, max, len(args))
+	}
+
+	for i, param := range params {
+		switch {
+		case param.Rest:
+			rest := []object.Object{}
+			if i < len(args) {
+				rest = append(rest, args[i:]...)
+			}
+			env.Set(param.Name.Value, &object.Array{Elements: rest})
+		case i < len(args):
+			env.Set(param.Name.Value, args[i])
+		default:
+			value := e.Eval(param.Default, env)
+			if isError(value) {
+				return value.(*object.Error)
+			}
+			env.Set(param.Name.Value, value)
+		}
+	}
+
+	return nil
+}
+
+func (e *Evaluator) arityError(name string, definedAt token.Token, min int, max int, got int) *object.Error {
+	if name == "" {
+		name = "fn"
+	}
+
+	return e.newError(
+		diagnostic.InvalidArgument,
+		"wrong number of arguments to %s. got=%d, want=%s (%s is defined at %s)",
+		name,
+		got,
+		describeArity(min, max),
+		name,
+		definedAt.Span().Location(),
+	)
+}
+
+// describeArity returns e.g. '2', '1..3', or 'at least 1'. max is -1 if there's
+// no limit.
+func describeArity(min int, max int) string {
+	switch {
+	case max == -1:
+		return fmt.Sprintf("at least %d", min)
+	case min == max:
+		return fmt.Sprintf("%d", min)
+	default:
+		return fmt.Sprintf("%d..%d", min, max)
+	}
 }
 
 func unwrapReturnValue(obj object.Object) object.Object {
@@ -748,16 +1239,25 @@ func (e *Evaluator) evalIndexExpression(left, index object.Object) object.Object
 	switch {
 	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
 		return e.evalArrayIndexExpression(left, index)
+	case left.Type() == object.STRING_OBJ && index.Type() == object.INTEGER_OBJ:
+		return e.evalStringIndexExpression(left, index)
 	case left.Type() == object.HASH_OBJ:
 		return e.evalHashIndexExpression(left, index)
+	case left.Type() == object.NAC_OBJ:
+		return e.newError(diagnostic.InvalidIndex, "cannot index into %s: nacs don't have keys, so use its methods instead", typeName(left))
 	default:
-		return e.newError("index operator not supported: %s", left.Type())
+		return e.newError(diagnostic.InvalidIndex, "index operator not supported: %s", typeName(left))
 	}
 }
 
 func (e *Evaluator) evalArrayIndexExpression(array, index object.Object) object.Object {
 	arrayObject := array.(*object.Array)
-	idx := index.(*object.Integer).Value
+	integer, ok := index.(*object.Integer)
+	if !ok {
+		// must be a BigInteger, which is never going to be in bounds
+		return object.NULL
+	}
+	idx := integer.Value
 	max := int64(len(arrayObject.Elements) - 1)
 
 	if idx < 0 || idx > max {
@@ -767,33 +1267,50 @@ func (e *Evaluator) evalArrayIndexExpression(array, index object.Object) object.
 	return arrayObject.Elements[idx]
 }
 
+// strings are indexed by character (i.e. code point), not by byte
+func (e *Evaluator) evalStringIndexExpression(str, index object.Object) object.Object {
+	chars := []rune(str.(*object.String).Value)
+	integer, ok := index.(*object.Integer)
+	if !ok {
+		// must be a BigInteger, which is never going to be in bounds
+		return object.NULL
+	}
+	idx := integer.Value
+	max := int64(len(chars) - 1)
+
+	if idx < 0 || idx > max {
+		return object.NULL
+	}
+
+	return &object.String{Value: string(chars[idx])}
+}
+
 func (e *Evaluator) evalHashLiteral(
 	node *ast.HashLiteral,
 	env *object.Environment,
 ) object.Object {
-	pairs := make(map[object.HashKey]object.HashPair)
+	hash := object.NewHash()
 
-	for keyNode, valueNode := range node.Pairs {
-		key := e.Eval(keyNode, env)
+	for _, pairNode := range node.Pairs {
+		key := e.Eval(pairNode.Key, env)
 		if isError(key) {
 			return key
 		}
 
 		hashKey, ok := key.(object.Hashable)
 		if !ok {
-			return e.newError("unusable as hash key: %s", key.Type())
+			return e.newError(diagnostic.InvalidIndex, "unusable as hash key: %s", typeName(key))
 		}
 
-		value := e.Eval(valueNode, env)
+		value := e.Eval(pairNode.Value, env)
 		if isError(value) {
 			return value
 		}
 
-		hashed := hashKey.HashKey()
-		pairs[hashed] = object.HashPair{Key: key, Value: value}
+		hash.Set(hashKey.HashKey(), object.HashPair{Key: key, Value: value})
 	}
 
-	return &object.Hash{Pairs: pairs}
+	return hash
 }
 
 func (e *Evaluator) evalHashIndexExpression(hash, index object.Object) object.Object {
@@ -801,10 +1318,10 @@ func (e *Evaluator) evalHashIndexExpression(hash, index object.Object) object.Ob
 
 	key, ok := index.(object.Hashable)
 	if !ok {
-		return e.newError("unusable as hash key: %s", index.Type())
+		return e.newError(diagnostic.InvalidIndex, "unusable as hash key: %s", typeName(index))
 	}
 
-	pair, ok := hashObject.Pairs[key.HashKey()]
+	pair, ok := hashObject.Get(key.HashKey())
 	if !ok {
 		return object.NULL
 	}
@@ -1,10 +1,12 @@
 package evaluator
 
 import (
+	"math"
 	"os"
 	"strings"
 	"testing"
 
+	"github.com/jesseduffield/OK/ok/diagnostic"
 	"github.com/jesseduffield/OK/ok/lexer"
 	"github.com/jesseduffield/OK/ok/object"
 	"github.com/jesseduffield/OK/ok/parser"
@@ -32,6 +34,16 @@ func TestEvalIntegerExpression(t *testing.T) {
 		{"3 * 3 * 3 + 10", 37},
 		{"3 * (3 * 3) + 10", 37},
 		{"(5 + 10 * 2 + 15 / 3) * 2 + -10", 20},
+		{"7 % 3", 1},
+		{"-7 % 3", -1},
+		{"7 / -2", -3},
+		{"0xff + 0b1", 256},
+		{"1_000_000 / 1_000", 1000},
+		{"(9223372036854775807 + 1) - 1", 9223372036854775807},
+		{"-9223372036854775807 - 1", -9223372036854775808},
+		{"99999999999999999999 - 99999999999999999998", 1},
+		{"99999999999999999999 / 99999999999999999999", 1},
+		{"99999999999999999999 % 10", 9},
 	}
 
 	for _, tt := range tests {
@@ -40,6 +52,81 @@ func TestEvalIntegerExpression(t *testing.T) {
 	}
 }
 
+func TestEvalFloatExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"5.5", 5.5},
+		{"-2.5", -2.5},
+		{"1.5 + 1.5", 3},
+		{"1 + 0.5", 1.5},
+		{"0.5 + 1", 1.5},
+		{"10 - 0.25", 9.75},
+		{"2 * 0.5", 1},
+		{"5.0 / 2", 2.5},
+		{"5 / 2.0", 2.5},
+		{"19.99 * 3", 59.97},
+		{"-(1.5 * 2)", -3},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		testFloatObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestIntegerOverflowPromotesToBigInteger(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"9223372036854775807 + 1", "9223372036854775808"},
+		{"-9223372036854775807 - 2", "-9223372036854775809"},
+		{"9223372036854775807 * 2", "18446744073709551614"},
+		{"let x = -9223372036854775807 - 1; -x", "9223372036854775808"},
+		{"let x = -9223372036854775807 - 1; x / -1", "9223372036854775808"},
+		{"99999999999999999999 * 99999999999999999999", "9999999999999999999800000000000000000001"},
+		{"-99999999999999999999", "-99999999999999999999"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		if _, ok := evaluated.(*object.BigInteger); !ok {
+			t.Errorf("object is not BigInteger. got=%T (%+v)", evaluated, evaluated)
+			continue
+		}
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong value for %s. got=%s, want=%s", tt.input, evaluated.Inspect(), tt.expected)
+		}
+	}
+
+	testBooleanObject(t, testEval(t, "99999999999999999999 >= 9223372036854775807"), true)
+	testBooleanObject(t, testEval(t, "-99999999999999999999 >= 1"), false)
+	testIntegerObject(t, testEval(t, "{99999999999999999999: 1}[99999999999999999998 + 1]"), 1)
+	testNullObject(t, testEval(t, "[1, 2][99999999999999999999]"))
+	testBooleanObject(t, testEval(t, "switch 99999999999999999999 { case 99999999999999999999: true; default: false }"), true)
+}
+
+func TestFloatInspect(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"2.5", "2.5"},
+		{"2.0", "2.0"},
+		{"1 * 3.0", "3.0"},
+		{"0.1 + 0.2", "0.30000000000000004"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong Inspect() for %s. got=%s, want=%s", tt.input, evaluated.Inspect(), tt.expected)
+		}
+	}
+}
+
 func testEval(t *testing.T, input string) object.Object {
 	l := lexer.New(input)
 	p := parser.New(l)
@@ -65,6 +152,21 @@ func testIntegerObject(t *testing.T, obj object.Object, expected int64) bool {
 	return true
 }
 
+func testFloatObject(t *testing.T, obj object.Object, expected float64) bool {
+	result, ok := obj.(*object.Float)
+	if !ok {
+		t.Errorf("object is not Float. got=%T (%+v)", obj, obj)
+		return false
+	}
+	if math.Abs(result.Value-expected) > 1e-9 {
+		t.Errorf("object has wrong value. got=%f, want=%f",
+			result.Value, expected)
+		return false
+	}
+
+	return true
+}
+
 // only checks for error inclusion: doesn't check against the entire error
 func testErrorObject(t *testing.T, obj object.Object, expected string) bool {
 	result, ok := obj.(*object.Error)
@@ -126,6 +228,12 @@ func TestEvalBooleanExpression(t *testing.T) {
 		{"let x = 3 >= 2; let y = 5 >= 4; x && y", true},
 		{"switch 1 { case 2: true; default: false }", false},
 		{"switch 1 { case 2: false; default: true }", true},
+		{"1.5 >= 1.5", true},
+		{"1.5 >= 2", false},
+		{"2 >= 1.5", true},
+		{"switch 1.5 { case 1.5: true; default: false }", true},
+		{"switch 2.0 { case 2: true; default: false }", true},
+		{"switch 2 { case 2.5: true; default: false }", false},
 	}
 
 	for _, tt := range tests {
@@ -232,6 +340,14 @@ func TestErrorHandling(t *testing.T) {
 			"-true",
 			"unknown operator: -BOOLEAN",
 		},
+		{
+			"1.5 + true",
+			"type mismatch: FLOAT + BOOLEAN",
+		},
+		{
+			`1.5 + "a"`,
+			"type mismatch: FLOAT + STRING",
+		},
 		{
 			"true + false;",
 			"unknown operator: BOOLEAN + BOOLEAN",
@@ -346,6 +462,74 @@ func TestFunctionApplication(t *testing.T) {
 	}
 }
 
+func TestDefaultAndRestParameters(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let f = fn(a, b = 2) { a + b }; f(1)`, "3"},
+		{`let f = fn(a, b = 2) { a + b }; f(1, 5)`, "6"},
+		{`let f = fn(a, b = a * 10) { b }; f(3)`, "30"},
+		{`let x = 4; let f = fn(a = x) { a }; x = 5; f()`, "5"},
+		{`let f = fn(first, ...rest) { rest }; f(1)`, "[]"},
+		{`let f = fn(first, ...rest) { rest }; f(1, 2, 3)`, "[2, 3]"},
+		{`let f = fn(a = 1, ...rest) { [a, rest] }; f()`, "[1, []]"},
+		{`notaclass counter { public add fn(selfish, n = 1, ...more) { [n, more] } }; new counter().add()`, "[1, []]"},
+		{`notaclass counter { public add fn(selfish, n = 1, ...more) { [n, more] } }; new counter().add(2, 3, 4)`, "[2, [3, 4]]"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestArityErrors(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{
+			"let add = fn(a, b) { a + b };\nadd(1)",
+			"wrong number of arguments to add. got=1, want=2 (add is defined at line 1, column 11)",
+		},
+		{
+			"let add = fn(a, b) { a + b }; add(1, 2, 3)",
+			"wrong number of arguments to add. got=3, want=2 (add is defined at line 1, column 11)",
+		},
+		{
+			"fn(a, b = 1) { a }()",
+			"wrong number of arguments to fn. got=0, want=1..2 (fn is defined at line 1, column 1)",
+		},
+		{
+			"let f = fn(a, ...b) { a }; f()",
+			"wrong number of arguments to f. got=0, want=at least 1 (f is defined at line 1, column 9)",
+		},
+		{
+			"notaclass person {\n  public hi fn(selfish, name) { name }\n}\nnew person().hi()",
+			"wrong number of arguments to person.hi. got=0, want=1 (person.hi is defined at line 2, column 13)",
+		},
+		{
+			"let f = fn(a, b = c) { a }; f(1)",
+			"identifier not found: c",
+		},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		err, ok := evaluated.(*object.Error)
+		if !ok || err.Diagnostic == nil {
+			t.Errorf("%s: expected error with diagnostic, got=%T (%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if err.Diagnostic.Message != tt.expectedMessage {
+			t.Errorf("wrong error message. expected=%q, got=%q", tt.expectedMessage, err.Diagnostic.Message)
+		}
+	}
+}
+
 func TestClosures(t *testing.T) {
 	input := `
 let newadder = fn(x) {
@@ -386,6 +570,28 @@ func TestStringConcatenation(t *testing.T) {
 	}
 }
 
+func TestStringEscapesAndInterpolation(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"say \"hi\"\n\tbye"`, "say \"hi\"\n\tbye"},
+		{`"caf\u{e9} \\ \$"`, "café \\ $"},
+		{`let name = "OK?"; "hello ${name}!"`, "hello OK?!"},
+		{`let x = 2; "${x} + ${x} = ${x + x}"`, "2 + 2 = 4"},
+		{`"${[1, "a"]} ${NO!} ${true}"`, "[1, a] NO! true"},
+		{`let h = {"k": "v"}; "${h["k"]}"`, "v"},
+		{`let a = "b"; "${"nested ${a}"}"`, "nested b"},
+		{`"${1}${2}"`, "12"},
+	}
+
+	for _, tt := range tests {
+		testStringObject(t, testEval(t, tt.input), tt.expected)
+	}
+
+	testErrorObject(t, testEval(t, `"${foo}"`), "identifier not found: foo")
+}
+
 func TestBuiltinFunctions(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -394,6 +600,8 @@ func TestBuiltinFunctions(t *testing.T) {
 		{`len("")`, 0},
 		{`len("four")`, 4},
 		{`len("hello world")`, 11},
+		{`len("é")`, 1},
+		{`len("héllo 😀")`, 7},
 		{`len(1)`, "argument to `len` not supported, got INTEGER"},
 		{`len("one", "two")`, "wrong number of arguments. got=2, want=1"},
 	}
@@ -496,6 +704,30 @@ func TestArrayIndexExpressions(t *testing.T) {
 	}
 }
 
+func TestStringIndexExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`"abc"[0]`, "a"},
+		{`"héllo"[1]`, "é"},
+		{`"héllo"[2]`, "l"},
+		{`"😀!"[1]`, "!"},
+		{`"abc"[3]`, nil},
+		{`"abc"[-1]`, nil},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		str, ok := tt.expected.(string)
+		if ok {
+			testStringObject(t, evaluated, str)
+		} else {
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
 func TestHashLiterals(t *testing.T) {
 	input := `let two = "two";
 	{
@@ -536,6 +768,26 @@ func TestHashLiterals(t *testing.T) {
 	}
 }
 
+func TestHashLiteralOrder(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`{"z": 1, "a": 2, "m": 3}`, "{z: 1, a: 2, m: 3}"},
+		// keys and values are evaluated left to right
+		{`let n = 0; let next = fn() { n = n + 1; n }; {next(): next(), next(): next()}`, "{1: 2, 3: 4}"},
+		// updating a key keeps its place, new keys go on the end
+		{`let h = {"b": 1, "a": 2}; h["c"] = 3; h["b"] = 4; h`, "{b: 4, a: 2, c: 3}"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("expected %s, got %s", tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
 func TestHashIndexExpressions(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -569,6 +821,14 @@ func TestHashIndexExpressions(t *testing.T) {
 			`{false: 5}[false]`,
 			5,
 		},
+		{
+			`{1.5: 5}[1.5]`,
+			5,
+		},
+		{
+			`{0.0: 5}[-0.0]`,
+			5,
+		},
 	}
 
 	for _, tt := range tests {
@@ -630,6 +890,70 @@ func TestSwitchExpressions(t *testing.T) {
 	}
 }
 
+func TestSwitchPatterns(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`switch 2 { case 1, 2, 3: "small"; default: "big" }`, "small"},
+		{`switch 4 { case 1, 2, 3: "small"; default: "big" }`, "big"},
+		{`switch 5 { case 1..5: "in"; default: "out" }`, "in"},
+		{`switch -1 { case -5..-1: "in"; default: "out" }`, "in"},
+		{`switch 6 { case 1..5: "in"; default: "out" }`, "out"},
+		{`switch 3.0 { case 1..5: "in"; default: "out" }`, "out"},
+		{`switch [5, ""] { case [x, ""]: x; default: 0 }`, 5},
+		{`switch [5, "oops"] { case [x, ""]: x; case [_, err]: err }`, "oops"},
+		{`switch [1, [2, 3]] { case [a, [b, c]]: a + b + c }`, 6},
+		{`switch [1, 2] { case [a]: "short"; case [a, b, c]: "long"; default: "neither" }`, "neither"},
+		{`switch {"name": "bob", "age": 3} { case {"name": n}: n }`, "bob"},
+		{`switch {"age": 3} { case {"name": n}: n; default: "anon" }`, "anon"},
+		{`notaclass person { field name }; switch new person() { case 1: "one"; case person: "person" }`, "person"},
+		{`notaclass person { field name }; notaclass dog { field name }; switch new dog() { case person: "person"; default: "not a person" }`, "not a person"},
+		// a variable is still compared by value, even if it shadows a nac
+		{`notaclass person { field name }; let f = fn() { let person = 5; switch 5 { case person: "five" } }; f()`, "five"},
+		// mismatched types just don't match
+		{`switch "a" { case 1: "one"; case "a": "a" }`, "a"},
+		// bindings don't outlive their case
+		{`let x = 1; switch [2] { case [x]: x }; x`, 1},
+		// but a let in the case behaves the same whether or not it has bindings
+		{`switch [2] { case [x]: let y = x }; y`, 2},
+		{`switch 2 { case 2: let y = 3 }; y`, 3},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		switch v := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(v))
+		case string:
+			testStringObject(t, evaluated, v)
+		}
+	}
+}
+
+func TestSwitchPatternErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`switch 1 { case 1.."a": 1 }`, "range bounds must be integers, got STRING"},
+		{`switch 1 { case nope: 1 }`, "identifier not found: nope"},
+		{`switch [1] { case [x]: 1 }; x`, "identifier not found: x"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		err, ok := evaluated.(*object.Error)
+		if !ok || err.Diagnostic == nil {
+			t.Errorf("expected error for %q, got=%T (%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if err.Diagnostic.Message != tt.expected {
+			t.Errorf("expected %q, got %q", tt.expected, err.Diagnostic.Message)
+		}
+	}
+}
+
 func TestAssignment(t *testing.T) {
 	tests := []struct {
 		input       string
@@ -824,6 +1148,45 @@ func TestAssignment(t *testing.T) {
 
 			// I acknowledge that this is bad
 			x.email = "test";
+			// I acknowledge that this is bad
+			x.email;`,
+			"test",
+			"",
+		},
+		{
+			`
+			notaclass person {
+				pack "this is bad"
+
+				field email
+			}
+
+			let x = new person();
+
+			// I acknowledge that this is bad
+			x.email = "test";
+			x.email;`,
+			nil,
+			"`email` is a private field on nac person",
+		},
+		{
+			`
+			/// a person with a private email
+			notaclass person {
+				pack "this is bad"
+
+				field email
+			}
+
+			/* the ack below still
+			   counts as an ack */
+			/// our person
+			let x = new person();
+
+			// I acknowledge that this is bad
+			// and a second comment doesn't get in the way
+			x.email = "test";
+			/* I acknowledge that this is bad */
 			x.email;`,
 			"test",
 			"",
@@ -844,6 +1207,26 @@ func TestAssignment(t *testing.T) {
 			nil,
 			"`email` is a private field on nac person",
 		},
+		{
+			`
+			notaclass person {
+				pack "this is bad"
+
+				field email = "a@b.com"
+			}
+
+			notaclass thief {
+				public steal fn(selfish, p) {
+					// I acknowledge that this is bad
+					p.email
+				}
+			}
+
+			let t = new thief();
+			t.steal(new person());`,
+			"a@b.com",
+			"",
+		},
 		{
 			`
 			notaclass person {
@@ -1023,34 +1406,811 @@ func TestAssignment(t *testing.T) {
 	}
 }
 
-func TestEvalErrors(t *testing.T) {
+func TestNewCallsInit(t *testing.T) {
+	nac := `
+	notaclass person {
+		field name
+		field email
+
+		public init fn(selfish, name, email = "none") {
+			selfish.name = name;
+			selfish.email = email;
+		}
+	};
+	`
+
 	tests := []struct {
 		input    string
 		expected string
 	}{
-		{"x = 5", "line 1, column 3 (=): x has not been declared"},
-		{"1 >= 5;\nx=5", "line 2, column 2 (=): x has not been declared"},
-		{"switch x { case true: 1 }", "line 1, column 8 (x): identifier not found: x"},
+		{nac + `new person("Jesse")`, "person: {name: Jesse, email: none}"},
+		{nac + `new person("Jesse", "jesse@test.com")`, "person: {name: Jesse, email: jesse@test.com}"},
+		// without arguments, init is left for you to call yourself
+		{nac + `new person()`, "person: {}"},
+		{nac + `let p = new person(); p.init("Jesse"); p`, "person: {name: Jesse, email: none}"},
+		{nac + `new person(x)`, "identifier not found: x"},
+		{
+			nac + `new person("a", "b", "c")`,
+			"wrong number of arguments to person.init. got=3, want=1..2 (person.init is defined at line 6, column 15)",
+		},
+		{
+			`notaclass dog { field name }; new dog("rex")`,
+			"notaclass dog has no init method to pass arguments to. Either define one, or call new dog() with no arguments",
+		},
+		{
+			"notaclass puppy {\n field name\n init fn(selfish, name) { selfish.name = name }\n}; new puppy(\"rex\")",
+			"puppy: {name: rex}",
+		},
 	}
 
 	for _, tt := range tests {
 		evaluated := testEval(t, tt.input)
-		testExactErrorObject(t, evaluated, tt.expected)
+		switch evaluated := evaluated.(type) {
+		case *object.Error:
+			if evaluated.Diagnostic == nil || evaluated.Diagnostic.Message != tt.expected {
+				t.Errorf("expected %q, got error %q", tt.expected, evaluated.Message)
+			}
+		default:
+			if evaluated.Inspect() != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, evaluated.Inspect())
+			}
+		}
 	}
 }
 
-func testExactErrorObject(t *testing.T, obj object.Object, expected string) bool {
-	result, ok := obj.(*object.Error)
-	if !ok {
-		t.Errorf("object is not Error. got=%T (%+v)", obj, obj)
-		return false
+func TestFieldDefaults(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			"notaclass person {\n field name = \"anon\"\n field email\n}; new person()",
+			"person: {name: anon}",
+		},
+		// defaults are evaluated at new time, in the scope the nac was defined in
+		{
+			"let n = 1; notaclass counter {\n field count = n * 10\n}; n = 2; new counter()",
+			"counter: {count: 20}",
+		},
+		// each instance gets its own value
+		{
+			"notaclass bag {\n field items = []\n public add fn(selfish, x) { selfish.items = push(selfish.items, x) }\n}; let a = new bag(); a.add(1); new bag()",
+			"bag: {items: []}",
+		},
+		// init runs after the defaults are set
+		{
+			"notaclass person {\n field name = \"anon\"\n field age = 1\n init fn(selfish, name) { selfish.name = name }\n}; new person(\"Jesse\")",
+			"person: {name: Jesse, age: 1}",
+		},
+		{
+			"notaclass person {\n field name = x\n}; new person()",
+			"identifier not found: x",
+		},
 	}
 
-	if result.Message != expected {
-		t.Errorf("object has wrong value. got=\n%s\n, want=\n%s",
-			result.Message, expected)
-		return false
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		switch evaluated := evaluated.(type) {
+		case *object.Error:
+			if evaluated.Diagnostic == nil || evaluated.Diagnostic.Message != tt.expected {
+				t.Errorf("expected %q, got error %q", tt.expected, evaluated.Message)
+			}
+		default:
+			if evaluated.Inspect() != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, evaluated.Inspect())
+			}
+		}
+	}
+}
+
+func TestInterfaces(t *testing.T) {
+	iface := `
+	notaninterface greeter {
+		greet(name)
+		bye()
+	};
+	`
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{iface + `greeter`, "notaninterface greeter"},
+		{
+			iface + `notaclass person implements greeter {
+				public greet fn(selfish, name, greeting = "hi") { greeting + " " + name }
+				public bye fn() { "bye" }
+			}; let p = new person(); [p.greet("bob"), implements?(p, greeter)]`,
+			"[hi bob, true]",
+		},
+		// implements? doesn't care whether the nac claimed the interface
+		{
+			iface + `notaclass person {
+				public greet fn(selfish, ...names) { 1 }
+				public bye fn(selfish) { 2 }
+			}; implements?(new person(), greeter)`,
+			"true",
+		},
+		{iface + `notaclass rock { field x }; implements?(new rock(), greeter)`, "false"},
+		{iface + `implements?(5, greeter)`, "false"},
+		// after evolving, we ask about the new nac
+		{
+			iface + `notaclass rock { field x };
+			notaclass person {
+				field old = false
+				public greet fn(selfish, name) { 1 }
+				public bye fn() { 2 }
+				public age fn(selfish) { selfish.old = true }
+				evolve fn(selfish) { switch selfish.old { case true: new rock(); default: NO! } }
+			}; let p = new person(); let before = implements?(p, greeter); p.age(); [before, implements?(p, greeter)]`,
+			"[true, false]",
+		},
+		{
+			iface + `notaclass person implements greeter { public greet fn(selfish, name) { 1 } }`,
+			"notaclass person does not implement greeter: it has no bye() method",
+		},
+		{
+			iface + `notaclass person implements greeter { public greet fn(selfish, name) { 1 } bye fn() { 2 } }`,
+			"notaclass person does not implement greeter: its bye method is private",
+		},
+		{
+			iface + `notaclass person implements greeter { public greet fn(selfish) { 1 } public bye fn() { 2 } }`,
+			"notaclass person does not implement greeter: greet should take 1 argument, but it takes 0",
+		},
+		{
+			iface + `notaclass person implements greeter { public greet fn(selfish, a, b) { 1 } public bye fn() { 2 } }`,
+			"notaclass person does not implement greeter: greet should take 1 argument, but it takes 2",
+		},
+		{
+			`let x = 5; notaclass person implements x { field name }`,
+			"notaclass person can only implement a notaninterface, but x is INTEGER",
+		},
+		{iface + `implements?(5, 5)`, "second argument to `implements?` must be NOTANINTERFACE, got INTEGER"},
+		// like nacs, notaninterfaces only exist in the block they're declared in
+		{`if (true) { notaninterface walker { walk() } }; walker`, "identifier not found: walker"},
+		{`switch 1 { case 1: notaninterface walker { walk() } }; walker`, "identifier not found: walker"},
+		{`let f = fn() { notaninterface walker { walk() } }; f(); walker`, "identifier not found: walker"},
+		{`if (true) { notaninterface walker { walk() }; let p = implements?(5, walker) }; p`, "false"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		switch evaluated := evaluated.(type) {
+		case *object.Error:
+			if evaluated.Diagnostic == nil || evaluated.Diagnostic.Message != tt.expected {
+				t.Errorf("expected %q, got error %q", tt.expected, evaluated.Message)
+			}
+		default:
+			if evaluated.Inspect() != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, evaluated.Inspect())
+			}
+		}
+	}
+}
+
+func TestEvolutionHistory(t *testing.T) {
+	nacs := `
+notaclass rock {
+  field name
+  onevolve fn(selfish, old) { selfish.name = old.name + "!" }
+}
+notaclass brgousie {
+  field name
+  public whoami fn(selfish) { selfish.name }
+  public fall fn(selfish) { selfish.name = "" }
+  onevolve fn(selfish, old) { selfish.name = old.whoami() }
+  evolve fn(selfish) { switch selfish.name { case "": new rock(); default: NO! } }
+}
+notaclass person {
+  field name
+  field likeclas = false
+  public init fn(selfish, name) { selfish.name = name }
+  public whoami fn(selfish) { selfish.name }
+  public makeold fn(selfish) { selfish.likeclas = true }
+  evolve fn(selfish) { switch selfish.likeclas { case true: new brgousie(); default: NO! } }
+};
+`
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{nacs + `history(new person("John"))`, "[]"},
+		// onevolve gets what we were before, and can see its private fields
+		{nacs + `let p = new person("John");
+p.makeold();
+[p.whoami(), history(p)]`, "[John, [{from: person, to: brgousie, at: line 22, column 1}]]"},
+		{nacs + `let p = new person("John");
+p.makeold();
+p.fall();
+history(p)`, "[{from: person, to: brgousie, at: line 22, column 1}, {from: brgousie, to: rock, at: line 23, column 1}]"},
+		{nacs + `let p = new person("John"); p.makeold(); p.fall(); p`, "rock: {name: !}"},
+		{`history(5)`, "argument to `history` must be a nac instance, got INTEGER"},
+		// an evolve that gives back nothing at all doesn't evolve
+		{
+			"notaclass person {\n public go fn() { 1 }\n evolve fn(selfish) { return; }\n}; let p = new person(); p.go(); [p, history(p)]",
+			"[person: {}, []]",
+		},
+		{
+			"notaclass person {\n public go fn() { 1 }\n evolve fn(selfish) {}\n}; let p = new person(); p.go(); [p, history(p)]",
+			"[person: {}, []]",
+		},
+		{
+			"notaclass rock {\n onevolve fn(selfish) { 1 }\n}; notaclass person {\n public go fn() { 1 }\n evolve fn(selfish) { new rock() }\n}; new person().go()",
+			"wrong number of arguments to rock.onevolve. got=1, want=0 (rock.onevolve is defined at line 2, column 11)",
+		},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		switch evaluated := evaluated.(type) {
+		case *object.Error:
+			if evaluated.Diagnostic == nil || evaluated.Diagnostic.Message != tt.expected {
+				t.Errorf("expected %q, got error %q", tt.expected, evaluated.Message)
+			}
+		default:
+			if evaluated.Inspect() != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, evaluated.Inspect())
+			}
+		}
+	}
+}
+
+func TestCarryingFieldsAcrossEvolution(t *testing.T) {
+	nacs := `
+notaclass brgousie {
+  field name
+  field email
+  field wealth = 100
+}
+notaclass rock { field name }
+notaclass person {
+  field name
+  field email
+  field likeclas = false
+  carry name, email into brgousie
+  carry name into rock
+
+  public init fn(selfish, name) { selfish.name = name }
+  public makeold fn(selfish) { selfish.likeclas = true }
+  public die fn(selfish) { selfish.likeclas = NO! }
+  evolve fn(selfish) {
+    switch selfish.likeclas {
+      case true: new brgousie();
+      case NO!: new rock();
+      default: NO!
+    }
+  }
+};
+`
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		// email was never set so the brgousie keeps its own
+		{nacs + `let p = new person("John"); p.makeold(); p`, "brgousie: {name: John, wealth: 100}"},
+		{nacs + `let p = new person("John"); p.die(); p`, "rock: {name: John}"},
+		{
+			"notaclass dog {\n  field name\n  carry name, age into cat\n}",
+			"notaclass dog can't carry age into cat: dog has no field age",
+		},
+		{
+			"notaclass cat { field x }; notaclass dog {\n  field name\n  carry name into cat\n}",
+			"notaclass dog can't carry name into cat: cat has no field name",
+		},
+		// if the target is defined later, we find out when it's defined,
+		// without anything having to evolve
+		{
+			`notaclass dog {
+  field name = "rex"
+  carry name into cat
+}; notaclass cat { field x }; 5`,
+			"notaclass dog can't carry name into cat: cat has no field name",
+		},
+		{
+			`notaclass dog {
+  field name = "rex"
+  carry name into cat
+  public go fn() { 1 }
+  evolve fn(selfish) { new cat() }
+}; notaclass cat { field name }; let d = new dog(); d.go(); d`,
+			"cat: {name: rex}",
+		},
+		// a cat defined somewhere else entirely isn't the one dog carries into
+		{
+			`notaclass dog {
+  field name
+  carry name into cat
+}; let f = fn() { notaclass cat { field x } }; f(); 5`,
+			"notaclass dog can't carry into cat: undefined nac cat",
+		},
+		{
+			"notaclass dog {\n  field name\n  carry name into ghost\n}\n5",
+			"notaclass dog can't carry into ghost: undefined nac ghost",
+		},
+		{
+			`if (true) { notaclass dog { field name carry name into ghost }; 5 }`,
+			"notaclass dog can't carry into ghost: undefined nac ghost",
+		},
+		{
+			`let f = fn() { notaclass dog { field name carry name into ghost }; return 5 }; f()`,
+			"notaclass dog can't carry into ghost: undefined nac ghost",
+		},
+		{
+			`let f = fn() { notaclass dog { field name carry name into cat }; notaclass cat { field name }; 5 }; f()`,
+			"5",
+		},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		switch evaluated := evaluated.(type) {
+		case *object.Error:
+			if evaluated.Diagnostic == nil || evaluated.Diagnostic.Message != tt.expected {
+				t.Errorf("expected %q, got error %q", tt.expected, evaluated.Message)
+			}
+		default:
+			if evaluated.Inspect() != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, evaluated.Inspect())
+			}
+		}
+	}
+}
+
+func TestFieldPacks(t *testing.T) {
+	nacs := `
+notaclass person {
+  pack "this is bad"
+  field name = "jesse"
+  field email = "a@b.com"
+  pack email "I am reading somebody else's email"
+}
+let p = new person();
+`
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			nacs + `
+// I acknowledge that this is bad
+p.name`,
+			"jesse",
+		},
+		{
+			nacs + `
+// I acknowledge that I am reading somebody else's email
+p.email`,
+			"a@b.com",
+		},
+		{
+			nacs + `
+// I acknowledge that this is bad
+p.email`,
+			"`email` is a private field on nac person",
+		},
+		{
+			nacs + `
+// I acknowledge that I am reading somebody else's email
+p.name`,
+			"`name` is a private field on nac person",
+		},
+		{
+			// a function defined in the acknowledged statement is covered
+			nacs + `
+// I acknowledge that this is bad
+let f = fn() { p.name };
+f()`,
+			"jesse",
+		},
+		{
+			// but calling a function from the acknowledged statement doesn't
+			// cover its body
+			nacs + `
+let f = fn() { p.name };
+// I acknowledge that this is bad
+f()`,
+			"`name` is a private field on nac person",
+		},
+		{
+			`notaclass person {
+  field name
+  pack email "nope"
+}`,
+			"notaclass person has a pack for email, but no field email",
+		},
+		{
+			`notaclass person {
+  field email
+  pack email "nope"
+  pack email "still nope"
+}`,
+			"notaclass person has more than one pack for email",
+		},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		switch evaluated := evaluated.(type) {
+		case *object.Error:
+			if evaluated.Diagnostic == nil || evaluated.Diagnostic.Message != tt.expected {
+				t.Errorf("expected %q, got error %q", tt.expected, evaluated.Message)
+			}
+		default:
+			if evaluated.Inspect() != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, evaluated.Inspect())
+			}
+		}
+	}
+}
+
+func TestMethodsCloseOverTheirScope(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			`let greeting = "hello";
+notaclass person { public greet fn() { greeting } };
+new person().greet()`,
+			"hello",
+		},
+		{
+			// like functions, methods see bindings made after the nac
+			`notaclass person { public greet fn(selfish, name) { shout(name) } };
+let shout = fn(s) { s + "!" };
+new person().greet("jesse")`,
+			"jesse!",
+		},
+		{
+			`let make = fn(name) {
+  notaclass thing { public who fn() { name } };
+  new thing()
+};
+make("rock").who()`,
+			"rock",
+		},
+		{
+			// but not the bindings of whoever's calling them
+			`notaclass person { public peek fn() { secret } };
+let p = new person();
+let f = fn() { let secret = 1; p.peek() };
+f()`,
+			"identifier not found: secret",
+		},
+		{
+			// and being inside a method doesn't let you into other nacs
+			`notaclass safe { field code = 1234 };
+let s = new safe();
+notaclass thief { public steal fn() { s.code } };
+new thief().steal()`,
+			"`code` is a private field on nac safe",
+		},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		switch evaluated := evaluated.(type) {
+		case *object.Error:
+			if evaluated.Diagnostic == nil || evaluated.Diagnostic.Message != tt.expected {
+				t.Errorf("expected %q, got error %q", tt.expected, evaluated.Message)
+			}
+		default:
+			if evaluated.Inspect() != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, evaluated.Inspect())
+			}
+		}
+	}
+}
+
+func TestNacScopesAndValues(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			`notaclass person {}; let kind = person; kind`,
+			"notaclass person",
+		},
+		{
+			`notaclass person { field name = "jesse" }; let kind = person; new kind()`,
+			`person: {name: jesse}`,
+		},
+		{
+			`notaclass rock {}; let make = fn(kind) { new kind() }; make(rock)`,
+			"rock: {}",
+		},
+		{
+			`let kind = 5; new kind()`,
+			"`kind` is not a nac, it's 5",
+		},
+		{
+			`let kind = "100%d"; new kind()`,
+			"`kind` is not a nac, it's 100%d",
+		},
+		{
+			`notaclass rock {}; let f = fn() { new rock() }; f()`,
+			"rock: {}",
+		},
+		{
+			`let f = fn() { notaclass rock {}; new rock() }; f(); new rock()`,
+			"undefined nac rock",
+		},
+		{
+			`if (true) { notaclass rock {} }; new rock()`,
+			"undefined nac rock",
+		},
+		{
+			`switch 1 { case 1: notaclass rock {}; default: NO! }; new rock()`,
+			"undefined nac rock",
+		},
+		{
+			// variables declared in a block still outlive it
+			`if (true) { notaclass rock {}; let r = new rock() }; r`,
+			"rock: {}",
+		},
+		{
+			`notaclass rock {}
+let r = if (true) { notaclass rock { field name = "inner" }; new rock() };
+[r, new rock()]`,
+			"[rock: {name: inner}, rock: {}]",
+		},
+		{
+			`notaclass rock {}
+notaclass person {
+  field next = rock
+  public poke fn() { 1 }
+  evolve fn(selfish) { let kind = selfish.next; new kind() }
+}
+let p = new person();
+p.poke();
+p`,
+			"rock: {}",
+		},
+		{
+			`notaclass person {}; let kind = person; switch new person() { case kind: "yes"; default: "no" }`,
+			"yes",
+		},
+		{
+			`notaclass person {}; let kind = person; switch kind { case person: "yes"; default: "no" }`,
+			"yes",
+		},
+		{
+			`let person = 5; notaclass person {}`,
+			"cannot define notaclass person: person has already been declared",
+		},
+		{
+			`notaclass person {}; let person = 5`,
+			"cannot declare person: notaclass person has already been defined",
+		},
+		{
+			`notaclass person {}; let [person] = [5]`,
+			"cannot declare person: notaclass person has already been defined",
+		},
+		{
+			`notaninterface person { greet() }; notaclass person {}`,
+			"cannot define notaclass person: person has already been declared",
+		},
+		{
+			// a block's variables belong to the enclosing scope
+			`if (true) { notaclass rock {}; let rock = 5 }`,
+			"cannot declare rock: notaclass rock has already been defined",
+		},
+		{
+			// but shadowing a name from further out is fine
+			`let rock = 5; let f = fn() { notaclass rock {}; new rock() }; f()`,
+			"rock: {}",
+		},
+		{
+			`notaclass rock {}; let f = fn() { let rock = 5; rock }; f()`,
+			"5",
+		},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		switch evaluated := evaluated.(type) {
+		case *object.Error:
+			if evaluated.Diagnostic == nil || evaluated.Diagnostic.Message != tt.expected {
+				t.Errorf("expected %q, got error %q", tt.expected, evaluated.Message)
+			}
+		default:
+			if evaluated.Inspect() != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, evaluated.Inspect())
+			}
+		}
+	}
+}
+
+func TestNacInstanceErrors(t *testing.T) {
+	prelude := `notaclass person { field name = "jesse" }; let p = new person(); `
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`p["name"]`, "cannot index into notaclass person: nacs don't have keys, so use its methods instead"},
+		{`p["name"] = "bob"`, "cannot index into notaclass person: nacs don't have keys, so use its methods instead"},
+		{`len(p)`, "argument to `len` not supported, got notaclass person"},
+		{`p + 1`, "type mismatch: notaclass person + INTEGER"},
+		{`p + new person()`, "unknown operator: notaclass person + notaclass person"},
+		{`-p`, "unknown operator: -notaclass person"},
+		{`p()`, "not a function: notaclass person"},
+		{`let h = {}; h[p] = 1`, "Unusable as hash key: notaclass person"},
+		{`let [a] = p`, "cannot destructure notaclass person into [a]: it's not an array"},
+		// the nac itself isn't an instance of it
+		{`person + 1`, "type mismatch: notaclass person (the nac itself) + INTEGER"},
+		{`person[0]`, "index operator not supported: notaclass person (the nac itself)"},
+		{`-person`, "unknown operator: -notaclass person (the nac itself)"},
+		{`notaninterface greeter { greet() }; greeter + 1`, "type mismatch: notaninterface greeter + INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, prelude+tt.input)
+		err, ok := evaluated.(*object.Error)
+		if !ok || err.Diagnostic == nil {
+			t.Errorf("expected error %q for %q, got=%T (%+v)", tt.expected, tt.input, evaluated, evaluated)
+			continue
+		}
+
+		if err.Diagnostic.Message != tt.expected {
+			t.Errorf("expected %q for %q, got %q", tt.expected, tt.input, err.Diagnostic.Message)
+		}
+	}
+}
+
+func TestEvalErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"x = 5", "line 1, column 3 (=): x has not been declared"},
+		{"1 >= 5;\nx=5", "line 2, column 2 (=): x has not been declared"},
+		{"switch x { case true: 1 }", "line 1, column 8 (x): identifier not found: x"},
+		{"let s = \"héllo\"; x = 1", "line 1, column 20 (=): x has not been declared"},
+		{"let x = 1 / 0", "line 1, column 11 (/): division by zero"},
+		{"let x = 1 % 0", "line 1, column 11 (%): modulo by zero"},
+		{"99999999999999999999 / 0", "line 1, column 22 (/): division by zero"},
+		{"1.5 / 0", "line 1, column 5 (/): division by zero"},
+		{"let x = [1]; x[99999999999999999999] = 2", "line 1, column 38 (=): Index 99999999999999999999 is out of bounds (array length 1)"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		testExactErrorObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestErrorCodes(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected diagnostic.Code
+	}{
+		{"1 + \"a\"", diagnostic.TypeMismatch},
+		{"-true", diagnostic.UnknownOperator},
+		{"1 / 0", diagnostic.DivisionByZero},
+		{"x", diagnostic.UndeclaredIdentifier},
+		{"[1][5] = 2", diagnostic.IndexOutOfBounds},
+		{"let x = 1; x()", diagnostic.NotAFunction},
+		{"len(1, 2)", diagnostic.InvalidArgument},
+		{"notaclass p { field n }; let x = new p(); x.n", diagnostic.PrivateAccess},
+		{"new p()", diagnostic.UndefinedNac},
+		{"notaclass p {}; let x = new p(); x[\"n\"]", diagnostic.InvalidIndex},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		err, ok := evaluated.(*object.Error)
+		if !ok || err.Diagnostic == nil {
+			t.Fatalf("expected error with diagnostic for %q, got=%T (%+v)", tt.input, evaluated, evaluated)
+		}
+
+		if err.Diagnostic.Code != tt.expected {
+			t.Errorf("expected code %s for %q, got=%s (%s)", tt.expected, tt.input, err.Diagnostic.Code, err.Message)
+		}
+	}
+}
+
+func TestErrorSpans(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x = 1;\nlet y = x + \"a\";", `x + "a"`},
+		{"let f = fn() {\n\treturn [1][\"a\"];\n};\nf()", `[1]["a"]`},
+		{"y = 5", "y = 5"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		err, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Fatalf("expected error, got=%T (%+v)", evaluated, evaluated)
+		}
+
+		if err.Diagnostic == nil {
+			t.Fatalf("expected error to have a diagnostic")
+		}
+		span := err.Diagnostic.Span
+		actual := tt.input[span.Start:span.End]
+		if actual != tt.expected {
+			t.Errorf("expected error span %q, got=%q", tt.expected, actual)
+		}
+	}
+}
+
+func testExactErrorObject(t *testing.T, obj object.Object, expected string) bool {
+	result, ok := obj.(*object.Error)
+	if !ok {
+		t.Errorf("object is not Error. got=%T (%+v)", obj, obj)
+		return false
+	}
+
+	if result.Message != expected {
+		t.Errorf("object has wrong value. got=\n%s\n, want=\n%s",
+			result.Message, expected)
+		return false
 	}
 
 	return true
 }
+
+func TestDestructuring(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`let [val, err] = [5, ""]; val`, 5},
+		{`let [val, err] = [NO!, "oops"]; err`, "oops"},
+		{`let [[a, b], c] = [[1, 2], 3]; a + b + c`, 6},
+		{`let {"x": x, 2: [y]} = {"x": 1, 2: [2], "z": 3}; x + y`, 3},
+		{`let [] = []; 1`, 1},
+		{`let [_, err] = [1, "oops"]; err`, "oops"},
+		{`let [a, b] = [1]`, "cannot destructure an array of length 1 into [a, b], which expects length 2"},
+		{`let [a, b] = 5`, "cannot destructure INTEGER into [a, b]: it's not an array"},
+		{`let {"x": x} = {"y": 1}`, "cannot destructure hash: it has no key x"},
+		{`let {"x": x} = [1]`, "cannot destructure ARRAY into a hash pattern: it's not a hash"},
+		// nothing gets bound if the pattern doesn't match
+		{`let a = 1; let [a, [b]] = [2, [3, 4]]`, "cannot destructure an array of length 2 into [b], which expects length 1"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			switch obj := evaluated.(type) {
+			case *object.String:
+				if obj.Value != expected {
+					t.Errorf("expected %q, got %q", expected, obj.Value)
+				}
+			case *object.Error:
+				if obj.Diagnostic == nil || obj.Diagnostic.Message != expected {
+					t.Errorf("expected error %q, got %q", expected, obj.Message)
+				}
+			default:
+				t.Errorf("unexpected result for %q: %T (%+v)", tt.input, evaluated, evaluated)
+			}
+		}
+	}
+}
+
+func TestDestructuringErrorsPointAtThePattern(t *testing.T) {
+	evaluated := testEval(t, "let x = 1;\nlet [a, [b, c]] = [1, [2]];")
+	err, ok := evaluated.(*object.Error)
+	if !ok || err.Diagnostic == nil {
+		t.Fatalf("expected error with diagnostic, got=%T (%+v)", evaluated, evaluated)
+	}
+
+	if err.Diagnostic.Code != diagnostic.PatternMismatch {
+		t.Errorf("expected code %s, got %s", diagnostic.PatternMismatch, err.Diagnostic.Code)
+	}
+
+	span := err.Diagnostic.Span
+	if span.Line != 1 || span.Column != 9 || span.End-span.Start != len("[b, c]") {
+		t.Errorf("expected the error to point at [b, c], got %+v", span)
+	}
+}
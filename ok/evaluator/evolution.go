@@ -0,0 +1,133 @@
+package evaluator
+
+import (
+	"github.com/jesseduffield/OK/ok/ast"
+	"github.com/jesseduffield/OK/ok/diagnostic"
+	"github.com/jesseduffield/OK/ok/object"
+)
+
+// checkCarries makes sure every field a nac carries into another nac is one of
+// its own fields, and, if the target nac has already been defined, one of the
+// target's fields too. A target defined later on is checked when it's defined,
+// by checkPendingCarries.
+func (e *Evaluator) checkCarries(
+	structDef *ast.Struct,
+	env *object.Environment,
+) object.Object {
+	pending := []*ast.StructCarry{}
+	for _, carry := range structDef.Carries {
+		var target *ast.Struct
+		found, targetDefined := env.GetStruct(carry.Target)
+		if targetDefined {
+			target = found.Definition
+		}
+		if carry.Target == structDef.Name {
+			target, targetDefined = structDef, true
+		}
+
+		for _, field := range carry.Fields {
+			if !hasField(structDef, field.Value) {
+				return e.at(field).carryError(structDef.Name, field.Value, carry.Target, structDef.Name)
+			}
+
+			if targetDefined && !hasField(target, field.Value) {
+				return e.at(field).carryError(structDef.Name, field.Value, carry.Target, carry.Target)
+			}
+		}
+
+		if !targetDefined {
+			pending = append(pending, carry)
+		}
+	}
+
+	for _, carry := range pending {
+		env.AddPendingCarry(structDef, carry)
+	}
+
+	return nil
+}
+
+// checkPendingCarries checks the carries into a nac which were made by nacs
+// defined before it in the same scope, now that we know the nac's fields
+func (e *Evaluator) checkPendingCarries(
+	structDef *ast.Struct,
+	env *object.Environment,
+) object.Object {
+	for _, pending := range env.TakePendingCarries(structDef.Name) {
+		for _, field := range pending.Carry.Fields {
+			if !hasField(structDef, field.Value) {
+				return e.at(field).carryError(pending.From.Name, field.Value, structDef.Name, structDef.Name)
+			}
+		}
+	}
+
+	return nil
+}
+
+// checkUnresolvedCarries is for the end of a program or block, after which no
+// more nacs can be defined in its environment. Any carry still waiting on its
+// target by then is carrying into a nac that doesn't exist.
+func (e *Evaluator) checkUnresolvedCarries(env *object.Environment) object.Object {
+	pending := env.PendingCarries()
+	if len(pending) == 0 {
+		return nil
+	}
+
+	first := pending[0]
+	return e.at(first.Carry).newError(
+		diagnostic.UndefinedNac,
+		"notaclass %s can't carry into %s: undefined nac %s",
+		first.From.Name,
+		first.Carry.Target,
+		first.Carry.Target,
+	)
+}
+
+// carryFields copies the fields that the instance's nac carries into the nac
+// it's evolving into. Carried fields win over whatever the new instance has,
+// unless the old instance never set them.
+func (e *Evaluator) carryFields(
+	instance *object.StructInstance,
+	new *object.StructInstance,
+) object.Object {
+	for _, carry := range instance.Struct.Carries {
+		if carry.Target != new.Struct.Name {
+			continue
+		}
+
+		for _, field := range carry.Fields {
+			if !new.IsField(field.Value) {
+				return e.carryError(instance.Struct.Name, field.Value, carry.Target, carry.Target)
+			}
+
+			if value, ok := instance.Fields[field.Value]; ok {
+				new.Fields[field.Value] = value
+			}
+		}
+	}
+
+	return nil
+}
+
+// carryError explains that a carried field is missing from one of the nacs
+// involved
+func (e *Evaluator) carryError(from string, field string, target string, missingFrom string) *object.Error {
+	return e.newError(
+		diagnostic.InvalidEvolution,
+		"notaclass %s can't carry %s into %s: %s has no field %s",
+		from,
+		field,
+		target,
+		missingFrom,
+		field,
+	)
+}
+
+func hasField(structDef *ast.Struct, name string) bool {
+	for _, field := range structDef.Fields {
+		if field.Name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,95 @@
+package evaluator
+
+import (
+	"fmt"
+
+	"github.com/jesseduffield/OK/ok/ast"
+	"github.com/jesseduffield/OK/ok/diagnostic"
+	"github.com/jesseduffield/OK/ok/object"
+)
+
+func (e *Evaluator) evalInterfaceDefinition(
+	node *ast.Interface,
+	env *object.Environment,
+) object.Object {
+	// like a nac, a notaninterface only exists in the block it's declared in
+	env.Bind(node.Name, &object.Interface{Definition: node})
+	return object.NULL
+}
+
+// checkImplements makes sure that a nac has every method asked for by the
+// notaninterfaces it claims to implement. Returns nil if it does.
+func (e *Evaluator) checkImplements(
+	structDef *ast.Struct,
+	env *object.Environment,
+) object.Object {
+	for _, name := range structDef.Interfaces {
+		obj := e.Eval(name, env)
+		if isError(obj) {
+			return obj
+		}
+
+		iface, ok := obj.(*object.Interface)
+		if !ok {
+			return e.at(name).newError(
+				diagnostic.TypeMismatch,
+				"notaclass %s can only implement a notaninterface, but %s is %s",
+				structDef.Name,
+				name.String(),
+				typeName(obj),
+			)
+		}
+
+		if problem := unsatisfied(structDef, iface.Definition); problem != "" {
+			return e.at(name).newError(
+				diagnostic.UnsatisfiedInterface,
+				"notaclass %s does not implement %s: %s",
+				structDef.Name,
+				iface.Definition.Name,
+				problem,
+			)
+		}
+	}
+
+	return nil
+}
+
+// unsatisfied explains why the nac doesn't implement the interface, returning
+// an empty string if it does. A method satisfies the interface if it's public
+// and accepts every number of arguments that the interface says it takes.
+func unsatisfied(structDef *ast.Struct, iface *ast.Interface) string {
+	for _, wanted := range iface.Methods {
+		method, ok := structDef.Methods[wanted.Name]
+		if !ok {
+			return fmt.Sprintf("it has no %s method", wanted.String())
+		}
+		if !method.Public {
+			return fmt.Sprintf("its %s method is private", wanted.Name)
+		}
+
+		params := method.FunctionLiteral.Parameters
+		if hasSelfish(params) {
+			params = params[1:]
+		}
+		min, max := ast.Arity(params)
+		wantedMin, wantedMax := ast.Arity(wanted.Parameters)
+		if min > wantedMin || (max != -1 && (wantedMax == -1 || max < wantedMax)) {
+			return fmt.Sprintf(
+				"%s should take %s, but it takes %s",
+				wanted.Name,
+				describeArguments(wantedMin, wantedMax),
+				describeArity(min, max),
+			)
+		}
+	}
+
+	return ""
+}
+
+// describeArguments is describeArity with a unit, e.g. '1 argument'
+func describeArguments(min int, max int) string {
+	if max == 1 || (max == -1 && min == 1) {
+		return describeArity(min, max) + " argument"
+	}
+	return describeArity(min, max) + " arguments"
+}
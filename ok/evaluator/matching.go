@@ -0,0 +1,155 @@
+package evaluator
+
+import (
+	"math/big"
+
+	"github.com/jesseduffield/OK/ok/ast"
+	"github.com/jesseduffield/OK/ok/diagnostic"
+	"github.com/jesseduffield/OK/ok/object"
+)
+
+// matchCase checks whether a switch case's value matches the subject. See
+// ast.SwitchCase for what a value can be.
+func (e *Evaluator) matchCase(
+	value ast.Expression,
+	subject object.Object,
+	env *object.Environment,
+) ([]binding, bool, *object.Error) {
+	// an identifier naming a nac, or holding one, matches instances of that
+	// nac, as well as the nac itself
+	if ident, ok := value.(*ast.Identifier); ok {
+		if nac, isNac := env.GetStruct(ident.Value); isNac {
+			switch subject := subject.(type) {
+			case *object.StructInstance:
+				return nil, subject.Struct == nac.Definition, nil
+			case *object.Struct:
+				return nil, subject.Definition == nac.Definition, nil
+			default:
+				return nil, false, nil
+			}
+		}
+	}
+
+	return e.matchPattern(value, subject, env, nil)
+}
+
+// matchPattern matches the subject against a pattern, returning the bindings
+// made along the way. Inside array and hash patterns, identifiers bind to
+// whatever they line up with, except for '_' which matches anything without
+// binding it.
+func (e *Evaluator) matchPattern(
+	pattern ast.Expression,
+	subject object.Object,
+	env *object.Environment,
+	bindings []binding,
+) ([]binding, bool, *object.Error) {
+	switch pattern := pattern.(type) {
+	case *ast.ArrayLiteral:
+		array, ok := subject.(*object.Array)
+		if !ok || len(array.Elements) != len(pattern.Elements) {
+			return nil, false, nil
+		}
+
+		for i, element := range pattern.Elements {
+			var matched bool
+			var err *object.Error
+			bindings, matched, err = e.matchElement(element, array.Elements[i], env, bindings)
+			if err != nil || !matched {
+				return nil, false, err
+			}
+		}
+
+		return bindings, true, nil
+
+	case *ast.HashLiteral:
+		hash, ok := subject.(*object.Hash)
+		if !ok {
+			return nil, false, nil
+		}
+
+		for _, pair := range pattern.Pairs {
+			key := e.Eval(pair.Key, env)
+			if isError(key) {
+				return nil, false, key.(*object.Error)
+			}
+			hashKey, ok := key.(object.Hashable)
+			if !ok {
+				return nil, false, e.at(pair.Key).newError(diagnostic.InvalidIndex, "unusable as hash key: %s", typeName(key))
+			}
+
+			found, ok := hash.Get(hashKey.HashKey())
+			if !ok {
+				return nil, false, nil
+			}
+
+			var matched bool
+			var err *object.Error
+			bindings, matched, err = e.matchElement(pair.Value, found.Value, env, bindings)
+			if err != nil || !matched {
+				return nil, false, err
+			}
+		}
+
+		return bindings, true, nil
+
+	case *ast.RangeExpression:
+		low, err := e.evalRangeBound(pattern.Low, env)
+		if err != nil {
+			return nil, false, err
+		}
+		high, err := e.evalRangeBound(pattern.High, env)
+		if err != nil {
+			return nil, false, err
+		}
+
+		if subject.Type() != object.INTEGER_OBJ {
+			return nil, false, nil
+		}
+		value := object.ToBigInt(subject)
+
+		return bindings, value.Cmp(low) >= 0 && value.Cmp(high) <= 0, nil
+
+	default:
+		value := e.Eval(pattern, env)
+		if isError(value) {
+			return nil, false, value.(*object.Error)
+		}
+
+		// a value of a different type is never going to be equal
+		if value.Type() != subject.Type() && !(isNumeric(value) && isNumeric(subject)) {
+			return nil, false, nil
+		}
+
+		return bindings, e.evalInfixExpression("==", subject, value) == object.TRUE, nil
+	}
+}
+
+// matchElement matches an element of an array or hash pattern, which unlike a
+// top-level case value, can be a name to bind to
+func (e *Evaluator) matchElement(
+	pattern ast.Expression,
+	subject object.Object,
+	env *object.Environment,
+	bindings []binding,
+) ([]binding, bool, *object.Error) {
+	if ident, ok := pattern.(*ast.Identifier); ok {
+		if ident.Value == "_" {
+			return bindings, true, nil
+		}
+		return append(bindings, binding{name: ident.Value, value: subject}), true, nil
+	}
+
+	return e.matchPattern(pattern, subject, env, bindings)
+}
+
+func (e *Evaluator) evalRangeBound(node ast.Expression, env *object.Environment) (*big.Int, *object.Error) {
+	bound := e.Eval(node, env)
+	if isError(bound) {
+		return nil, bound.(*object.Error)
+	}
+	if bound.Type() != object.INTEGER_OBJ {
+		return nil, e.at(node).newError(diagnostic.TypeMismatch, "range bounds must be integers, got %s", typeName(bound))
+	}
+
+	return object.ToBigInt(bound), nil
+}
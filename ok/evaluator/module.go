@@ -0,0 +1,209 @@
+package evaluator
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jesseduffield/OK/ok/ast"
+	"github.com/jesseduffield/OK/ok/diagnostic"
+	"github.com/jesseduffield/OK/ok/lexer"
+	"github.com/jesseduffield/OK/ok/object"
+	"github.com/jesseduffield/OK/ok/parser"
+)
+
+// modules is shared by every evaluator spawned from the same call to New, so
+// that a file imported from several places is only ever evaluated once
+type modules struct {
+	// keyed by absolute path
+	cache map[string]*object.Module
+	// the source of each file we've loaded, keyed by the file name used in
+	// its spans, so that errors inside modules can be shown
+	sources map[string]string
+	// absolute paths of the modules we're part-way through evaluating, in
+	// the order they were imported. Used to catch import cycles
+	loading []string
+}
+
+func newModules() *modules {
+	return &modules{
+		cache:   map[string]*object.Module{},
+		sources: map[string]string{},
+	}
+}
+
+// ModuleSource returns the source of an imported file, for pointing at errors
+// which happened inside it
+func (e *Evaluator) ModuleSource(file string) (string, bool) {
+	source, ok := e.modules.sources[file]
+	return source, ok
+}
+
+func (e *Evaluator) evalImportStatement(
+	node *ast.ImportStatement,
+	env *object.Environment,
+) object.Object {
+	module := e.loadModule(node)
+	if isError(module) {
+		return module
+	}
+
+	env.Set(node.Name.Value, module)
+
+	return nil
+}
+
+func (e *Evaluator) loadModule(node *ast.ImportStatement) object.Object {
+	importer := node.Token.File
+	// anything that didn't come from a file, e.g. the REPL, has a name like
+	// '<input 1>'. Programs from the playground have no name at all, and we
+	// don't want those reading files off the server
+	if importer == "" || strings.HasPrefix(importer, "<") {
+		return e.newError(diagnostic.ForbiddenImport, "cannot import %q: import only works in programs run from a file", node.Path.Value)
+	}
+
+	path, problem := modulePath(importer, node.Path.Value)
+	if problem != "" {
+		return e.newError(diagnostic.ForbiddenImport, "cannot import %q: %s", node.Path.Value, problem)
+	}
+
+	// the file being run isn't a module, but a module importing it is still
+	// a cycle
+	if len(e.modules.loading) == 0 {
+		if root, err := filepath.Abs(importer); err == nil {
+			e.modules.loading = []string{root}
+			defer func() { e.modules.loading = nil }()
+		}
+	}
+
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return e.newError(diagnostic.ModuleNotFound, "cannot import %q: %s", node.Path.Value, err)
+	}
+
+	for i, loading := range e.modules.loading {
+		if loading == absPath {
+			cycle := append(append([]string{}, e.modules.loading[i:]...), absPath)
+			for j, p := range cycle {
+				cycle[j] = displayPath(p)
+			}
+			return e.newError(diagnostic.ImportCycle, "import cycle: %s", strings.Join(cycle, " -> "))
+		}
+	}
+
+	if module, ok := e.modules.cache[absPath]; ok {
+		return module
+	}
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return e.newError(diagnostic.ModuleNotFound, "cannot import %q: %s", node.Path.Value, err)
+	}
+	source := string(content)
+
+	p := parser.New(lexer.NewWithFile(source, path))
+	program := p.ParseProgram()
+	if len(p.Diagnostics()) > 0 {
+		// we point at the import rather than into the file, and don't repeat
+		// the parser's message, because a file that doesn't parse may not be
+		// a module at all, and we shouldn't show any of it. Running the file
+		// by itself will show what's wrong
+		first := p.Diagnostics()[0]
+		return e.newError(first.Code, "cannot import %q: it has a syntax error at %s", node.Path.Value, first.Span.Location())
+	}
+	// only now that we know it's a module do we keep its source around for
+	// showing errors in it
+	e.modules.sources[path] = source
+
+	moduleEnv := object.NewEnvironment()
+	e.modules.loading = append(e.modules.loading, absPath)
+	result := e.Eval(program, moduleEnv)
+	e.modules.loading = e.modules.loading[:len(e.modules.loading)-1]
+	if isError(result) {
+		return result
+	}
+
+	module := &object.Module{Name: node.Name.Value, Path: path, Env: moduleEnv}
+	e.modules.cache[absPath] = module
+
+	return module
+}
+
+// modulePath resolves an import path against the directory of the importing
+// file. Only .ok files in that directory, or below it, can be imported, so
+// the problem is returned if the path points anywhere else.
+func modulePath(importer string, path string) (string, string) {
+	if filepath.IsAbs(path) {
+		return "", "import paths must be relative to the importing file"
+	}
+	if filepath.Ext(path) != ".ok" {
+		return "", "only .ok files can be imported"
+	}
+
+	path = filepath.Clean(path)
+	if path == ".." || strings.HasPrefix(path, ".."+string(filepath.Separator)) {
+		return "", "modules must be in the importing file's directory or below it"
+	}
+
+	return filepath.Join(filepath.Dir(importer), path), ""
+}
+
+// displayPath shows a path relative to the working directory if we can
+func displayPath(path string) string {
+	wd, err := os.Getwd()
+	if err != nil {
+		return path
+	}
+	rel, err := filepath.Rel(wd, path)
+	if err != nil {
+		return path
+	}
+	return rel
+}
+
+func (e *Evaluator) evalModuleMemberAccess(
+	module *object.Module,
+	node *ast.StructMemberAccessExpression,
+) object.Object {
+	value, ok := module.Env.Get(node.MemberName)
+	if !ok {
+		return e.newError(diagnostic.UndefinedMember, "module %s has no member %s", module.Name, node.MemberName)
+	}
+
+	return value
+}
+
+// lookupStruct finds the nac being instantiated, which may live in a module.
+// The name can also be a variable holding a nac, as in
+// 'let kind = person; new kind()'.
+func (e *Evaluator) lookupStruct(
+	node *ast.StructInstantiation,
+	env *object.Environment,
+) (*object.Struct, object.Object) {
+	if node.Namespace == "" {
+		obj, ok := env.Get(node.StructName)
+		if !ok {
+			return nil, e.newError(diagnostic.UndefinedNac, "undefined nac %s", node.StructName)
+		}
+		str, ok := obj.(*object.Struct)
+		if !ok {
+			return nil, e.newError(diagnostic.UndefinedNac, "`%s` is not a nac, it's %s", node.StructName, obj.Inspect())
+		}
+		return str, nil
+	}
+
+	obj, ok := env.Get(node.Namespace)
+	if !ok {
+		return nil, e.newError(diagnostic.UndeclaredIdentifier, "identifier not found: %s", node.Namespace)
+	}
+	module, ok := obj.(*object.Module)
+	if !ok {
+		return nil, e.newError(diagnostic.UndefinedNac, "`%s` is not a module", node.Namespace)
+	}
+	str, ok := module.Env.GetStruct(node.StructName)
+	if !ok {
+		return nil, e.newError(diagnostic.UndefinedNac, "undefined nac %s in module %s", node.StructName, module.Name)
+	}
+	return str, nil
+}
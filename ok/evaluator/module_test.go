@@ -0,0 +1,239 @@
+package evaluator
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jesseduffield/OK/ok/diagnostic"
+	"github.com/jesseduffield/OK/ok/lexer"
+	"github.com/jesseduffield/OK/ok/object"
+	"github.com/jesseduffield/OK/ok/parser"
+)
+
+// writes the files into a temp directory and runs main.ok from there
+func testEvalFiles(t *testing.T, files map[string]string) object.Object {
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	mainPath := filepath.Join(dir, "main.ok")
+	p := parser.New(lexer.NewWithFile(files["main.ok"], mainPath))
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	return New(ioutil.Discard).Eval(program, object.NewEnvironment())
+}
+
+func TestImports(t *testing.T) {
+	helpers := `
+let double = fn(x) { x * 2 };
+let items = [1, 2];
+notaclass person {
+  field name
+  public init fn(selfish, name) { selfish.name = name }
+  public getname fn(selfish) { selfish.name }
+}`
+
+	tests := []struct {
+		name     string
+		main     string
+		expected interface{}
+	}{
+		{"let bindings", `import "lib/helpers.ok"; helpers.double(21)`, 42},
+		{"named import", `import h "lib/helpers.ok"; h.double(2)`, 4},
+		{"nacs", `import "lib/helpers.ok"; let p = new helpers.person(); p.init("bob"); p.getname()`, "bob"},
+		{"modules are only evaluated once", `import a "lib/helpers.ok"; import b "lib/helpers.ok"; a.items[0] = 5; b.items[0]`, 5},
+		{"module bindings don't leak", `import "lib/helpers.ok"; items`, "identifier not found: items"},
+		{"missing member", `import "lib/helpers.ok"; helpers.nope`, "module helpers has no member nope"},
+		{"missing nac", `import "lib/helpers.ok"; new helpers.nope()`, "undefined nac nope in module helpers"},
+		{"read-only from outside", `import "lib/helpers.ok"; helpers.items = 1`, "cannot assign to `items`: bindings in module helpers can only be changed from inside the module"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEvalFiles(t, map[string]string{
+			"main.ok":        tt.main,
+			"lib/helpers.ok": helpers,
+		})
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			switch obj := evaluated.(type) {
+			case *object.String:
+				if obj.Value != expected {
+					t.Errorf("%s: expected %q, got %q", tt.name, expected, obj.Value)
+				}
+			case *object.Error:
+				if obj.Diagnostic == nil || obj.Diagnostic.Message != expected {
+					t.Errorf("%s: expected error %q, got %q", tt.name, expected, obj.Message)
+				}
+			default:
+				t.Errorf("%s: unexpected result %T (%+v)", tt.name, evaluated, evaluated)
+			}
+		}
+	}
+}
+
+func TestImportErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		files    map[string]string
+		expected diagnostic.Code
+		file     string
+	}{
+		{
+			"missing file",
+			map[string]string{"main.ok": `import "nope.ok"`},
+			diagnostic.ModuleNotFound,
+			"main.ok",
+		},
+		{
+			"cycle through the main file",
+			map[string]string{"main.ok": `import "a.ok"`, "a.ok": `import "main.ok"`},
+			diagnostic.ImportCycle,
+			"a.ok",
+		},
+		{
+			"cycle between modules",
+			map[string]string{"main.ok": `import "a.ok"`, "a.ok": `import "b.ok"`, "b.ok": `import "a.ok"`},
+			diagnostic.ImportCycle,
+			"b.ok",
+		},
+		{
+			// we don't point into a file that doesn't parse, in case it isn't
+			// really a module
+			"syntax error in module",
+			map[string]string{"main.ok": `import "a.ok"`, "a.ok": `let x = ;`},
+			diagnostic.UnexpectedToken,
+			"main.ok",
+		},
+		{
+			"not an .ok file",
+			map[string]string{"main.ok": `import a "a.txt"`, "a.txt": `let x = 1;`},
+			diagnostic.ForbiddenImport,
+			"main.ok",
+		},
+		{
+			"runtime error in module",
+			map[string]string{"main.ok": `import "a.ok"`, "a.ok": `let x = 1 / 0;`},
+			diagnostic.DivisionByZero,
+			"a.ok",
+		},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEvalFiles(t, tt.files)
+		err, ok := evaluated.(*object.Error)
+		if !ok || err.Diagnostic == nil {
+			t.Errorf("%s: expected error with diagnostic, got=%T (%+v)", tt.name, evaluated, evaluated)
+			continue
+		}
+
+		if err.Diagnostic.Code != tt.expected {
+			t.Errorf("%s: expected code %s, got=%s (%s)", tt.name, tt.expected, err.Diagnostic.Code, err.Message)
+		}
+		if filepath.Base(err.Diagnostic.Span.File) != tt.file {
+			t.Errorf("%s: expected error in %s, got %s", tt.name, tt.file, err.Diagnostic.Span.File)
+		}
+	}
+}
+
+func TestImportPathsStayInTheImportingDirectory(t *testing.T) {
+	// a module that would be importable if we let the path escape
+	outside := t.TempDir()
+	outsidePath := filepath.Join(outside, "secret.ok")
+	if err := ioutil.WriteFile(outsidePath, []byte(`let x = 1;`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		files    map[string]string
+		expected string
+	}{
+		{
+			"absolute path",
+			map[string]string{"main.ok": `import secret "` + filepath.ToSlash(outsidePath) + `"`},
+			"import paths must be relative to the importing file",
+		},
+		{
+			"parent directory",
+			map[string]string{"main.ok": `import secret "../secret.ok"`},
+			"modules must be in the importing file's directory or below it",
+		},
+		{
+			"parent directory via a subdirectory",
+			map[string]string{"main.ok": `import secret "lib/../../secret.ok"`},
+			"modules must be in the importing file's directory or below it",
+		},
+		{
+			"parent directory from inside a module",
+			map[string]string{"main.ok": `import "lib/a.ok"`, "lib/a.ok": `import b "../b.ok"`, "b.ok": `let x = 1;`},
+			"modules must be in the importing file's directory or below it",
+		},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEvalFiles(t, tt.files)
+		err, ok := evaluated.(*object.Error)
+		if !ok || err.Diagnostic == nil {
+			t.Errorf("%s: expected error, got=%T (%+v)", tt.name, evaluated, evaluated)
+			continue
+		}
+
+		if err.Diagnostic.Code != diagnostic.ForbiddenImport || !strings.HasSuffix(err.Diagnostic.Message, tt.expected) {
+			t.Errorf("%s: expected %s ending in %q, got %s %q", tt.name, diagnostic.ForbiddenImport, tt.expected, err.Diagnostic.Code, err.Diagnostic.Message)
+		}
+	}
+}
+
+func TestImportNeedsAnImportingFile(t *testing.T) {
+	// e.g. a program sent to the playground
+	evaluated := testEval(t, `import "lib/helpers.ok"`)
+	err, ok := evaluated.(*object.Error)
+	if !ok || err.Diagnostic == nil {
+		t.Fatalf("expected error, got=%T (%+v)", evaluated, evaluated)
+	}
+
+	expected := `cannot import "lib/helpers.ok": import only works in programs run from a file`
+	if err.Diagnostic.Code != diagnostic.ForbiddenImport || err.Diagnostic.Message != expected {
+		t.Errorf("expected %s %q, got %s %q", diagnostic.ForbiddenImport, expected, err.Diagnostic.Code, err.Diagnostic.Message)
+	}
+}
+
+func TestUnparseableModuleSourceIsNotShown(t *testing.T) {
+	dir := t.TempDir()
+	mainPath := filepath.Join(dir, "main.ok")
+	if err := ioutil.WriteFile(filepath.Join(dir, "a.ok"), []byte("root:x:0:0:root"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	p := parser.New(lexer.NewWithFile(`import "a.ok"`, mainPath))
+	program := p.ParseProgram()
+	e := New(ioutil.Discard)
+	evaluated := e.Eval(program, object.NewEnvironment())
+
+	err, ok := evaluated.(*object.Error)
+	if !ok || err.Diagnostic == nil {
+		t.Fatalf("expected error, got=%T (%+v)", evaluated, evaluated)
+	}
+	if strings.Contains(err.Message, "root") {
+		t.Errorf("expected the error not to quote the module, got %q", err.Message)
+	}
+	if _, ok := e.ModuleSource(filepath.Join(dir, "a.ok")); ok {
+		t.Errorf("expected the module's source not to be kept")
+	}
+}
@@ -0,0 +1,480 @@
+// Package evolution works out which nacs can evolve into which without running
+// anything, by looking at the 'new X()' expressions each nac's evolve method
+// can return.
+package evolution
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jesseduffield/OK/ok/ast"
+	"github.com/jesseduffield/OK/ok/diagnostic"
+)
+
+// Graph is the becomes-a graph of a set of programs. Nacs are identified by
+// name, so nacs from different files with the same name are treated as one.
+type Graph struct {
+	// in the order they're defined
+	Nacs  []*Nac
+	Edges []Edge
+	// cycles, unreachable nacs, and evolve methods returning things we can't
+	// make sense of
+	Warnings []diagnostic.Diagnostic
+}
+
+type Nac struct {
+	Name     string
+	Location string
+	// false if there's no way to end up with an instance of the nac: it's
+	// never instantiated outside of an evolve method, and nothing that is
+	// instantiated can evolve into it
+	Reachable bool
+	InCycle   bool
+	// true for a nac from an imported module, e.g. helpers.rock, which we only
+	// know by name. Its location is where it's first instantiated.
+	External bool
+}
+
+// Edge says that From's evolve method can return a new To
+type Edge struct {
+	From     string
+	To       string
+	Location string
+}
+
+// Analyze builds the evolution graph of the given programs
+func Analyze(programs ...*ast.Program) *Graph {
+	graph := &Graph{}
+	nacs := map[string]*Nac{}
+	seen := map[Edge]bool{}
+	roots := map[string]bool{}
+	definitions := map[string]*ast.Struct{}
+
+	for _, program := range programs {
+		ast.Inspect(program, func(node ast.Node) bool {
+			switch node := node.(type) {
+			case *ast.Struct:
+				if _, ok := nacs[node.Name]; !ok {
+					nac := &Nac{Name: node.Name, Location: node.Token.Span().Location()}
+					nacs[node.Name] = nac
+					graph.Nacs = append(graph.Nacs, nac)
+					definitions[node.Name] = node
+				}
+			case *ast.StructMethod:
+				// what evolve returns isn't an instantiation that someone
+				// can get their hands on directly, so it's not a root
+				if node.Name == "evolve" {
+					return false
+				}
+			case *ast.StructInstantiation:
+				roots[qualifiedName(node)] = true
+			case *ast.Identifier:
+				// a nac's name can be passed around and instantiated
+				// elsewhere, e.g. 'make(rock)', so we give it the benefit of
+				// the doubt
+				roots[node.Value] = true
+			}
+			return true
+		})
+	}
+
+	for _, nac := range graph.Nacs {
+		str := definitions[nac.Name]
+		method, ok := str.Methods["evolve"]
+		if !ok {
+			continue
+		}
+
+		for _, result := range returnedExpressions(method.FunctionLiteral.Body) {
+			switch result := result.(type) {
+			case *ast.StructInstantiation:
+				if _, ok := definitions[result.StructName]; !ok && result.Namespace == "" {
+					graph.Warnings = append(graph.Warnings, warning(
+						diagnostic.UnknownEvolution,
+						result,
+						fmt.Sprintf(
+							"evolve method of %s returns %s, but %s isn't a nac we know of, so we can't tell what it evolves into",
+							nac.Name,
+							result.String(),
+							result.StructName,
+						),
+					))
+					continue
+				}
+
+				edge := Edge{From: nac.Name, To: qualifiedName(result)}
+				if seen[edge] {
+					continue
+				}
+				seen[edge] = true
+				if result.Namespace != "" && nacs[edge.To] == nil {
+					external := &Nac{Name: edge.To, Location: result.Token.Span().Location(), External: true}
+					nacs[edge.To] = external
+					graph.Nacs = append(graph.Nacs, external)
+				}
+				edge.Location = result.Token.Span().Location()
+				graph.Edges = append(graph.Edges, edge)
+			case *ast.NullLiteral:
+				// not evolving is always an option
+			default:
+				graph.Warnings = append(graph.Warnings, warning(
+					diagnostic.UnknownEvolution,
+					result,
+					fmt.Sprintf(
+						"evolve method of %s returns %s, which isn't a nac instantiation, so we can't tell what it evolves into",
+						nac.Name,
+						result.String(),
+					),
+				))
+			}
+		}
+	}
+
+	graph.markReachable(roots)
+	graph.findCycles(definitions)
+
+	for _, nac := range graph.Nacs {
+		// a module's nacs are the module's business
+		if !nac.Reachable && !nac.External {
+			graph.Warnings = append(graph.Warnings, warning(
+				diagnostic.UnreachableNac,
+				definitions[nac.Name],
+				fmt.Sprintf("%s is never instantiated, and nothing evolves into it", nac.Name),
+			))
+		}
+	}
+
+	sort.SliceStable(graph.Warnings, func(i, j int) bool {
+		a, b := graph.Warnings[i].Span, graph.Warnings[j].Span
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		return a.Start < b.Start
+	})
+
+	return graph
+}
+
+// qualifiedName is the name we know an instantiated nac by, including the
+// module it's from, e.g. helpers.rock
+func qualifiedName(node *ast.StructInstantiation) string {
+	if node.Namespace == "" {
+		return node.StructName
+	}
+
+	return node.Namespace + "." + node.StructName
+}
+
+// returnedExpressions collects the expressions an evolve method body can
+// return: anything after a 'return', plus the value of the body's last
+// statement. Ifs and switches are looked inside of, and nested functions are
+// skipped, because their returns aren't ours.
+func returnedExpressions(body *ast.BlockStatement) []ast.Expression {
+	results := []ast.Expression{}
+
+	ast.Inspect(body, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.FunctionLiteral:
+			return false
+		case *ast.ReturnStatement:
+			results = append(results, tails(node.ReturnValue)...)
+		}
+		return true
+	})
+
+	return append(results, blockTails(body)...)
+}
+
+// tails returns the expressions that an expression's value can come from
+func tails(exp ast.Expression) []ast.Expression {
+	switch exp := exp.(type) {
+	case *ast.IfExpression:
+		return append(blockTails(exp.Consequence), blockTails(exp.Alternative)...)
+	case *ast.SwitchExpression:
+		results := []ast.Expression{}
+		for _, c := range exp.Cases {
+			results = append(results, blockTails(c.Block)...)
+		}
+		return append(results, blockTails(exp.Default)...)
+	case nil:
+		return nil
+	default:
+		return []ast.Expression{exp}
+	}
+}
+
+// blockTails returns the expressions a block's value can come from. A block
+// ending in a return has already been dealt with.
+func blockTails(block *ast.BlockStatement) []ast.Expression {
+	if block == nil || len(block.Statements) == 0 {
+		return nil
+	}
+
+	statement, ok := block.Statements[len(block.Statements)-1].(*ast.ExpressionStatement)
+	if !ok {
+		return nil
+	}
+
+	return tails(statement.Expression)
+}
+
+func (self *Graph) markReachable(roots map[string]bool) {
+	reachable := map[string]bool{}
+	queue := []string{}
+	for _, nac := range self.Nacs {
+		if roots[nac.Name] {
+			reachable[nac.Name] = true
+			queue = append(queue, nac.Name)
+		}
+	}
+
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		for _, edge := range self.Edges {
+			if edge.From == name && !reachable[edge.To] {
+				reachable[edge.To] = true
+				queue = append(queue, edge.To)
+			}
+		}
+	}
+
+	for _, nac := range self.Nacs {
+		nac.Reachable = reachable[nac.Name]
+	}
+}
+
+// findCycles flags every group of nacs that can evolve back into themselves,
+// using Tarjan's algorithm to find the strongly connected components
+func (self *Graph) findCycles(definitions map[string]*ast.Struct) {
+	index := map[string]int{}
+	lowlink := map[string]int{}
+	onStack := map[string]bool{}
+	stack := []string{}
+	components := [][]string{}
+
+	var connect func(name string)
+	connect = func(name string) {
+		index[name] = len(index)
+		lowlink[name] = index[name]
+		stack = append(stack, name)
+		onStack[name] = true
+
+		for _, next := range self.successors(name) {
+			if _, visited := index[next]; !visited {
+				connect(next)
+				if lowlink[next] < lowlink[name] {
+					lowlink[name] = lowlink[next]
+				}
+			} else if onStack[next] && index[next] < lowlink[name] {
+				lowlink[name] = index[next]
+			}
+		}
+
+		if lowlink[name] == index[name] {
+			component := []string{}
+			for {
+				last := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				onStack[last] = false
+				component = append(component, last)
+				if last == name {
+					break
+				}
+			}
+			components = append(components, component)
+		}
+	}
+
+	for _, nac := range self.Nacs {
+		if _, visited := index[nac.Name]; !visited {
+			connect(nac.Name)
+		}
+	}
+
+	for _, component := range components {
+		members := map[string]bool{}
+		for _, name := range component {
+			members[name] = true
+		}
+
+		// starting from whichever member was defined first, so that the
+		// output doesn't depend on the order we happened to visit them in
+		var start *Nac
+		for _, nac := range self.Nacs {
+			if members[nac.Name] {
+				start = nac
+				break
+			}
+		}
+		if start == nil {
+			continue
+		}
+
+		path := self.cycleFrom(start.Name, members)
+		if path == nil {
+			continue
+		}
+
+		for _, nac := range self.Nacs {
+			if members[nac.Name] {
+				nac.InCycle = true
+			}
+		}
+
+		self.Warnings = append(self.Warnings, warning(
+			diagnostic.EvolutionCycle,
+			definitions[start.Name],
+			fmt.Sprintf("%s can evolve in a cycle: %s", start.Name, strings.Join(path, " -> ")),
+		))
+	}
+}
+
+// cycleFrom returns a path from start back to itself through the given nacs,
+// or nil if there isn't one
+func (self *Graph) cycleFrom(start string, members map[string]bool) []string {
+	visited := map[string]bool{}
+
+	var search func(name string, path []string) []string
+	search = func(name string, path []string) []string {
+		for _, next := range self.successors(name) {
+			if next == start {
+				return append(path, next)
+			}
+			if members[next] && !visited[next] {
+				visited[next] = true
+				if found := search(next, append(path, next)); found != nil {
+					return found
+				}
+			}
+		}
+		return nil
+	}
+
+	return search(start, []string{start})
+}
+
+func (self *Graph) successors(name string) []string {
+	result := []string{}
+	for _, edge := range self.Edges {
+		if edge.From == name {
+			result = append(result, edge.To)
+		}
+	}
+	return result
+}
+
+func (self *Graph) nac(name string) *Nac {
+	for _, nac := range self.Nacs {
+		if nac.Name == name {
+			return nac
+		}
+	}
+	return nil
+}
+
+// DOT renders the graph in Graphviz's DOT language. Nacs that can't be reached
+// are dashed, cycles are red, and nacs from imported modules are boxes.
+func (self *Graph) DOT() string {
+	var out bytes.Buffer
+
+	out.WriteString("digraph evolutions {\n")
+	for _, nac := range self.Nacs {
+		attributes := []string{}
+		if !nac.Reachable {
+			attributes = append(attributes, "style=dashed")
+		}
+		if nac.InCycle {
+			attributes = append(attributes, "color=red")
+		}
+		if nac.External {
+			attributes = append(attributes, "shape=box")
+		}
+
+		out.WriteString("  " + strconv.Quote(nac.Name))
+		if len(attributes) > 0 {
+			out.WriteString(" [" + strings.Join(attributes, ", ") + "]")
+		}
+		out.WriteString(";\n")
+	}
+	for _, edge := range self.Edges {
+		out.WriteString("  " + strconv.Quote(edge.From) + " -> " + strconv.Quote(edge.To))
+		from, to := self.nac(edge.From), self.nac(edge.To)
+		if from != nil && to != nil && from.InCycle && to.InCycle {
+			out.WriteString(" [color=red]")
+		}
+		out.WriteString(";\n")
+	}
+	out.WriteString("}\n")
+
+	return out.String()
+}
+
+type jsonGraph struct {
+	Nacs     []jsonNac     `json:"nacs"`
+	Edges    []jsonEdge    `json:"edges"`
+	Warnings []jsonWarning `json:"warnings"`
+}
+
+type jsonNac struct {
+	Name      string `json:"name"`
+	Location  string `json:"location"`
+	Reachable bool   `json:"reachable"`
+	InCycle   bool   `json:"inCycle"`
+	External  bool   `json:"external,omitempty"`
+}
+
+type jsonEdge struct {
+	From     string `json:"from"`
+	To       string `json:"to"`
+	Location string `json:"location"`
+}
+
+type jsonWarning struct {
+	Code     string `json:"code"`
+	Location string `json:"location"`
+	Message  string `json:"message"`
+}
+
+// JSON renders the graph as indented JSON
+func (self *Graph) JSON() string {
+	graph := jsonGraph{Nacs: []jsonNac{}, Edges: []jsonEdge{}, Warnings: []jsonWarning{}}
+	for _, nac := range self.Nacs {
+		graph.Nacs = append(graph.Nacs, jsonNac(*nac))
+	}
+	for _, edge := range self.Edges {
+		graph.Edges = append(graph.Edges, jsonEdge(edge))
+	}
+	for _, warning := range self.Warnings {
+		graph.Warnings = append(graph.Warnings, jsonWarning{
+			Code:     string(warning.Code),
+			Location: warning.Span.Location(),
+			Message:  warning.Message,
+		})
+	}
+
+	var out bytes.Buffer
+	encoder := json.NewEncoder(&out)
+	// the cycle messages contain arrows, which we'd rather not see as \u003e
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	// we're only encoding strings and bools, so this can't fail
+	_ = encoder.Encode(graph)
+
+	return out.String()
+}
+
+func warning(code diagnostic.Code, node ast.Node, message string) diagnostic.Diagnostic {
+	return diagnostic.Diagnostic{
+		Severity: diagnostic.Warning,
+		Code:     code,
+		Span:     node.Span(),
+		Location: fmt.Sprintf("%s (%s)", node.GetToken().Location(), node.GetToken().Literal),
+		Message:  message,
+		Link:     code.Link(),
+	}
+}
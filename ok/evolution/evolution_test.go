@@ -0,0 +1,253 @@
+package evolution
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jesseduffield/OK/ok/ast"
+	"github.com/jesseduffield/OK/ok/lexer"
+	"github.com/jesseduffield/OK/ok/parser"
+)
+
+func TestAnalyze(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		edges    []string
+		warnings []string
+	}{
+		{
+			"a chain of evolutions",
+			`
+notaclass rock {}
+notaclass brgousie {
+  evolve fn(selfish) { switch selfish.name { case "": new rock(); default: NO! } }
+}
+notaclass person {
+  evolve fn(selfish) {
+    if (selfish.likeclas) {
+      return new brgousie();
+    }
+    NO!
+  }
+}
+let p = new person();`,
+			[]string{"brgousie -> rock", "person -> brgousie"},
+			[]string{},
+		},
+		{
+			"repeated returns make one edge",
+			`
+notaclass rock {}
+notaclass person {
+  evolve fn(selfish) { if (selfish.a) { return new rock() }; if (selfish.b) { return new rock() }; NO! }
+}
+let p = new person();`,
+			[]string{"person -> rock"},
+			[]string{},
+		},
+		{
+			"returns inside nested functions aren't ours",
+			`
+notaclass rock {}
+notaclass person {
+  evolve fn(selfish) { let f = fn() { return new rock() }; NO! }
+}
+let p = new person();`,
+			[]string{},
+			[]string{"OK201 line 2, column 1: rock is never instantiated, and nothing evolves into it"},
+		},
+		{
+			"a cycle",
+			`
+notaclass person {
+  evolve fn(selfish) { new brgousie() }
+}
+notaclass brgousie {
+  evolve fn(selfish) { new person() }
+}
+let p = new person();`,
+			[]string{"person -> brgousie", "brgousie -> person"},
+			[]string{"OK200 line 2, column 1: person can evolve in a cycle: person -> brgousie -> person"},
+		},
+		{
+			"evolving into yourself is a cycle",
+			`
+notaclass person {
+  evolve fn(selfish) { new person() }
+}
+let p = new person();`,
+			[]string{"person -> person"},
+			[]string{"OK200 line 2, column 1: person can evolve in a cycle: person -> person"},
+		},
+		{
+			"evolve returning something other than a new nac",
+			`
+notaclass person {
+  evolve fn(selfish) { return selfish.next }
+}
+let p = new person();`,
+			[]string{},
+			[]string{"OK202 line 3, column 31: evolve method of person returns selfish.next, which isn't a nac instantiation, so we can't tell what it evolves into"},
+		},
+		{
+			"evolve instantiating a nac held in a variable",
+			`
+notaclass rock {}
+notaclass person {
+  field next = rock
+  evolve fn(selfish) { let kind = selfish.next; new kind() }
+}
+let p = new person();`,
+			[]string{},
+			[]string{"OK202 line 5, column 49: evolve method of person returns new kind(), but kind isn't a nac we know of, so we can't tell what it evolves into"},
+		},
+		{
+			"evolving into a nac from a module",
+			`
+notaclass person {
+  evolve fn(selfish) { new helpers.rock() }
+}
+notaclass rock {}
+let p = new person();`,
+			[]string{"person -> helpers.rock"},
+			[]string{"OK201 line 5, column 1: rock is never instantiated, and nothing evolves into it"},
+		},
+		{
+			"passing a nac around makes it reachable",
+			`
+notaclass rock {}
+let make = fn(kind) { new kind() };
+make(rock);`,
+			[]string{},
+			[]string{},
+		},
+		{
+			"instantiating a nac in another nac's method makes it reachable",
+			`
+notaclass rock {}
+notaclass person {
+  public throw fn(selfish) { new rock() }
+}
+let p = new person();`,
+			[]string{},
+			[]string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			graph := Analyze(parse(t, tt.input))
+
+			edges := []string{}
+			for _, edge := range graph.Edges {
+				edges = append(edges, edge.From+" -> "+edge.To)
+			}
+			if strings.Join(edges, "\n") != strings.Join(tt.edges, "\n") {
+				t.Errorf("wrong edges. expected=%q, got=%q", tt.edges, edges)
+			}
+
+			warnings := []string{}
+			for _, warning := range graph.Warnings {
+				warnings = append(warnings, string(warning.Code)+" "+warning.Span.Location()+": "+warning.Message)
+			}
+			if strings.Join(warnings, "\n") != strings.Join(tt.warnings, "\n") {
+				t.Errorf("wrong warnings. expected=%q, got=%q", tt.warnings, warnings)
+			}
+		})
+	}
+}
+
+func TestDOT(t *testing.T) {
+	input := `
+notaclass person {
+  evolve fn(selfish) { new brgousie() }
+}
+notaclass brgousie {
+  evolve fn(selfish) { new person() }
+}
+notaclass ghost {
+  evolve fn(selfish) { new helpers.rock() }
+}
+let p = new person();`
+
+	expected := `digraph evolutions {
+  "person" [color=red];
+  "brgousie" [color=red];
+  "ghost" [style=dashed];
+  "helpers.rock" [style=dashed, shape=box];
+  "person" -> "brgousie" [color=red];
+  "brgousie" -> "person" [color=red];
+  "ghost" -> "helpers.rock";
+}
+`
+
+	got := Analyze(parse(t, input)).DOT()
+	if got != expected {
+		t.Errorf("wrong DOT. expected=\n%s\ngot=\n%s", expected, got)
+	}
+}
+
+func TestJSON(t *testing.T) {
+	input := `
+notaclass rock {}
+notaclass person {
+  evolve fn(selfish) { switch selfish.a { case true: new rock(); default: new helpers.rock() } }
+}
+let p = new person();`
+
+	expected := `{
+  "nacs": [
+    {
+      "name": "rock",
+      "location": "line 2, column 1",
+      "reachable": true,
+      "inCycle": false
+    },
+    {
+      "name": "person",
+      "location": "line 3, column 1",
+      "reachable": true,
+      "inCycle": false
+    },
+    {
+      "name": "helpers.rock",
+      "location": "line 4, column 75",
+      "reachable": true,
+      "inCycle": false,
+      "external": true
+    }
+  ],
+  "edges": [
+    {
+      "from": "person",
+      "to": "rock",
+      "location": "line 4, column 54"
+    },
+    {
+      "from": "person",
+      "to": "helpers.rock",
+      "location": "line 4, column 75"
+    }
+  ],
+  "warnings": []
+}
+`
+
+	got := Analyze(parse(t, input)).JSON()
+	if got != expected {
+		t.Errorf("wrong JSON. expected=\n%s\ngot=\n%s", expected, got)
+	}
+}
+
+func parse(t *testing.T, input string) *ast.Program {
+	t.Helper()
+
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	for _, d := range p.Diagnostics() {
+		t.Fatalf("parser error: %s", d.Message)
+	}
+
+	return program
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/jesseduffield/OK/ok/diagnostic"
+	"github.com/jesseduffield/OK/ok/evolution"
+)
+
+// runEvolutions implements 'ok evolutions', which prints the graph of which
+// nacs can evolve into which. Returns the exit code: 1 if any file fails to
+// parse or the graph has anything to warn about.
+func runEvolutions(args []string) int {
+	flags := flag.NewFlagSet("evolutions", flag.ContinueOnError)
+	asJSON := flags.Bool("json", false, "print the graph as JSON instead of DOT")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: ok evolutions [--json] file ...")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+
+	programs, sources, ok := parseFiles(flags.Args())
+	if !ok {
+		return 1
+	}
+
+	graph := evolution.Analyze(programs...)
+	if *asJSON {
+		fmt.Print(graph.JSON())
+	} else {
+		fmt.Print(graph.DOT())
+	}
+
+	// the warnings are included in the JSON, but we print them anyway so that
+	// you don't need to dig through it to find out why we exited with 1
+	for i, warning := range graph.Warnings {
+		if i > 0 {
+			fmt.Fprintln(os.Stderr)
+		}
+		diagnostic.Print(os.Stderr, []diagnostic.Diagnostic{warning}, sources[warning.Span.File])
+	}
+
+	if len(graph.Warnings) > 0 {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/jesseduffield/OK/ok/diagnostic"
+	"github.com/jesseduffield/OK/ok/format"
+	"github.com/jesseduffield/OK/ok/lexer"
+	"github.com/jesseduffield/OK/ok/parser"
+)
+
+// runFmt implements 'ok fmt'. With no files, it formats stdin to stdout.
+// Returns the exit code.
+func runFmt(args []string) int {
+	flags := flag.NewFlagSet("fmt", flag.ContinueOnError)
+	write := flags.Bool("w", false, "write the formatted source back to each file instead of printing it")
+	check := flags.Bool("check", false, "list the files that aren't formatted, exiting with status 1 if there are any")
+	fix := flags.Bool("fix", false, "apply the fixes suggested for any errors, e.g. shortening identifiers, before formatting")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: ok fmt [-w | --check] [--fix] [file ...]")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if *write && *check {
+		fmt.Fprintln(os.Stderr, "ok fmt: -w and --check can't be used together")
+		return 2
+	}
+
+	if flags.NArg() == 0 {
+		if *write {
+			fmt.Fprintln(os.Stderr, "ok fmt: -w needs at least one file")
+			return 2
+		}
+
+		source, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+
+		formatted, ok := formatSource(string(source), "<stdin>", *fix)
+		if !ok {
+			return 1
+		}
+		if *check {
+			if formatted != string(source) {
+				fmt.Println("<stdin>")
+				return 1
+			}
+			return 0
+		}
+		fmt.Print(formatted)
+		return 0
+	}
+
+	exitCode := 0
+	for _, filename := range flags.Args() {
+		source, err := ioutil.ReadFile(filename)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			exitCode = 1
+			continue
+		}
+
+		formatted, ok := formatSource(string(source), filename, *fix)
+		if !ok {
+			exitCode = 1
+			continue
+		}
+
+		switch {
+		case *check:
+			if formatted != string(source) {
+				fmt.Println(filename)
+				exitCode = 1
+			}
+		case *write:
+			if formatted == string(source) {
+				continue
+			}
+			if err := ioutil.WriteFile(filename, []byte(formatted), 0o644); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				exitCode = 1
+			}
+		default:
+			fmt.Print(formatted)
+		}
+	}
+
+	return exitCode
+}
+
+// formatSource prints any parser diagnostics to stderr and returns false if
+// the source couldn't be formatted. With fix, we first apply whatever fixes
+// the diagnostics suggest.
+func formatSource(source string, filename string, fix bool) (string, bool) {
+	if fix {
+		source = fixSource(source, filename)
+	}
+
+	program, ok := parseSource(source, filename)
+	if !ok {
+		return "", false
+	}
+
+	return format.Program(program, source), true
+}
+
+// fixSource applies the fixes suggested by the parser's diagnostics, for as
+// long as that gets us anywhere
+func fixSource(source string, filename string) string {
+	for {
+		p := parser.New(lexer.NewWithFile(source, filename))
+		p.ParseProgram()
+
+		fixed := diagnostic.ApplyFixes(source, p.Diagnostics())
+		if fixed == source {
+			return source
+		}
+		source = fixed
+	}
+}
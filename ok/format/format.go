@@ -0,0 +1,698 @@
+// Package format lays out OK? source code in its one true style. There are no
+// options: if you don't like how your code looks after formatting, you can
+// take that up with the formatter.
+package format
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+
+	"github.com/jesseduffield/OK/ok/ast"
+	"github.com/jesseduffield/OK/ok/diagnostic"
+	"github.com/jesseduffield/OK/ok/lexer"
+	"github.com/jesseduffield/OK/ok/parser"
+	"github.com/jesseduffield/OK/ok/token"
+)
+
+const indentation = "  "
+
+// Source formats the given source code. We refuse to format code that doesn't
+// parse, in which case the parser's diagnostics are returned instead.
+func Source(source string, filename string) (string, []diagnostic.Diagnostic) {
+	l := lexer.NewWithFile(source, filename)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if len(p.Diagnostics()) > 0 {
+		return "", p.Diagnostics()
+	}
+
+	return Program(program, source), nil
+}
+
+// Program formats an already-parsed program. The source is needed for
+// literals, which we print exactly as they were written, and for working out
+// where the blank lines were.
+func Program(program *ast.Program, source string) string {
+	pr := newPrinter(source)
+	pr.comments = program.Comments
+	if len(program.Statements) == 0 {
+		return ""
+	}
+
+	pr.statements(program.Statements)
+	pr.trailingComments(len(source))
+	pr.out.WriteString("\n")
+
+	return pr.out.String()
+}
+
+type printer struct {
+	source string
+	out    bytes.Buffer
+	indent int
+	// byte offset of the start of each line of the source
+	lineStarts []int
+	// the block comments from inside expressions that we've yet to print, in
+	// the order they appear in the source
+	comments []token.Token
+}
+
+func newPrinter(source string) *printer {
+	lineStarts := []int{0}
+	for i := 0; i < len(source); i++ {
+		if source[i] == '\n' {
+			lineStarts = append(lineStarts, i+1)
+		}
+	}
+
+	return &printer{source: source, lineStarts: lineStarts}
+}
+
+func (self *printer) write(strs ...string) {
+	for _, str := range strs {
+		self.out.WriteString(str)
+	}
+}
+
+func (self *printer) newline() {
+	self.out.WriteString("\n")
+	self.out.WriteString(strings.Repeat(indentation, self.indent))
+}
+
+// raw returns the node's text exactly as it appears in the source
+func (self *printer) raw(node ast.Node) string {
+	span := node.Span()
+	if span.Start < 0 || span.End > len(self.source) || span.Start > span.End {
+		return node.String()
+	}
+	return self.source[span.Start:span.End]
+}
+
+// leadingComments prints any block comments that came before the given offset,
+// each followed by a space, e.g. the comment in 'let x = /* five */ 5'
+func (self *printer) leadingComments(offset int) {
+	for len(self.comments) > 0 && self.comments[0].Offset < offset {
+		self.write(self.comments[0].Literal, " ")
+		self.comments = self.comments[1:]
+	}
+}
+
+// trailingComments is like leadingComments, but for comments that came at the
+// end of an expression, e.g. 'f(x /* the x */)'. There's nothing after these to
+// attach them to, so they go after the statement, as if they'd trailed it.
+func (self *printer) trailingComments(offset int) {
+	for len(self.comments) > 0 && self.comments[0].Offset < offset {
+		self.write(" ", self.comments[0].Literal)
+		self.comments = self.comments[1:]
+	}
+}
+
+// lineOf returns the 0-based line of the given byte offset
+func (self *printer) lineOf(offset int) int {
+	return sort.Search(len(self.lineStarts), func(i int) bool {
+		return self.lineStarts[i] > offset
+	}) - 1
+}
+
+func (self *printer) startLine(node ast.Node) int {
+	switch node := node.(type) {
+	case *ast.LetStatement:
+		if node.Doc != nil {
+			return node.Doc.Token.Line
+		}
+	case *ast.Struct:
+		if node.Doc != nil {
+			return node.Doc.Token.Line
+		}
+	case *ast.Interface:
+		if node.Doc != nil {
+			return node.Doc.Token.Line
+		}
+	}
+	return self.lineOf(node.Span().Start)
+}
+
+func (self *printer) endLine(node ast.Node) int {
+	end := node.Span().End
+	if end > 0 {
+		end--
+	}
+	return self.lineOf(end)
+}
+
+// nodes prints a list of statements or nac members, one per line, starting at
+// the current position. A single blank line is kept wherever the source had
+// one or more, and comments that trailed a line in the source stay there.
+func (self *printer) nodes(nodes []ast.Node, print func(node ast.Node, next ast.Node)) {
+	for i, node := range nodes {
+		var next ast.Node
+		if i < len(nodes)-1 {
+			next = nodes[i+1]
+		}
+
+		if i > 0 {
+			prev := nodes[i-1]
+			if comment, ok := node.(*ast.CommentStatement); ok && comment.Token.Line == self.endLine(prev) {
+				self.write(" ")
+				self.comment(comment)
+				continue
+			}
+
+			if self.startLine(node)-self.endLine(prev) > 1 {
+				self.write("\n")
+			}
+			self.newline()
+		}
+
+		print(node, next)
+	}
+}
+
+func (self *printer) statements(statements []ast.Statement) {
+	nodes := make([]ast.Node, len(statements))
+	for i, statement := range statements {
+		nodes[i] = statement
+	}
+
+	self.nodes(nodes, func(node ast.Node, next ast.Node) {
+		self.statement(node.(ast.Statement))
+		if needsSemicolon(node.(ast.Statement), next) {
+			self.write(";")
+		}
+		self.trailingComments(node.Span().End)
+	})
+}
+
+// needsSemicolon says whether we should end a statement with a semicolon. We
+// always do, except after an if or switch statement, where it just looks
+// silly. That is, unless the next statement would otherwise be parsed as a
+// continuation of the if or switch, e.g. if it starts with a '('.
+func needsSemicolon(statement ast.Statement, next ast.Node) bool {
+	switch statement := statement.(type) {
+	case *ast.LetStatement, *ast.ReturnStatement, *ast.ImportStatement:
+		return true
+	case *ast.ExpressionStatement:
+		switch statement.Expression.(type) {
+		case *ast.IfExpression, *ast.SwitchExpression:
+			if next == nil {
+				return false
+			}
+			_, continues := continuationTokens[next.GetToken().Type]
+			return continues
+		}
+		return true
+	default:
+		return false
+	}
+}
+
+// these are the tokens which, when they follow a complete expression, are
+// parsed as part of that expression
+var continuationTokens = map[token.TokenType]bool{
+	token.PLUS:     true,
+	token.MINUS:    true,
+	token.SLASH:    true,
+	token.ASTERISK: true,
+	token.PERCENT:  true,
+	token.GTEQ:     true,
+	token.AND:      true,
+	token.OR:       true,
+	token.ASSIGN:   true,
+	token.LPAREN:   true,
+	token.LBRACKET: true,
+	token.PERIOD:   true,
+}
+
+func (self *printer) statement(statement ast.Statement) {
+	switch statement := statement.(type) {
+	case *ast.LetStatement:
+		self.docComment(statement.Doc)
+		self.write("let ")
+		self.pattern(statement.Target())
+		self.write(" = ")
+		self.expression(statement.Value)
+	case *ast.ImportStatement:
+		self.write("import ")
+		if statement.Name.Token.Type == token.IDENT {
+			self.write(statement.Name.Value, " ")
+		}
+		self.write(self.raw(statement.Path))
+	case *ast.ReturnStatement:
+		self.write("return")
+		if statement.ReturnValue != nil {
+			self.write(" ")
+			self.expression(statement.ReturnValue)
+		}
+	case *ast.ExpressionStatement:
+		self.expression(statement.Expression)
+	case *ast.CommentStatement:
+		self.comment(statement)
+	case *ast.DocComment:
+		self.docLines(statement)
+	case *ast.Struct:
+		self.docComment(statement.Doc)
+		self.nac(statement)
+	case *ast.Interface:
+		self.docComment(statement.Doc)
+		self.iface(statement)
+	case *ast.BlockStatement:
+		self.block(statement)
+	default:
+		self.write(statement.String())
+	}
+}
+
+func (self *printer) pattern(pattern ast.Pattern) {
+	switch pattern := pattern.(type) {
+	case *ast.Identifier:
+		self.write(pattern.Value)
+	case *ast.ArrayPattern:
+		self.write("[")
+		for i, element := range pattern.Elements {
+			if i > 0 {
+				self.write(", ")
+			}
+			self.pattern(element)
+		}
+		self.write("]")
+	case *ast.HashPattern:
+		self.write("{")
+		for i, pair := range pattern.Pairs {
+			if i > 0 {
+				self.write(", ")
+			}
+			self.expression(pair.Key)
+			self.write(": ")
+			self.pattern(pair.Value)
+		}
+		self.write("}")
+	}
+}
+
+func (self *printer) comment(comment *ast.CommentStatement) {
+	self.write(strings.TrimRight(comment.Token.Literal, " \t\r"))
+}
+
+// docComment prints a doc comment on the lines above a declaration
+func (self *printer) docComment(doc *ast.DocComment) {
+	if doc == nil {
+		return
+	}
+	self.docLines(doc)
+	self.newline()
+}
+
+func (self *printer) docLines(doc *ast.DocComment) {
+	for i, line := range doc.Lines {
+		if i > 0 {
+			self.newline()
+		}
+		self.write(strings.TrimRight("/// "+line, " \t\r"))
+	}
+}
+
+func (self *printer) nac(nac *ast.Struct) {
+	self.write("notaclass ", nac.Name)
+	for i, name := range nac.Interfaces {
+		if i == 0 {
+			self.write(" implements ")
+		} else {
+			self.write(", ")
+		}
+		self.expression(name)
+	}
+	self.write(" {")
+
+	if nac.PrivacyAcknowledgement == "" && len(nac.Members) == 0 {
+		self.write("}")
+		return
+	}
+
+	self.indent++
+	if nac.PrivacyAcknowledgement != "" {
+		self.newline()
+		self.write("pack ", quote(nac.PrivacyAcknowledgement))
+		if len(nac.Members) > 0 {
+			self.write("\n")
+		}
+	}
+
+	if len(nac.Members) > 0 {
+		self.newline()
+
+		nodes := make([]ast.Node, len(nac.Members))
+		for i, member := range nac.Members {
+			nodes[i] = member
+		}
+		self.nodes(nodes, func(node ast.Node, _ ast.Node) {
+			self.member(node)
+			self.trailingComments(node.Span().End)
+		})
+	}
+	self.indent--
+
+	self.newline()
+	self.write("}")
+}
+
+func (self *printer) iface(iface *ast.Interface) {
+	self.write("notaninterface ", iface.Name, " {")
+
+	if len(iface.Members) == 0 {
+		self.write("}")
+		return
+	}
+
+	self.indent++
+	self.newline()
+	self.nodes(iface.Members, func(node ast.Node, _ ast.Node) {
+		self.member(node)
+	})
+	self.indent--
+
+	self.newline()
+	self.write("}")
+}
+
+func (self *printer) member(member ast.Node) {
+	switch member := member.(type) {
+	case *ast.StructField:
+		if member.Public {
+			self.write("public ")
+		}
+		self.write("field ", member.Name)
+		if member.Default != nil {
+			self.write(" = ")
+			self.expression(member.Default)
+		}
+	case *ast.StructMethod:
+		if member.Public {
+			self.write("public ")
+		}
+		self.write(member.Name, " ")
+		self.expression(member.FunctionLiteral)
+	case *ast.StructPack:
+		self.write("pack ", member.Field.Value, " ", quote(member.Acknowledgement))
+	case *ast.StructCarry:
+		self.write("carry ")
+		for i, field := range member.Fields {
+			if i > 0 {
+				self.write(", ")
+			}
+			self.write(field.Value)
+		}
+		self.write(" into ", member.Target)
+	case *ast.InterfaceMethod:
+		self.write(member.Name, "(")
+		for i, param := range member.Parameters {
+			if i > 0 {
+				self.write(", ")
+			}
+			if param.Rest {
+				self.write("...")
+			}
+			self.write(param.Name.Value)
+		}
+		self.write(")")
+	case *ast.CommentStatement:
+		self.comment(member)
+	case *ast.DocComment:
+		self.docLines(member)
+	}
+}
+
+// block prints a block in braces. A block with a single statement is kept on
+// one line if that's how it was written, e.g. 'fn(x) { x * 2 }'.
+func (self *printer) block(block *ast.BlockStatement) {
+	if len(block.Statements) == 0 {
+		self.write("{}")
+		return
+	}
+
+	if len(block.Statements) == 1 && block.Token.Line == self.endLine(block) {
+		if inline, ok := self.inlineStatement(block.Statements[0]); ok {
+			self.write("{ ", inline, " }")
+			return
+		}
+	}
+
+	self.write("{")
+	self.indent++
+	self.newline()
+	self.statements(block.Statements)
+	self.indent--
+	self.newline()
+	self.write("}")
+}
+
+// inlineStatement returns the statement formatted on a single line, if that's
+// possible
+func (self *printer) inlineStatement(statement ast.Statement) (string, bool) {
+	switch statement.(type) {
+	case *ast.CommentStatement, *ast.DocComment:
+		return "", false
+	}
+
+	sub := &printer{source: self.source, lineStarts: self.lineStarts, comments: self.comments}
+	sub.statement(statement)
+	str := sub.out.String()
+	if strings.Contains(str, "\n") {
+		return "", false
+	}
+	self.comments = sub.comments
+
+	return str, true
+}
+
+func (self *printer) switchExpression(exp *ast.SwitchExpression) {
+	self.write("switch ")
+	self.expression(exp.Subject)
+	self.write(" {")
+
+	self.indent++
+	for _, switchCase := range exp.Cases {
+		self.newline()
+		self.write("case ")
+		for i, value := range switchCase.Values {
+			if i > 0 {
+				self.write(", ")
+			}
+			self.expression(value)
+		}
+		self.write(":")
+		self.switchBlock(switchCase.Block)
+	}
+	if exp.Default != nil {
+		self.newline()
+		self.write("default:")
+		self.switchBlock(exp.Default)
+	}
+	self.indent--
+
+	self.newline()
+	self.write("}")
+}
+
+// switchBlock prints the statement of a case on the same line as the case,
+// unless there are comments above it, in which case everything goes on the
+// lines below.
+func (self *printer) switchBlock(block *ast.BlockStatement) {
+	if len(block.Statements) == 0 {
+		return
+	}
+
+	self.indent++
+	switch block.Statements[0].(type) {
+	case *ast.CommentStatement, *ast.DocComment:
+		self.newline()
+	default:
+		self.write(" ")
+	}
+	self.statements(block.Statements)
+	self.indent--
+}
+
+func (self *printer) expression(exp ast.Expression) {
+	self.leadingComments(exp.Span().Start)
+
+	switch exp := exp.(type) {
+	case *ast.Identifier, *ast.IntegerLiteral, *ast.FloatLiteral, *ast.StringLiteral,
+		*ast.InterpolatedString, *ast.Boolean, *ast.NullLiteral:
+		self.write(self.raw(exp))
+	case *ast.PrefixExpression:
+		self.write(exp.Operator)
+		self.operand(exp.Right, precedence(exp.Right) < parser.PREFIX)
+	case *ast.LazyExpression:
+		self.write("lazy ")
+		self.operand(exp.Right, precedence(exp.Right) <= parser.LAZY)
+	case *ast.InfixExpression:
+		prec := precedence(exp)
+		self.operand(exp.Left, precedence(exp.Left) < prec)
+		self.write(" ", exp.Operator, " ")
+		self.operand(exp.Right, rightNeedsParens(exp.Right, prec))
+	case *ast.CallExpression:
+		self.operand(exp.Function, precedence(exp.Function) < parser.CALL)
+		self.list("(", ")", exp.Token, exp.Arguments)
+	case *ast.IndexExpression:
+		self.operand(exp.Left, precedence(exp.Left) < parser.INDEX)
+		self.write("[")
+		self.expression(exp.Index)
+		self.write("]")
+	case *ast.StructMemberAccessExpression:
+		self.operand(exp.Left, precedence(exp.Left) < parser.MEMBERACCESS)
+		self.write(".", exp.MemberName)
+	case *ast.StructInstantiation:
+		self.write("new ")
+		if exp.Namespace != "" {
+			self.write(exp.Namespace, ".")
+		}
+		self.write(exp.StructName)
+		self.list("(", ")", exp.Token, exp.Arguments)
+	case *ast.ArrayLiteral:
+		self.list("[", "]", exp.Token, exp.Elements)
+	case *ast.HashLiteral:
+		self.hash(exp)
+	case *ast.FunctionLiteral:
+		self.write("fn(")
+		for i, param := range exp.Parameters {
+			if i > 0 {
+				self.write(", ")
+			}
+			if param.Rest {
+				self.write("...")
+			}
+			self.write(param.Name.Value)
+			if param.Default != nil {
+				self.write(" = ")
+				self.expression(param.Default)
+			}
+		}
+		self.write(") ")
+		self.block(exp.Body)
+	case *ast.IfExpression:
+		self.write("if (")
+		self.expression(exp.Condition)
+		self.write(") ")
+		self.block(exp.Consequence)
+		if exp.Alternative != nil {
+			self.write(" else ")
+			self.block(exp.Alternative)
+		}
+	case *ast.SwitchExpression:
+		self.switchExpression(exp)
+	case *ast.RangeExpression:
+		self.expression(exp.Low)
+		self.write("..")
+		self.expression(exp.High)
+	default:
+		self.write(exp.String())
+	}
+}
+
+func (self *printer) operand(exp ast.Expression, parens bool) {
+	if parens {
+		self.write("(")
+	}
+	self.expression(exp)
+	if parens {
+		self.write(")")
+	}
+}
+
+// precedence returns how tightly the expression holds together when it's used
+// as an operand. Anything that isn't an operator application can't be pulled
+// apart by its neighbours.
+func precedence(exp ast.Expression) int {
+	switch exp := exp.(type) {
+	case *ast.InfixExpression:
+		return parser.Precedence(exp.Token.Type)
+	case *ast.PrefixExpression:
+		return parser.PREFIX
+	case *ast.LazyExpression:
+		return parser.LAZY
+	default:
+		return parser.COMMENT + 1
+	}
+}
+
+// Operators are left-associative, so an infix on the right needs parentheses
+// even when it has the same precedence as its parent. Prefix operators don't
+// have that problem because nothing to their left can steal their operand.
+func rightNeedsParens(exp ast.Expression, parentPrecedence int) bool {
+	if _, ok := exp.(*ast.InfixExpression); ok {
+		return precedence(exp) <= parentPrecedence
+	}
+	return precedence(exp) < parentPrecedence
+}
+
+// list prints comma-separated expressions between the given delimiters. If the
+// first element was on a later line than the opening delimiter, each element
+// gets its own line.
+func (self *printer) list(open string, close string, start token.Token, exps []ast.Expression) {
+	self.write(open)
+	if len(exps) > 0 && self.startLine(exps[0]) > start.Line {
+		self.indent++
+		for i, exp := range exps {
+			self.newline()
+			self.expression(exp)
+			if i < len(exps)-1 {
+				self.write(",")
+			}
+		}
+		self.indent--
+		self.newline()
+	} else {
+		for i, exp := range exps {
+			if i > 0 {
+				self.write(", ")
+			}
+			self.expression(exp)
+		}
+	}
+	self.write(close)
+}
+
+func (self *printer) hash(hash *ast.HashLiteral) {
+	self.write("{")
+	if len(hash.Pairs) > 0 && self.startLine(hash.Pairs[0].Key) > hash.Token.Line {
+		self.indent++
+		for i, pair := range hash.Pairs {
+			self.newline()
+			self.pair(pair)
+			if i < len(hash.Pairs)-1 {
+				self.write(",")
+			}
+		}
+		self.indent--
+		self.newline()
+	} else {
+		for i, pair := range hash.Pairs {
+			if i > 0 {
+				self.write(", ")
+			}
+			self.pair(pair)
+		}
+	}
+	self.write("}")
+}
+
+func (self *printer) pair(pair ast.HashPair) {
+	self.expression(pair.Key)
+	self.write(": ")
+	self.expression(pair.Value)
+}
+
+// quote turns a string value back into a string literal
+func quote(str string) string {
+	replacer := strings.NewReplacer(
+		`\`, `\\`,
+		`"`, `\"`,
+		"${", `\${`,
+		"\n", `\n`,
+		"\t", `\t`,
+		"\r", `\r`,
+	)
+
+	return `"` + replacer.Replace(str) + `"`
+}
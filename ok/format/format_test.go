@@ -0,0 +1,196 @@
+package format
+
+import (
+	"testing"
+)
+
+func TestSource(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			"spacing and semicolons",
+			"let  x=1+2\nputs( x )",
+			"let x = 1 + 2;\nputs(x);\n",
+		},
+		{
+			"empty program",
+			"",
+			"",
+		},
+		{
+			"blank lines are collapsed to one",
+			"let x = 1;\n\n\n\nlet y = 2;",
+			"let x = 1;\n\nlet y = 2;\n",
+		},
+		{
+			"needless parentheses are removed",
+			"let x = (1 + 2) * 3;\nlet y = ((a));",
+			"let x = 1 + 2 * 3;\nlet y = a;\n",
+		},
+		{
+			"needed parentheses are kept",
+			"let x = 1 + (2 * 3);\nlet y = -(a + b);\nlet z = (a + b).c;\nlet w = (lazy a)[0];",
+			"let x = 1 + (2 * 3);\nlet y = -(a + b);\nlet z = (a + b).c;\nlet w = (lazy a)[0];\n",
+		},
+		{
+			"literals are kept as written",
+			`let s = "a\tb\"${x}";` + "\nlet n = 1.50;",
+			`let s = "a\tb\"${x}";` + "\nlet n = 1.50;\n",
+		},
+		{
+			"switch cases are indented under the switch",
+			"switch x {\ncase 1: puts(1)\n    case 2:\n puts(2);\ndefault: puts(3) }",
+			"switch x {\n  case 1: puts(1);\n  case 2: puts(2);\n  default: puts(3);\n}\n",
+		},
+		{
+			"a case with a comment goes on the next line",
+			"let a = switch x { case true:\n// because\n\"prod\" }",
+			"let a = switch x {\n  case true:\n    // because\n    \"prod\";\n};\n",
+		},
+		{
+			"block comments inside expressions are kept",
+			"let x = /* five */ 5;\nlet y = [1,/* two */2];\nlet z = 1 + /* a */ /* b */ 2",
+			"let x = /* five */ 5;\nlet y = [1, /* two */ 2];\nlet z = 1 + /* a */ /* b */ 2;\n",
+		},
+		{
+			"block comments at the end of an expression go after the statement",
+			"puts(x /* the x */)\nlet f = fn() { [a, b /* c */] }",
+			"puts(x); /* the x */\nlet f = fn() { [a, b] }; /* c */\n",
+		},
+		{
+			"a block comment after a case on its own line stays a statement",
+			"switch x { case 1: /* one */ puts(1)\ncase 2:\n/* two */\nputs(2) }",
+			"switch x {\n  case 1: /* one */ puts(1);\n  case 2:\n    /* two */\n    puts(2);\n}\n",
+		},
+		{
+			"single-line blocks stay on one line",
+			"map(arr, fn(e) { e * 2 });\nlet f = fn(a) {\nreturn a }",
+			"map(arr, fn(e) { e * 2 });\nlet f = fn(a) {\n  return a;\n};\n",
+		},
+		{
+			"a block holding a switch is never squashed onto one line",
+			"let f = fn(a) { switch a { case 1: 2; } }",
+			"let f = fn(a) {\n  switch a {\n    case 1: 2;\n  }\n};\n",
+		},
+		{
+			"no semicolon after a switch statement unless it's needed",
+			"switch x { case 1: y; }\nputs(x);\nswitch x { case 1: y; }\n(x)",
+			"switch x {\n  case 1: y;\n}\nputs(x);\nswitch x {\n  case 1: y;\n}(x);\n",
+		},
+		{
+			"trailing comments stay put",
+			"let x = 1; // one\n// two\nlet y = 2; /* three */",
+			"let x = 1; // one\n// two\nlet y = 2; /* three */\n",
+		},
+		{
+			"doc comments stay attached",
+			"///adds\n///\nlet add = fn(a, b) { a + b }",
+			"/// adds\n///\nlet add = fn(a, b) { a + b };\n",
+		},
+		{
+			"lists spread over lines when the first element is on its own line",
+			"let a = [\n1, 2];\nlet h = {\n\"b\": 1, \"a\": 2};\nlet c = {\"b\": 1,\n\"a\": 2}",
+			"let a = [\n  1,\n  2\n];\nlet h = {\n  \"b\": 1,\n  \"a\": 2\n};\nlet c = {\"b\": 1, \"a\": 2};\n",
+		},
+		{
+			"nac members stay in source order with their comments",
+			`notaclass person {
+pack "I know what I'm doing"
+  public greet fn(selfish) { "hi" }
+  // the name
+  field name
+
+
+  /// shouts
+  shout fn(selfish) { return "HI" } // loudly
+}`,
+			`notaclass person {
+  pack "I know what I'm doing"
+
+  public greet fn(selfish) { "hi" }
+  // the name
+  field name
+
+  /// shouts
+  shout fn(selfish) { return "HI" } // loudly
+}
+`,
+		},
+		{
+			"destructuring",
+			"let [ val,err ] = f()\nlet {\"a\": [x], 1:y} = h",
+			"let [val, err] = f();\nlet {\"a\": [x], 1: y} = h;\n",
+		},
+		{
+			"case alternatives and ranges",
+			"switch x { case 1,2 : a; case -1 .. 5: b; case [y, \"\"]: y }",
+			"switch x {\n  case 1, 2: a;\n  case -1..5: b;\n  case [y, \"\"]: y;\n}\n",
+		},
+		{
+			"default and rest parameters",
+			"let f = fn(a,b=1+2, ...rest) { a }",
+			"let f = fn(a, b = 1 + 2, ...rest) { a };\n",
+		},
+		{
+			"imports",
+			"import   \"lib/helpers.ok\"\nimport h \"h.ok\"\nlet p = new  h.person()",
+			"import \"lib/helpers.ok\";\nimport h \"h.ok\";\nlet p = new h.person();\n",
+		},
+		{
+			"field defaults",
+			"notaclass person {\n  field name=\"anon\"\n  field tags  = []\n  field email\n}",
+			"notaclass person {\n  field name = \"anon\"\n  field tags = []\n  field email\n}\n",
+		},
+		{
+			"interfaces",
+			"notaninterface greeter {\ngreet( name )\n  // later\n log(...xs) }\nnotaclass person implements greeter,h.namer {}",
+			"notaninterface greeter {\n  greet(name)\n  // later\n  log(...xs)\n}\nnotaclass person implements greeter, h.namer {}\n",
+		},
+		{
+			"carries",
+			"notaclass person {\n  field name\n  carry name,email   into brgousie\n}",
+			"notaclass person {\n  field name\n  carry name, email into brgousie\n}\n",
+		},
+		{
+			"field packs",
+			"notaclass person {\npack \"bad\"\n  field email\n  pack   email \"worse\"\n}",
+			"notaclass person {\n  pack \"bad\"\n\n  field email\n  pack email \"worse\"\n}\n",
+		},
+		{
+			"empty nac",
+			"notaclass thing { }",
+			"notaclass thing {}\n",
+		},
+	}
+
+	for _, tt := range tests {
+		actual, diagnostics := Source(tt.input, "test.ok")
+		if len(diagnostics) > 0 {
+			t.Errorf("%s: unexpected diagnostics: %v", tt.name, diagnostics)
+			continue
+		}
+		if actual != tt.expected {
+			t.Errorf("%s: expected:\n%s\ngot:\n%s", tt.name, tt.expected, actual)
+			continue
+		}
+
+		// formatting formatted code shouldn't change it
+		again, _ := Source(actual, "test.ok")
+		if again != actual {
+			t.Errorf("%s: formatting is not idempotent. got:\n%s", tt.name, again)
+		}
+	}
+}
+
+func TestSourceWithParseErrors(t *testing.T) {
+	actual, diagnostics := Source("let x = ;", "test.ok")
+	if len(diagnostics) == 0 {
+		t.Fatalf("expected diagnostics, got none")
+	}
+	if actual != "" {
+		t.Errorf("expected no output, got %q", actual)
+	}
+}
@@ -4,8 +4,8 @@ import (
 	"io"
 	"io/ioutil"
 	"log"
-	"strings"
 
+	"github.com/jesseduffield/OK/ok/diagnostic"
 	"github.com/jesseduffield/OK/ok/evaluator"
 	"github.com/jesseduffield/OK/ok/lexer"
 	"github.com/jesseduffield/OK/ok/object"
@@ -14,25 +14,38 @@ import (
 )
 
 func Interpret(r io.Reader, w io.Writer) {
+	InterpretFile(r, "", w)
+}
+
+// InterpretFile is like Interpret but includes the filename when pointing at
+// the source of an error
+func InterpretFile(r io.Reader, filename string, w io.Writer) {
 	content, err := ioutil.ReadAll(r)
 	if err != nil {
 		log.Fatal(err)
 	}
+	source := string(content)
 
-	l := lexer.New(string(content))
+	l := lexer.NewWithFile(source, filename)
 	p := parser.New(l)
 
 	program := p.ParseProgram()
-	if len(p.Errors()) != 0 {
-		printParserErrors(w, p.Errors())
+	if len(p.Diagnostics()) != 0 {
+		diagnostic.Print(w, p.Diagnostics(), source)
 		return
 	}
 
 	env := object.NewEnvironment()
-	output := evaluator.New(w).Eval(program, env)
+	e := evaluator.New(w)
+	output := e.Eval(program, env)
 	if v, ok := output.(*object.Error); ok {
-		io.WriteString(w, v.Inspect())
-		io.WriteString(w, "\n")
+		// the error may have come from inside an imported module
+		if v.Diagnostic != nil && v.Diagnostic.Span.File != filename {
+			if moduleSource, ok := e.ModuleSource(v.Diagnostic.Span.File); ok {
+				source = moduleSource
+			}
+		}
+		PrintError(w, v, source)
 	}
 
 	quentynMessage := quentyn.GetQuentynMessage()
@@ -42,10 +55,14 @@ func Interpret(r io.Reader, w io.Writer) {
 	}
 }
 
-func printParserErrors(out io.Writer, errors []string) {
-	io.WriteString(out, " Parser errors:\n")
-	for _, msg := range errors {
-		indentedMsg := strings.Replace(msg, "\n", "\n\t", -1)
-		io.WriteString(out, "\t"+indentedMsg+"\n")
+// PrintError prints a runtime error, pointing at the offending source code if
+// we know where it is
+func PrintError(w io.Writer, err *object.Error, source string) {
+	if err.Diagnostic == nil {
+		io.WriteString(w, err.Inspect())
+		io.WriteString(w, "\n")
+		return
 	}
+
+	diagnostic.Print(w, []diagnostic.Diagnostic{*err.Diagnostic}, source)
 }
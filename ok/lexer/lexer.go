@@ -2,44 +2,67 @@
 package lexer
 
 import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
 	"github.com/jesseduffield/OK/ok/token"
 )
 
 type Lexer struct {
 	input        string
-	position     int  // current position in input (points to current char)
-	readPosition int  // current reading position in input (after current char)
-	ch           byte // current char under examination
+	position     int  // current byte offset in input (points to current char)
+	readPosition int  // current reading byte offset in input (after current char)
+	ch           rune // current char under examination
 
 	line   int
 	column int
+
+	// attached to each token so that errors can say which file they're in
+	file string
+
+	// one entry per interpolated expression (i.e. "${...}") we're currently
+	// inside of, holding how many unclosed braces we've seen within it. When we
+	// hit a '}' with no unclosed braces we resume reading the string.
+	interpolations []int
 }
 
 func New(input string) *Lexer {
-	l := &Lexer{input: input}
+	return NewWithFile(input, "")
+}
+
+func NewWithFile(input string, file string) *Lexer {
+	l := &Lexer{input: input, file: file}
 	l.readChar()
 	return l
 }
 
+func (l *Lexer) Input() string {
+	return l.input
+}
+
+// we work in runes rather than bytes so that a multi-byte character only
+// counts as one column
 func (l *Lexer) readChar() {
+	width := 1
 	if l.readPosition >= len(l.input) {
-		l.ch = eofByte()
+		l.ch = eofRune()
 	} else {
-		l.ch = l.input[l.readPosition]
+		l.ch, width = utf8.DecodeRuneInString(l.input[l.readPosition:])
 	}
 	l.position = l.readPosition
-	l.readPosition += 1
+	l.readPosition += width
 	l.column++
 }
 
 type mapNode struct {
-	key       byte
+	key       rune
 	tokenType token.TokenType
-	mapping   map[byte]mapNode
+	mapping   map[rune]mapNode
 }
 
-// The order matters here: if you have a token of two characters, you need to preceed
-// it with a token of just its first character, even if that's just an illegal token.
+// The order matters here: if you have a token of several characters, you need to preceed
+// it with a token for each of its prefixes, even if that's just an illegal token.
 // We could improve this algorithm but it's good enough for now.
 var mapping = []struct{ key, tokenType token.TokenType }{
 	{"!", token.BANG},
@@ -57,6 +80,7 @@ var mapping = []struct{ key, tokenType token.TokenType }{
 	{"|", token.ILLEGAL},
 	{"||", token.OR},
 	{"*", token.ASTERISK},
+	{"%", token.PERCENT},
 	{";", token.SEMICOLON},
 	{",", token.COMMA},
 	{"(", token.LPAREN},
@@ -67,21 +91,20 @@ var mapping = []struct{ key, tokenType token.TokenType }{
 	{"]", token.RBRACKET},
 	{":", token.COLON},
 	{".", token.PERIOD},
+	{"..", token.DOTDOT},
+	{"...", token.ELLIPSIS},
 }
 
 var tokenTree = generateTokenTree()
 
-func generateTokenTree() map[byte]mapNode {
-	result := map[byte]mapNode{}
+func generateTokenTree() map[rune]mapNode {
+	result := map[rune]mapNode{}
 
 	for _, node := range mapping {
-		if len(node.key) > 2 {
-			panic("only known tokens of length 1 and 2 are supported")
-		}
 		inner := result
-		for _, ch := range []byte(node.key) {
+		for _, ch := range string(node.key) {
 			if _, ok := inner[ch]; !ok {
-				inner[ch] = mapNode{key: ch, tokenType: node.tokenType, mapping: map[byte]mapNode{}}
+				inner[ch] = mapNode{key: ch, tokenType: node.tokenType, mapping: map[rune]mapNode{}}
 			}
 			inner = inner[ch].mapping
 		}
@@ -96,26 +119,45 @@ func (l *Lexer) ReadKnownToken(line int, column int) (token.Token, bool) {
 		return token.Token{}, false
 	}
 
-	// no registered tokens longer than the given token so we can return immediately
-	if node.mapping == nil {
-		return l.newToken(node.tokenType, l.ch), true
-	}
-
-	// if we're here then maybe we've got '=' but we want to see if the token is
-	// actually '=='.
-	char := l.ch
-	nextChar := l.peekChar()
-	if next, ok := node.mapping[nextChar]; ok {
+	// if we're here then maybe we've got '.' but we want to see if the token is
+	// actually '..' or '...', so we keep going for as long as there's a longer
+	// token to match
+	literal := string(l.ch)
+	for {
+		next, ok := node.mapping[l.peekChar()]
+		if !ok {
+			break
+		}
 		l.readChar()
-		return l.newStringToken(next.tokenType, string(char)+string(nextChar), line, column), true
+		literal += string(l.ch)
+		node = next
 	}
 
-	return l.newToken(node.tokenType, l.ch), true
+	return l.newStringToken(node.tokenType, literal, line, column), true
 }
 
 func (l *Lexer) NextToken() token.Token {
 	l.skipWhitespace()
 
+	start := l.offset()
+	tok := l.readToken()
+	tok.Offset = start
+	tok.EndOffset = l.offset()
+	tok.File = l.file
+
+	return tok
+}
+
+// once we've reached the end of the input, position keeps incrementing, so we
+// cap it here
+func (l *Lexer) offset() int {
+	if l.position > len(l.input) {
+		return len(l.input)
+	}
+	return l.position
+}
+
+func (l *Lexer) readToken() token.Token {
 	var tok token.Token
 	tok.Column = l.column
 	tok.Line = l.line
@@ -125,16 +167,44 @@ func (l *Lexer) NextToken() token.Token {
 		if l.peekChar() == '/' {
 			tok.Literal = l.readComment()
 			tok.Type = token.COMMENT
+			if strings.HasPrefix(tok.Literal, "///") {
+				tok.Type = token.DOC_COMMENT
+			}
+			return tok
+		}
+
+		if l.peekChar() == '*' {
+			var ok bool
+			tok.Literal, ok = l.readBlockComment()
+			tok.Type = token.BLOCK_COMMENT
+			if !ok {
+				tok.Type = token.ILLEGAL
+				tok.Literal = "/*"
+			}
 			return tok
 		}
 
 		tok = l.newToken(token.SLASH, l.ch)
-	case eofByte():
+	case eofRune():
 		tok.Literal = ""
 		tok.Type = token.EOF
 	case '"':
-		tok.Type = token.STRING
-		tok.Literal = l.readString()
+		tok.Type, tok.Literal = l.readString(token.STRING, token.INTERP_START)
+	case '{', '}':
+		depth := len(l.interpolations) - 1
+		if depth >= 0 {
+			if l.ch == '{' {
+				l.interpolations[depth]++
+			} else if l.interpolations[depth] == 0 {
+				l.interpolations = l.interpolations[:depth]
+				tok.Type, tok.Literal = l.readString(token.INTERP_END, token.INTERP_MID)
+				break
+			} else {
+				l.interpolations[depth]--
+			}
+		}
+
+		tok, _ = l.ReadKnownToken(l.line, l.column)
 	default:
 		if tok, ok := l.ReadKnownToken(l.line, l.column); ok {
 			l.readChar()
@@ -148,8 +218,7 @@ func (l *Lexer) NextToken() token.Token {
 		}
 
 		if isDigit(l.ch) {
-			tok.Type = token.INT
-			tok.Literal = l.readNumber()
+			tok.Type, tok.Literal = l.readNumber()
 			return tok
 		}
 
@@ -160,7 +229,7 @@ func (l *Lexer) NextToken() token.Token {
 	return tok
 }
 
-func (l *Lexer) newToken(tokenType token.TokenType, ch byte) token.Token {
+func (l *Lexer) newToken(tokenType token.TokenType, ch rune) token.Token {
 	return l.newStringToken(tokenType, string(ch), l.line, l.column)
 }
 
@@ -173,15 +242,103 @@ func (l *Lexer) newStringToken(
 	return token.Token{Type: tokenType, Literal: literal, Line: line, Column: column}
 }
 
-func (l *Lexer) readString() string {
-	position := l.position + 1
+// readString reads up to the end of a string, or up to the start of an
+// interpolated expression. If we reach the end of the string we return the
+// endType, otherwise we return the interpolationType. Escape sequences are
+// decoded in the returned literal. If an escape sequence is invalid, we return
+// an ILLEGAL token containing it.
+func (l *Lexer) readString(
+	endType token.TokenType,
+	interpolationType token.TokenType,
+) (token.TokenType, string) {
+	var out strings.Builder
+	illegal := ""
+
 	for {
 		l.readChar()
-		if l.ch == '"' || l.ch == eofByte() {
-			break
+
+		switch l.ch {
+		case '"', eofRune():
+			if illegal != "" {
+				return token.ILLEGAL, illegal
+			}
+			return endType, out.String()
+		case '$':
+			if l.peekChar() == '{' {
+				l.readChar()
+				l.interpolations = append(l.interpolations, 0)
+				if illegal != "" {
+					return token.ILLEGAL, illegal
+				}
+				return interpolationType, out.String()
+			}
+			out.WriteRune(l.ch)
+		case '\\':
+			decoded, ok := l.readEscapeSequence()
+			if !ok && illegal == "" {
+				illegal = decoded
+			}
+			out.WriteString(decoded)
+		case '\n':
+			l.line++
+			l.column = 0
+			out.WriteRune(l.ch)
+		default:
+			// writing the raw bytes rather than the rune so that invalid UTF-8
+			// passes through untouched
+			out.WriteString(l.input[l.position:l.readPosition])
 		}
 	}
-	return l.input[position:l.position]
+}
+
+var simpleEscapes = map[rune]string{
+	'n':  "\n",
+	't':  "\t",
+	'r':  "\r",
+	'"':  "\"",
+	'\\': "\\",
+	'$':  "$",
+}
+
+// expects the current char to be the backslash. Returns the decoded value, or
+// if the sequence is invalid, the raw sequence and false.
+func (l *Lexer) readEscapeSequence() (string, bool) {
+	start := l.position
+	l.readChar()
+
+	if l.ch == eofRune() {
+		return `\`, false
+	}
+
+	if decoded, ok := simpleEscapes[l.ch]; ok {
+		return decoded, true
+	}
+
+	if l.ch != 'u' || l.peekChar() != '{' {
+		return l.input[start:l.readPosition], false
+	}
+
+	l.readChar()
+	for l.peekChar() != '}' && l.peekChar() != '"' && l.peekChar() != eofRune() {
+		l.readChar()
+	}
+	if l.peekChar() != '}' {
+		return l.input[start:l.readPosition], false
+	}
+	l.readChar()
+
+	// e.g. \u{1F600}
+	raw := l.input[start:l.readPosition]
+	hex := raw[len(`\u{`) : len(raw)-1]
+	if len(hex) == 0 || len(hex) > 6 {
+		return raw, false
+	}
+	code, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil || !utf8.ValidRune(rune(code)) {
+		return raw, false
+	}
+
+	return string(rune(code)), true
 }
 
 func (l *Lexer) readIdentifier() string {
@@ -194,22 +351,47 @@ func (l *Lexer) readIdentifier() string {
 	return l.input[position:l.position]
 }
 
-func isValidIdentifierStartChar(ch byte) bool {
+func isValidIdentifierStartChar(ch rune) bool {
 	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == '_'
 }
 
-func eofByte() byte {
+func eofRune() rune {
 	return 0
 }
 
 func (l *Lexer) readComment() string {
 	position := l.position
-	for l.ch != '\n' && l.ch != eofByte() {
+	for l.ch != '\n' && l.ch != eofRune() {
 		l.readChar()
 	}
 	return l.input[position:l.position]
 }
 
+// returns false if we hit the end of the input before the closing '*/'
+func (l *Lexer) readBlockComment() (string, bool) {
+	position := l.position
+	// skipping the opening '/*' so that '/*/' isn't treated as a complete comment
+	l.readChar()
+	l.readChar()
+
+	for {
+		switch l.ch {
+		case eofRune():
+			return l.input[position:l.position], false
+		case '*':
+			if l.peekChar() == '/' {
+				l.readChar()
+				l.readChar()
+				return l.input[position:l.position], true
+			}
+		case '\n':
+			l.line++
+			l.column = 0
+		}
+		l.readChar()
+	}
+}
+
 func (l *Lexer) skipWhitespace() {
 	for l.ch == ' ' || l.ch == '\t' || l.ch == '\n' || l.ch == '\r' {
 		if l.ch == '\n' {
@@ -220,22 +402,52 @@ func (l *Lexer) skipWhitespace() {
 	}
 }
 
-func (l *Lexer) readNumber() string {
+// underscores are permitted as digit separators e.g. 1_000_000. We're lenient
+// about where they go and about which digits follow a 0x or 0b prefix: the
+// parser will complain if the number is malformed.
+func (l *Lexer) readNumber() (token.TokenType, string) {
 	position := l.position
-	for isDigit(l.ch) {
+
+	if l.ch == '0' && strings.ContainsRune("xXbB", l.peekChar()) {
 		l.readChar()
+		l.readChar()
+		for isHexDigit(l.ch) || l.ch == '_' {
+			l.readChar()
+		}
+		return token.INT, l.input[position:l.position]
 	}
-	return l.input[position:l.position]
+
+	for isDigit(l.ch) || l.ch == '_' {
+		l.readChar()
+	}
+
+	// we require a digit after the period so that we don't get confused by
+	// something like `1.foo`
+	if l.ch != '.' || !isDigit(l.peekChar()) {
+		return token.INT, l.input[position:l.position]
+	}
+
+	l.readChar()
+	for isDigit(l.ch) || l.ch == '_' {
+		l.readChar()
+	}
+
+	return token.FLOAT, l.input[position:l.position]
 }
 
-func isDigit(ch byte) bool {
+func isDigit(ch rune) bool {
 	return '0' <= ch && ch <= '9'
 }
 
-func (l *Lexer) peekChar() byte {
+func isHexDigit(ch rune) bool {
+	return isDigit(ch) || 'a' <= ch && ch <= 'f' || 'A' <= ch && ch <= 'F'
+}
+
+func (l *Lexer) peekChar() rune {
 	if l.readPosition >= len(l.input) {
-		return eofByte()
+		return eofRune()
 	} else {
-		return l.input[l.readPosition]
+		ch, _ := utf8.DecodeRuneInString(l.input[l.readPosition:])
+		return ch
 	}
 }
@@ -16,7 +16,7 @@ let add = fn(x, y) {
 };
 
 let result = add(five, ten);
-!-/*5;
+!-/ *5;
 5 >= 10 >= 5;
 
 if (5 >= 10) {
@@ -278,3 +278,309 @@ func TestLocationMarking(t *testing.T) {
 		}
 	}
 }
+
+func TestLocationMarkingWithMultiByteCharacters(t *testing.T) {
+	input := "let s = \"héllo 😀\"; s // café\ncafé"
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+		expectedLine    int
+		expectedColumn  int
+	}{
+		{token.LET, "let", 0, 1},
+		{token.IDENT, "s", 0, 5},
+		{token.ASSIGN, "=", 0, 7},
+		{token.STRING, "héllo 😀", 0, 9},
+		{token.SEMICOLON, ";", 0, 18},
+		{token.IDENT, "s", 0, 20},
+		{token.COMMENT, "// café", 0, 22},
+		{token.IDENT, "caf", 1, 1},
+		{token.ILLEGAL, "é", 1, 4},
+		{token.EOF, "", 1, 5},
+	}
+
+	l := New(input)
+
+	tokens := []token.Token{}
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+		tokens = append(tokens, tok)
+
+		if tok.Type != tt.expectedType {
+			t.Log(spew.Sdump(tokens))
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q",
+				i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Log(spew.Sdump(tokens))
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q",
+				i, tt.expectedLiteral, tok.Literal)
+		}
+
+		if tok.Line != tt.expectedLine {
+			t.Log(spew.Sdump(tokens))
+			t.Fatalf("tests[%d] - line wrong. expected=%d, got=%d",
+				i, tt.expectedLine, tok.Line)
+		}
+
+		if tok.Column != tt.expectedColumn {
+			t.Log(spew.Sdump(tokens))
+			t.Fatalf("tests[%d] - column wrong. expected=%d, got=%d",
+				i, tt.expectedColumn, tok.Column)
+		}
+	}
+}
+
+func TestStringEscapesAndInterpolation(t *testing.T) {
+	input := `"a\"b\\c\nd\te"
+"\u{e9}\u{1F600}\$"
+"hello ${name}!"
+"${a} and ${b + 1}"
+"${ {"k": "v"}["k"] } ${"nested ${x}"}"
+"bad\q"
+"bad\u{110000}"
+`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.STRING, "a\"b\\c\nd\te"},
+		{token.STRING, "é😀$"},
+		{token.INTERP_START, "hello "},
+		{token.IDENT, "name"},
+		{token.INTERP_END, "!"},
+		{token.INTERP_START, ""},
+		{token.IDENT, "a"},
+		{token.INTERP_MID, " and "},
+		{token.IDENT, "b"},
+		{token.PLUS, "+"},
+		{token.INT, "1"},
+		{token.INTERP_END, ""},
+		{token.INTERP_START, ""},
+		{token.LBRACE, "{"},
+		{token.STRING, "k"},
+		{token.COLON, ":"},
+		{token.STRING, "v"},
+		{token.RBRACE, "}"},
+		{token.LBRACKET, "["},
+		{token.STRING, "k"},
+		{token.RBRACKET, "]"},
+		{token.INTERP_MID, " "},
+		{token.INTERP_START, "nested "},
+		{token.IDENT, "x"},
+		{token.INTERP_END, ""},
+		{token.INTERP_END, ""},
+		{token.ILLEGAL, `\q`},
+		{token.ILLEGAL, `\u{110000}`},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	tokens := []token.Token{}
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+		tokens = append(tokens, tok)
+
+		if tok.Type != tt.expectedType {
+			t.Log(spew.Sdump(tokens))
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q",
+				i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Log(spew.Sdump(tokens))
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q",
+				i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestNumberLiterals(t *testing.T) {
+	input := `5 5.5 0.25 10.0 1.foo arr[0].bar 0xff 0XA_b 0b101 1_000_000 1_000.5 7 % 2`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.INT, "5"},
+		{token.FLOAT, "5.5"},
+		{token.FLOAT, "0.25"},
+		{token.FLOAT, "10.0"},
+		{token.INT, "1"},
+		{token.PERIOD, "."},
+		{token.IDENT, "foo"},
+		{token.IDENT, "arr"},
+		{token.LBRACKET, "["},
+		{token.INT, "0"},
+		{token.RBRACKET, "]"},
+		{token.PERIOD, "."},
+		{token.IDENT, "bar"},
+		{token.INT, "0xff"},
+		{token.INT, "0XA_b"},
+		{token.INT, "0b101"},
+		{token.INT, "1_000_000"},
+		{token.FLOAT, "1_000.5"},
+		{token.INT, "7"},
+		{token.PERCENT, "%"},
+		{token.INT, "2"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	tokens := []token.Token{}
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+		tokens = append(tokens, tok)
+
+		if tok.Type != tt.expectedType {
+			t.Log(spew.Sdump(tokens))
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q",
+				i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Log(spew.Sdump(tokens))
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q",
+				i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestComments(t *testing.T) {
+	input := `// line
+/// doc
+/* block
+comment */ 5 /**/ /*/ unterminated`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+		expectedLine    int
+		expectedColumn  int
+	}{
+		{token.COMMENT, "// line", 0, 1},
+		{token.DOC_COMMENT, "/// doc", 1, 1},
+		{token.BLOCK_COMMENT, "/* block\ncomment */", 2, 1},
+		{token.INT, "5", 3, 12},
+		{token.BLOCK_COMMENT, "/**/", 3, 14},
+		{token.ILLEGAL, "/*", 3, 19},
+		{token.EOF, "", 3, 35},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q",
+				i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q",
+				i, tt.expectedLiteral, tok.Literal)
+		}
+
+		if tok.Line != tt.expectedLine || tok.Column != tt.expectedColumn {
+			t.Fatalf("tests[%d] - location wrong. expected=%d:%d, got=%d:%d",
+				i, tt.expectedLine, tt.expectedColumn, tok.Line, tok.Column)
+		}
+	}
+}
+
+func TestTokenOffsets(t *testing.T) {
+	input := "let é = \"a${b}c\";\n/* x */ 10 >= 2"
+
+	expected := []string{
+		"let", "é", "=", `"a${`, "b", `}c"`, ";", "/* x */", "10", ">=", "2", "",
+	}
+
+	l := NewWithFile(input, "main.ok")
+
+	for i, literal := range expected {
+		tok := l.NextToken()
+
+		if tok.File != "main.ok" {
+			t.Fatalf("tests[%d] - file wrong. expected=%q, got=%q", i, "main.ok", tok.File)
+		}
+
+		actual := input[tok.Offset:tok.EndOffset]
+		if actual != literal {
+			t.Fatalf("tests[%d] - offsets wrong. expected=%q, got=%q", i, literal, actual)
+		}
+	}
+}
+
+func TestRanges(t *testing.T) {
+	input := `1..5 1.5..x.y ...rest`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.INT, "1"},
+		{token.DOTDOT, ".."},
+		{token.INT, "5"},
+		{token.FLOAT, "1.5"},
+		{token.DOTDOT, ".."},
+		{token.IDENT, "x"},
+		{token.PERIOD, "."},
+		{token.IDENT, "y"},
+		{token.ELLIPSIS, "..."},
+		{token.IDENT, "rest"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - expected=%q (%q), got=%q (%q)",
+				i, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+	}
+}
+
+func TestBlockCommentInsideExpression(t *testing.T) {
+	input := `let x = /* five */ 5;`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.LET, "let"},
+		{token.IDENT, "x"},
+		{token.ASSIGN, "="},
+		{token.BLOCK_COMMENT, "/* five */"},
+		{token.INT, "5"},
+		{token.SEMICOLON, ";"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q",
+				i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q",
+				i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
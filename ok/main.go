@@ -20,6 +20,12 @@ func main() {
 			user.Username)
 		fmt.Printf("Feel free to type in commands\n")
 		repl.Start(os.Stdin, os.Stdout)
+	} else if os.Args[1] == "fmt" {
+		os.Exit(runFmt(os.Args[2:]))
+	} else if os.Args[1] == "evolutions" {
+		os.Exit(runEvolutions(os.Args[2:]))
+	} else if os.Args[1] == "audit" {
+		os.Exit(runAudit(os.Args[2:]))
 	} else {
 		filename := os.Args[1]
 
@@ -28,6 +34,6 @@ func main() {
 			log.Fatal(err)
 		}
 
-		interpreter.Interpret(f, os.Stdout)
+		interpreter.InterpretFile(f, filename, os.Stdout)
 	}
 }
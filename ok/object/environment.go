@@ -2,17 +2,37 @@ package object
 
 import (
 	"fmt"
+	"sort"
 	"sync"
 
 	"github.com/jesseduffield/OK/ok/ast"
+	"github.com/jesseduffield/OK/ok/token"
 )
 
+// PendingCarry is a nac's carry into a nac that hadn't been defined yet, which
+// we check once the target nac is defined
+type PendingCarry struct {
+	From  *ast.Struct
+	Carry *ast.StructCarry
+}
+
+// acknowledgement is an 'I acknowledge that ...' comment, along with the
+// statement after it, which is the only code it lets through
+type acknowledgement struct {
+	text   string
+	covers token.Span
+}
+
 type Environment struct {
 	variableStore         map[string]Object
-	structStore           map[string]*ast.Struct
+	structStore           map[string]*Struct
 	outer                 *Environment
 	currentStructInstance *StructInstance
-	acknowledgements      map[string]bool
+	acknowledgements      map[acknowledgement]bool
+	// keyed by the name of the nac being carried into
+	pendingCarries map[string][]PendingCarry
+	// see NewBlockEnvironment
+	block bool
 
 	mutex sync.Mutex
 }
@@ -23,33 +43,32 @@ func NewEnclosedEnvironment(outer *Environment) *Environment {
 	return env
 }
 
+// NewBlockEnvironment is for the body of an if or a switch case. Variables
+// declared in the block belong to the enclosing environment, as they always
+// have, but nacs defined in the block are only visible inside it.
+func NewBlockEnvironment(outer *Environment) *Environment {
+	env := NewEnclosedEnvironment(outer)
+	env.block = true
+	return env
+}
+
 func NewEnvironment() *Environment {
 	s := make(map[string]Object)
-	vs := make(map[string]*ast.Struct)
-	acknowledgements := make(map[string]bool)
+	vs := make(map[string]*Struct)
+	acknowledgements := make(map[acknowledgement]bool)
+	pendingCarries := make(map[string][]PendingCarry)
 
 	return &Environment{
 		variableStore:    s,
 		structStore:      vs,
 		outer:            nil,
 		acknowledgements: acknowledgements,
+		pendingCarries:   pendingCarries,
 	}
 }
 
-// Hack for now that allows us to access all the defined structs without accessing any variables.
-// A better solution would be to store that in a separate environment
-func OnlyStructs(env *Environment) *Environment {
-	if env == nil {
-		return nil
-	}
-	s := make(map[string]Object)
-	return &Environment{
-		variableStore: s,
-		structStore:   env.structStore,
-		outer:         OnlyStructs(env.outer),
-	}
-}
-
+// Get returns the value of a variable. A nac's name is a value too, so if no
+// variable is found at a given level of the chain, we check for a nac there.
 func (e *Environment) Get(name string) (Object, bool) {
 	current := e
 	for current != nil {
@@ -61,6 +80,11 @@ func (e *Environment) Get(name string) (Object, bool) {
 			return obj, ok
 		}
 
+		str, ok := current.structStore[name]
+		if ok {
+			return str, ok
+		}
+
 		current = current.outer
 	}
 
@@ -69,6 +93,21 @@ func (e *Environment) Get(name string) (Object, bool) {
 
 // Set is for declaring variables and then assigning to them in the current environment. If the variable is declared in a parent environment, that variable will now be shadowed
 func (e *Environment) Set(name string, val Object) Object {
+	if e.block {
+		return e.outer.Set(name, val)
+	}
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	e.variableStore[name] = val
+	return val
+}
+
+// Bind declares a variable in this environment even if it's a block. This is
+// for names which only exist inside the block, like a switch case's bindings
+// or a notaninterface declared there
+func (e *Environment) Bind(name string, val Object) Object {
 	e.mutex.Lock()
 	defer e.mutex.Unlock()
 
@@ -95,29 +134,100 @@ func (e *Environment) Assign(name string, val Object) (Object, error) {
 	return NULL, fmt.Errorf("%s has not been declared", name)
 }
 
-func (e *Environment) GetStruct(name string) (*ast.Struct, bool) {
-	current := e
-	for current != nil {
+// GetStruct returns the nac that the name refers to, whether the nac was
+// defined with that name or the name is a variable holding it
+func (e *Environment) GetStruct(name string) (*Struct, bool) {
+	obj, ok := e.Get(name)
+	if !ok {
+		return nil, false
+	}
+
+	str, ok := obj.(*Struct)
+	return str, ok
+}
+
+// SetStruct defines a nac in this environment, which is then the environment
+// its field defaults and methods are evaluated in
+func (e *Environment) SetStruct(structDef *ast.Struct) *Struct {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	str := &Struct{Definition: structDef, Env: e}
+	e.structStore[structDef.Name] = str
+	return str
+}
+
+// HasVariable says whether a variable of the given name is declared in the
+// scope that a declaration here would go in: this environment and, if it's a
+// block, the environments around it, up to the one its variables belong to.
+// Names from further out can be shadowed, so they don't count.
+func (e *Environment) HasVariable(name string) bool {
+	return e.inScope(func(current *Environment) bool {
+		_, ok := current.variableStore[name]
+		return ok
+	})
+}
+
+// HasStruct is like HasVariable, but for nacs
+func (e *Environment) HasStruct(name string) bool {
+	return e.inScope(func(current *Environment) bool {
+		_, ok := current.structStore[name]
+		return ok
+	})
+}
+
+func (e *Environment) inScope(has func(current *Environment) bool) bool {
+	for current := e; current != nil; current = current.outer {
 		current.mutex.Lock()
-		defer current.mutex.Unlock()
+		found := has(current)
+		current.mutex.Unlock()
 
-		obj, ok := e.structStore[name]
-		if ok {
-			return obj, ok
+		if found {
+			return true
+		}
+		if !current.block {
+			return false
 		}
-
-		current = current.outer
 	}
 
-	return nil, false
+	return false
 }
 
-func (e *Environment) SetStruct(structDef *ast.Struct) *ast.Struct {
+// AddPendingCarry records a carry into a nac that isn't defined yet, to be
+// checked when a nac of that name is defined in this environment
+func (e *Environment) AddPendingCarry(from *ast.Struct, carry *ast.StructCarry) {
 	e.mutex.Lock()
 	defer e.mutex.Unlock()
 
-	e.structStore[structDef.Name] = structDef
-	return structDef
+	e.pendingCarries[carry.Target] = append(e.pendingCarries[carry.Target], PendingCarry{From: from, Carry: carry})
+}
+
+// TakePendingCarries returns the carries waiting on the given nac to be
+// defined, and forgets them
+func (e *Environment) TakePendingCarries(target string) []PendingCarry {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	pending := e.pendingCarries[target]
+	delete(e.pendingCarries, target)
+	return pending
+}
+
+// PendingCarries returns the carries still waiting on a nac to be defined, in
+// the order they appear in the source
+func (e *Environment) PendingCarries() []PendingCarry {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	pending := []PendingCarry{}
+	for _, carries := range e.pendingCarries {
+		pending = append(pending, carries...)
+	}
+	sort.Slice(pending, func(i, j int) bool {
+		return pending[i].Carry.Token.Offset < pending[j].Carry.Token.Offset
+	})
+
+	return pending
 }
 
 func (e *Environment) SetCurrentStructInstance(structInstance *StructInstance) {
@@ -149,8 +259,8 @@ func (e *Environment) String() string {
 		}
 	}
 	result += "Structs:\n"
-	for name, obj := range e.structStore {
-		result += name + ": " + obj.String() + "\n"
+	for name, definition := range e.structStore {
+		result += name + ": " + definition.Definition.String() + "\n"
 	}
 	if e.outer != nil {
 		result += "Outer:\n"
@@ -160,16 +270,35 @@ func (e *Environment) String() string {
 	return result
 }
 
-func (e *Environment) AddAcknowledgement(ack string) {
+// AddAcknowledgement records an acknowledgement that only applies to code
+// within the covered span, i.e. the statement following the comment
+func (e *Environment) AddAcknowledgement(ack string, covers token.Span) {
 	e.mutex.Lock()
 	defer e.mutex.Unlock()
 
-	e.acknowledgements[ack] = true
+	e.acknowledgements[acknowledgement{text: ack, covers: covers}] = true
 }
 
-func (e *Environment) AllowsPrivateAccess(str *ast.Struct) bool {
-	e.mutex.Lock()
-	defer e.mutex.Unlock()
+// AllowsPrivateAccess returns whether the given private member of the nac can
+// be accessed by code at the given span, because the statement it's in was
+// acknowledged with the member's pack. Enclosing environments are checked too,
+// so that a function defined in an acknowledged statement is covered.
+func (e *Environment) AllowsPrivateAccess(str *ast.Struct, member string, at token.Span) bool {
+	wanted := str.AcknowledgementFor(member)
+	if wanted == "" {
+		return false
+	}
+
+	for current := e; current != nil; current = current.outer {
+		current.mutex.Lock()
+		for ack := range current.acknowledgements {
+			if ack.text == wanted && ack.covers.Contains(at) {
+				current.mutex.Unlock()
+				return true
+			}
+		}
+		current.mutex.Unlock()
+	}
 
-	return e.acknowledgements[str.PrivacyAcknowledgement]
+	return false
 }
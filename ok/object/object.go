@@ -4,9 +4,13 @@ import (
 	"bytes"
 	"fmt"
 	"hash/fnv"
+	"math"
+	"math/big"
+	"strconv"
 	"strings"
 
 	"github.com/jesseduffield/OK/ok/ast"
+	"github.com/jesseduffield/OK/ok/diagnostic"
 	"github.com/jesseduffield/OK/ok/token"
 )
 
@@ -14,6 +18,7 @@ type ObjectType string
 
 const (
 	INTEGER_OBJ      = "INTEGER"
+	FLOAT_OBJ        = "FLOAT"
 	BOOLEAN_OBJ      = "BOOLEAN"
 	NULL_OBJ         = "NULL"
 	RETURN_VALUE_OBJ = "RETURN_VALUE"
@@ -23,10 +28,12 @@ const (
 	BUILTIN_OBJ      = "BUILTIN"
 	ARRAY_OBJ        = "ARRAY"
 	HASH_OBJ         = "HASH"
-	// TODO: see if this ever gets printed, if so we should call it a `notaclass` or `NAC`
-	STRUCT_OBJ = "STRUCT"
-	METHOD_OBJ = "METHOD"
-	LAZY_OBJ   = "LAZY"
+	NAC_OBJ          = "NAC"
+	STRUCT_OBJ       = "NOTACLASS"
+	METHOD_OBJ       = "METHOD"
+	LAZY_OBJ         = "LAZY"
+	MODULE_OBJ       = "MODULE"
+	INTERFACE_OBJ    = "NOTANINTERFACE"
 )
 
 type Object interface {
@@ -47,6 +54,51 @@ type Integer struct {
 func (i *Integer) Inspect() string  { return fmt.Sprintf("%d", i.Value) }
 func (i *Integer) Type() ObjectType { return INTEGER_OBJ }
 
+// BigInteger is what an integer becomes when it no longer fits in an int64.
+// As far as the user is concerned it's just another INTEGER. To keep things
+// simple, we only ever use a BigInteger for values outside the int64 range:
+// use NewInteger to get the right representation for a given value.
+type BigInteger struct {
+	Value *big.Int
+}
+
+func (i *BigInteger) Inspect() string  { return i.Value.String() }
+func (i *BigInteger) Type() ObjectType { return INTEGER_OBJ }
+
+func NewInteger(value *big.Int) Object {
+	if value.IsInt64() {
+		return &Integer{Value: value.Int64()}
+	}
+
+	return &BigInteger{Value: value}
+}
+
+// ToBigInt expects an *Integer or a *BigInteger
+func ToBigInt(obj Object) *big.Int {
+	switch obj := obj.(type) {
+	case *Integer:
+		return big.NewInt(obj.Value)
+	case *BigInteger:
+		return obj.Value
+	default:
+		return nil
+	}
+}
+
+type Float struct {
+	Value float64
+}
+
+func (f *Float) Type() ObjectType { return FLOAT_OBJ }
+func (f *Float) Inspect() string {
+	str := strconv.FormatFloat(f.Value, 'g', -1, 64)
+	// we always want to be able to tell a float apart from an integer
+	if !strings.ContainsAny(str, ".eIN") {
+		str += ".0"
+	}
+	return str
+}
+
 type Boolean struct {
 	Value bool
 }
@@ -68,15 +120,21 @@ func (rv *ReturnValue) Inspect() string  { return rv.Value.Inspect() }
 
 type Error struct {
 	Message string
+	// nil if we don't know where the error came from
+	Diagnostic *diagnostic.Diagnostic
 }
 
 func (e *Error) Type() ObjectType { return ERROR_OBJ }
 func (e *Error) Inspect() string  { return "ERROR: " + e.Message }
 
 type Function struct {
-	Parameters []*ast.Identifier
+	Parameters []*ast.Parameter
 	Body       *ast.BlockStatement
 	Env        *Environment
+	// empty for an anonymous function
+	Name string
+	// the 'fn' token, so we can say where the function was defined
+	Token token.Token
 }
 
 func (f *Function) Type() ObjectType { return FUNCTION_OBJ }
@@ -159,6 +217,26 @@ func (i *Integer) HashKey() HashKey {
 	return HashKey{Type: i.Type(), Value: uint64(i.Value)}
 }
 
+func (i *BigInteger) HashKey() HashKey {
+	h := fnv.New64a()
+	h.Write(i.Value.Bytes())
+	if i.Value.Sign() < 0 {
+		h.Write([]byte{'-'})
+	}
+
+	return HashKey{Type: i.Type(), Value: h.Sum64()}
+}
+
+func (f *Float) HashKey() HashKey {
+	value := f.Value
+	// -0.0 and 0.0 are equal so they need the same key
+	if value == 0 {
+		value = 0
+	}
+
+	return HashKey{Type: f.Type(), Value: math.Float64bits(value)}
+}
+
 func (s *String) HashKey() HashKey {
 	h := fnv.New64a()
 	h.Write([]byte(s.Value))
@@ -171,8 +249,39 @@ type HashPair struct {
 	Value Object
 }
 
+// Hash remembers the order its keys were first inserted in, so that iterating
+// over it (and printing it) is predictable. Always go through Set to add pairs,
+// otherwise Keys and Pairs will disagree.
 type Hash struct {
 	Pairs map[HashKey]HashPair
+	Keys  []HashKey
+}
+
+func NewHash() *Hash {
+	return &Hash{Pairs: map[HashKey]HashPair{}, Keys: []HashKey{}}
+}
+
+// Set adds the pair, or replaces the value of an existing key. Replacing a
+// value doesn't change where the key sits in the order.
+func (h *Hash) Set(key HashKey, pair HashPair) {
+	if _, ok := h.Pairs[key]; !ok {
+		h.Keys = append(h.Keys, key)
+	}
+	h.Pairs[key] = pair
+}
+
+func (h *Hash) Get(key HashKey) (HashPair, bool) {
+	pair, ok := h.Pairs[key]
+	return pair, ok
+}
+
+// OrderedPairs returns the pairs in insertion order
+func (h *Hash) OrderedPairs() []HashPair {
+	pairs := make([]HashPair, 0, len(h.Keys))
+	for _, key := range h.Keys {
+		pairs = append(pairs, h.Pairs[key])
+	}
+	return pairs
 }
 
 func (h *Hash) Type() ObjectType { return HASH_OBJ }
@@ -180,7 +289,7 @@ func (h *Hash) Inspect() string {
 	var out bytes.Buffer
 
 	pairs := []string{}
-	for _, pair := range h.Pairs {
+	for _, pair := range h.OrderedPairs() {
 		pairs = append(pairs, fmt.Sprintf("%s: %s", pair.Key.Inspect(), pair.Value.Inspect()))
 	}
 
@@ -195,6 +304,10 @@ func NewError(format string, a ...interface{}) *Error {
 	return &Error{Message: fmt.Sprintf(format, a...)}
 }
 
+func NewDiagnosticError(d diagnostic.Diagnostic) *Error {
+	return &Error{Message: d.String(), Diagnostic: &d}
+}
+
 type LazyObject struct {
 	Right ast.Node
 }
@@ -203,3 +316,41 @@ func (self *LazyObject) Type() ObjectType { return LAZY_OBJ }
 func (self *LazyObject) Inspect() string {
 	return fmt.Sprintf("lazy %s", self.Right.String())
 }
+
+// Module is what an import evaluates to. The module's top-level lets and nacs
+// live in its own environment, and are reached through the module's name,
+// e.g. helpers.equals
+type Module struct {
+	Name string
+	Path string
+	Env  *Environment
+}
+
+func (self *Module) Type() ObjectType { return MODULE_OBJ }
+func (self *Module) Inspect() string {
+	return fmt.Sprintf("module %s (%s)", self.Name, self.Path)
+}
+
+// Struct is what a nac's name refers to, so that the nac can be passed around
+// like any other value, e.g. 'let kind = person; new kind()'
+type Struct struct {
+	Definition *ast.Struct
+	// the environment the nac was defined in
+	Env *Environment
+}
+
+func (self *Struct) Type() ObjectType { return STRUCT_OBJ }
+func (self *Struct) Inspect() string {
+	return "notaclass " + self.Definition.Name
+}
+
+// Interface is what a notaninterface declaration binds its name to, so that it
+// can be passed around like any other value, e.g. implements?(p, greeter)
+type Interface struct {
+	Definition *ast.Interface
+}
+
+func (self *Interface) Type() ObjectType { return INTERFACE_OBJ }
+func (self *Interface) Inspect() string {
+	return "notaninterface " + self.Definition.Name
+}
@@ -20,3 +20,39 @@ func TestStringHashKey(t *testing.T) {
 		t.Errorf("strings with different content have same hash keys")
 	}
 }
+
+func TestFloatHashKey(t *testing.T) {
+	half1 := &Float{Value: 0.5}
+	half2 := &Float{Value: 0.5}
+	zero := &Float{Value: 0}
+	negZero := &Float{Value: -zero.Value}
+
+	if half1.HashKey() != half2.HashKey() {
+		t.Errorf("floats with same value have different hash keys")
+	}
+
+	if zero.HashKey() != negZero.HashKey() {
+		t.Errorf("0.0 and -0.0 have different hash keys")
+	}
+
+	if half1.HashKey() == zero.HashKey() {
+		t.Errorf("floats with different values have same hash keys")
+	}
+}
+
+func TestHashKeepsInsertionOrder(t *testing.T) {
+	hash := NewHash()
+	for _, key := range []string{"b", "a", "c", "a"} {
+		str := &String{Value: key}
+		hash.Set(str.HashKey(), HashPair{Key: str, Value: &Integer{Value: int64(len(hash.Keys))}})
+	}
+
+	expected := "{b: 0, a: 3, c: 2}"
+	if hash.Inspect() != expected {
+		t.Errorf("expected %s, got %s", expected, hash.Inspect())
+	}
+
+	if len(hash.Keys) != 3 || len(hash.Pairs) != 3 {
+		t.Errorf("expected 3 keys, got %d keys and %d pairs", len(hash.Keys), len(hash.Pairs))
+	}
+}
@@ -11,17 +11,36 @@ import (
 type StructInstance struct {
 	Fields map[string]Object
 	Struct *ast.Struct
+	// the environment the nac was defined in, which its methods close over
+	Env *Environment
+
+	// every nac the instance has evolved from, oldest first
+	History []Evolution
+	// a fossil is a snapshot of what an instance was before it evolved, as
+	// handed to the new nac's onevolve method. Fossils don't evolve.
+	Fossil bool
 }
 
-func (self *StructInstance) Type() ObjectType { return HASH_OBJ }
+// Evolution records an instance evolving from one nac into another, and where
+// the method call that triggered it was
+type Evolution struct {
+	From     string
+	To       string
+	Location string
+}
+
+func (self *StructInstance) Type() ObjectType { return NAC_OBJ }
 func (self *StructInstance) Inspect() string {
 	var out bytes.Buffer
 
 	out.WriteString(self.Struct.Name)
 
+	// going by the nac's field order so that the output is predictable
 	pairs := []string{}
-	for key, obj := range self.Fields {
-		pairs = append(pairs, fmt.Sprintf("%s: %s", key, obj.Inspect()))
+	for _, field := range self.Struct.Fields {
+		if obj, ok := self.Fields[field.Name]; ok {
+			pairs = append(pairs, fmt.Sprintf("%s: %s", field.Name, obj.Inspect()))
+		}
 	}
 
 	out.WriteString(": {")
@@ -58,6 +77,7 @@ func (self *StructInstance) GetMethod(methodName string) Object {
 				StructInstance: self,
 				Name:           methodName,
 				StructMethod:   method,
+				Env:            self.Env,
 			}
 		}
 	}
@@ -104,16 +124,35 @@ func (self *StructInstance) SetFieldValue(fieldName string, value Object) Object
 	return value
 }
 
-func (self *StructInstance) EvolveInto(other *StructInstance) {
+// EvolveInto turns the instance into the other one, returning a fossil of what
+// it was before
+func (self *StructInstance) EvolveInto(other *StructInstance, location string) *StructInstance {
+	fossil := &StructInstance{
+		Fields:  self.Fields,
+		Struct:  self.Struct,
+		Env:     self.Env,
+		History: self.History,
+		Fossil:  true,
+	}
+
+	self.History = append(append([]Evolution{}, self.History...), Evolution{
+		From:     self.Struct.Name,
+		To:       other.Struct.Name,
+		Location: location,
+	})
 	self.Struct = other.Struct
 	self.Fields = other.Fields
+	self.Env = other.Env
+
+	return fossil
 }
 
 type Method struct {
 	Name         string
 	StructMethod ast.StructMethod
 
-	// not sure if we need an env here, or if we do which env to pass.
+	// the environment the method's nac was defined in. Like a function, the
+	// method closes over it
 	Env *Environment
 
 	StructInstance *StructInstance
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/jesseduffield/OK/ok/ast"
+	"github.com/jesseduffield/OK/ok/diagnostic"
+	"github.com/jesseduffield/OK/ok/lexer"
+	"github.com/jesseduffield/OK/ok/parser"
+)
+
+// parseFiles reads and parses each of the given files, for the commands which
+// look at a whole program without running it. Returns the programs and their
+// sources, keyed by filename, or false if a file couldn't be read or didn't
+// parse, in which case we've already said why on stderr.
+func parseFiles(filenames []string) ([]*ast.Program, map[string]string, bool) {
+	programs := []*ast.Program{}
+	sources := map[string]string{}
+	for _, filename := range filenames {
+		source, err := ioutil.ReadFile(filename)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return nil, nil, false
+		}
+
+		program, ok := parseSource(string(source), filename)
+		if !ok {
+			return nil, nil, false
+		}
+
+		programs = append(programs, program)
+		sources[filename] = string(source)
+	}
+
+	return programs, sources, true
+}
+
+// parseSource parses the source, printing the parser's diagnostics to stderr
+// and returning false if there are any
+func parseSource(source string, filename string) (*ast.Program, bool) {
+	p := parser.New(lexer.NewWithFile(source, filename))
+	program := p.ParseProgram()
+	if len(p.Diagnostics()) > 0 {
+		diagnostic.Print(os.Stderr, p.Diagnostics(), source)
+		return nil, false
+	}
+
+	return program, true
+}
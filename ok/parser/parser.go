@@ -2,20 +2,40 @@ package parser
 
 import (
 	"fmt"
+	"math/big"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/jesseduffield/OK/ok/ast"
+	"github.com/jesseduffield/OK/ok/diagnostic"
 	"github.com/jesseduffield/OK/ok/lexer"
 	"github.com/jesseduffield/OK/ok/token"
 )
 
 type Parser struct {
-	l      *lexer.Lexer
-	errors []string
+	l           *lexer.Lexer
+	diagnostics []diagnostic.Diagnostic
+
+	// set when we hit an error mid-statement. While set, further errors are
+	// dropped (they're almost always knock-on effects of the first one) until
+	// we've skipped ahead to the start of the next statement.
+	recovering bool
+	// how many unclosed brackets/braces/parens we've passed through. Used to
+	// work out where a broken statement ends.
+	depth int
 
 	curToken  token.Token
 	peekToken token.Token
+	// tokens we've read from the lexer while looking past block comments, but
+	// haven't got to yet
+	buffered []token.Token
+	// block comments found inside an expression, e.g. 'let x = /* five */ 5'.
+	// There's no statement for these to become, so we skip them like
+	// whitespace and keep them on the program for the formatter.
+	inlineComments []token.Token
 
 	prefixParseFns map[token.TokenType]prefixParseFn
 	infixParseFns  map[token.TokenType]infixParseFn
@@ -28,12 +48,19 @@ type (
 
 const MAX_IDENTIFIER_LENGTH = 8
 
+// builtins whose names break our own rules. You can still call them, but you
+// can't name anything of your own like this.
+var grandfatheredIdentifiers = map[string]bool{
+	"implements?": true,
+}
+
 func New(l *lexer.Lexer) *Parser {
-	p := &Parser{l: l, errors: []string{}}
+	p := &Parser{l: l, diagnostics: []diagnostic.Diagnostic{}}
 
 	p.prefixParseFns = make(map[token.TokenType]prefixParseFn)
 	p.registerPrefix(token.IDENT, p.parseIdentifier)
 	p.registerPrefix(token.INT, p.parseIntegerLiteral)
+	p.registerPrefix(token.FLOAT, p.parseFloatLiteral)
 	p.registerPrefix(token.NULL, p.parseNull)
 	p.registerPrefix(token.BANG, p.parsePrefixExpression)
 	p.registerPrefix(token.MINUS, p.parsePrefixExpression)
@@ -43,6 +70,7 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerPrefix(token.IF, p.parseIfExpression)
 	p.registerPrefix(token.FUNCTION, p.parseFunctionLiteral)
 	p.registerPrefix(token.STRING, p.parseStringLiteral)
+	p.registerPrefix(token.INTERP_START, p.parseInterpolatedString)
 	p.registerPrefix(token.LBRACKET, p.parseArrayLiteral)
 	p.registerPrefix(token.LBRACE, p.parseHashLiteral)
 	p.registerPrefix(token.SWITCH, p.parseSwitchExpression)
@@ -54,6 +82,7 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerInfix(token.MINUS, p.parseInfixExpression)
 	p.registerInfix(token.SLASH, p.parseInfixExpression)
 	p.registerInfix(token.ASTERISK, p.parseInfixExpression)
+	p.registerInfix(token.PERCENT, p.parseInfixExpression)
 	p.registerInfix(token.GTEQ, p.parseInfixExpression)
 	p.registerInfix(token.AND, p.parseLogicalInfixExpression)
 	p.registerInfix(token.OR, p.parseLogicalInfixExpression)
@@ -77,6 +106,7 @@ func (p *Parser) parseMemberAccessExpression(left ast.Expression) ast.Expression
 	}
 
 	exp.MemberName = p.curToken.Literal
+	exp.EndToken = p.curToken
 
 	return exp
 }
@@ -85,6 +115,35 @@ func (p *Parser) parseStringLiteral() ast.Expression {
 	return &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
 }
 
+func (p *Parser) parseInterpolatedString() ast.Expression {
+	exp := &ast.InterpolatedString{Token: p.curToken}
+	exp.Segments = []string{p.curToken.Literal}
+
+	for {
+		p.nextToken()
+
+		expression := p.parseExpression(LOWEST)
+		if expression == nil {
+			return nil
+		}
+		exp.Expressions = append(exp.Expressions, expression)
+
+		if p.peekTokenIs(token.INTERP_MID) {
+			p.nextToken()
+			exp.Segments = append(exp.Segments, p.curToken.Literal)
+			continue
+		}
+
+		if !p.expectPeek(token.INTERP_END) {
+			return nil
+		}
+		exp.Segments = append(exp.Segments, p.curToken.Literal)
+		exp.EndToken = p.curToken
+
+		return exp
+	}
+}
+
 func (p *Parser) parsePrefixExpression() ast.Expression {
 	expression := &ast.PrefixExpression{
 		Token:    p.curToken,
@@ -94,6 +153,9 @@ func (p *Parser) parsePrefixExpression() ast.Expression {
 	p.nextToken()
 
 	expression.Right = p.parseExpression(PREFIX)
+	if expression.Right == nil {
+		return nil
+	}
 
 	return expression
 }
@@ -111,7 +173,9 @@ func (p *Parser) parseLazyExpression() ast.Expression {
 }
 
 func (p *Parser) parseIdentifier() ast.Expression {
-	p.validateIdentifier(p.curToken.Literal)
+	if !grandfatheredIdentifiers[p.curToken.Literal] {
+		p.validateIdentifier(p.curToken.Literal)
+	}
 
 	return &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
 }
@@ -120,56 +184,219 @@ func (p *Parser) validateIdentifier(identifier string) {
 	if len(identifier) > MAX_IDENTIFIER_LENGTH {
 		suggested := shortenedIdentifier(identifier)
 
-		p.appendError(fmt.Sprintf(
-			"Identifier must be at most eight characters long; consider using '%s' instead.\nSee https://github.com/jesseduffield/ok#familiarity-admits-brevity",
+		p.appendIdentifierError(
+			diagnostic.IdentifierTooLong,
+			"Identifier must be at most eight characters long; consider using '%s' instead.",
 			suggested,
-		))
+		)
 
 		return
 	}
 
 	if strings.ToLower(identifier) != identifier {
-		p.appendError(fmt.Sprintf(
-			"Identifier must not contain uppercase characters; consider using '%s' instead.\nSee https://github.com/jesseduffield/ok#familiarity-admits-brevity",
+		p.appendIdentifierError(
+			diagnostic.IdentifierUppercase,
+			"Identifier must not contain uppercase characters; consider using '%s' instead.",
 			strings.ToLower(identifier),
-		))
+		)
 
 		return
 	}
 
-	if strings.Contains(identifier, "_") {
-		p.appendError(fmt.Sprintf(
-			"Identifier must not contain underscores; consider using '%s' instead.\nSee https://github.com/jesseduffield/ok#familiarity-admits-brevity",
+	// a lone underscore is the wildcard in patterns, e.g. 'let [_, err] = x'
+	if strings.Contains(identifier, "_") && identifier != "_" {
+		p.appendIdentifierError(
+			diagnostic.IdentifierUnderscore,
+			"Identifier must not contain underscores; consider using '%s' instead.",
 			removeUnderscores(identifier),
-		))
+		)
 
 		return
 	}
 }
 
+// expects the current token to be the offending identifier
+func (p *Parser) appendIdentifierError(code diagnostic.Code, format string, suggested string) {
+	p.appendNonFatalError(code, fmt.Sprintf(format, suggested))
+	if p.recovering {
+		return
+	}
+
+	p.diagnostics[len(p.diagnostics)-1].Fix = &diagnostic.Fix{
+		Span:        p.curToken.Span(),
+		Replacement: suggested,
+	}
+}
+
+// Errors returns our diagnostics in string form
 func (p *Parser) Errors() []string {
-	return p.errors
+	errors := make([]string, len(p.diagnostics))
+	for i, d := range p.diagnostics {
+		errors[i] = d.String()
+	}
+	return errors
+}
+
+func (p *Parser) Diagnostics() []diagnostic.Diagnostic {
+	return p.diagnostics
 }
 
 func (p *Parser) peekError(t token.TokenType) {
+	// an illegal token has a more useful explanation than 'expected X'
+	if p.peekTokenIs(token.ILLEGAL) {
+		p.nextToken()
+		p.handleUnexpectedToken(p.curToken)
+		return
+	}
+
 	p.appendError(
+		diagnostic.ExpectedToken,
 		fmt.Sprintf("expected next token to be %s, got %s instead", t, p.peekToken.Type),
 	)
 }
 
-func (p *Parser) appendError(msg string) {
-	locatedMsg := fmt.Sprintf("%s (%s): %s", p.curToken.Location(), p.curToken.Literal, msg)
-	p.errors = append(p.errors, locatedMsg)
+// appendError reports an error that leaves us unable to make sense of the rest
+// of the current statement
+func (p *Parser) appendError(code diagnostic.Code, msg string) {
+	p.appendNonFatalError(code, msg)
+	p.recovering = true
 }
 
-func (p *Parser) appendErrorForExpression(msg string, exp ast.Expression) {
-	locatedMsg := fmt.Sprintf("%s (%s): %s", exp.GetToken().Location(), exp.String(), msg)
-	p.errors = append(p.errors, locatedMsg)
+// appendNonFatalError is for errors where the code is still well-formed, like
+// badly-named identifiers, so we can keep parsing the statement as normal
+func (p *Parser) appendNonFatalError(code diagnostic.Code, msg string) {
+	if p.recovering {
+		return
+	}
+
+	p.diagnostics = append(p.diagnostics, diagnostic.Diagnostic{
+		Severity: diagnostic.Error,
+		Code:     code,
+		Span:     p.curToken.Span(),
+		Location: fmt.Sprintf("%s (%s)", p.curToken.Location(), p.curToken.Literal),
+		Message:  msg,
+		Link:     code.Link(),
+	})
+}
+
+func (p *Parser) appendErrorForExpression(code diagnostic.Code, msg string, exp ast.Expression) {
+	if p.recovering {
+		return
+	}
+
+	p.diagnostics = append(p.diagnostics, diagnostic.Diagnostic{
+		Severity: diagnostic.Error,
+		Code:     code,
+		Span:     exp.Span(),
+		Location: fmt.Sprintf("%s (%s)", exp.GetToken().Location(), exp.String()),
+		Message:  msg,
+		Link:     code.Link(),
+	})
+	p.recovering = true
 }
 
 func (p *Parser) nextToken() {
 	p.curToken = p.peekToken
-	p.peekToken = p.l.NextToken()
+	p.peekToken = p.readToken()
+	if p.peekTokenIs(token.BLOCK_COMMENT) && !p.curTokenIs(token.BLOCK_COMMENT) {
+		p.skipInlineComments()
+	}
+
+	switch p.curToken.Type {
+	case token.LBRACE, token.LPAREN, token.LBRACKET, token.INTERP_START:
+		p.depth++
+	case token.RBRACE, token.RPAREN, token.RBRACKET, token.INTERP_END:
+		// a stray closing brace at the top level shouldn't throw off our count
+		if p.depth > 0 {
+			p.depth--
+		}
+	}
+}
+
+func (p *Parser) readToken() token.Token {
+	if len(p.buffered) > 0 {
+		tok := p.buffered[0]
+		p.buffered = p.buffered[1:]
+		return tok
+	}
+
+	return p.l.NextToken()
+}
+
+// skipInlineComments steps over the block comments starting at the peek token
+// if they sit inside an expression, i.e. the current token needs an operand
+// after it or the token after the comments carries on from an operand. Any
+// other block comment is left to be parsed as a comment statement.
+func (p *Parser) skipInlineComments() {
+	comments := []token.Token{}
+	next := p.peekToken
+	for next.Type == token.BLOCK_COMMENT {
+		comments = append(comments, next)
+		next = p.readToken()
+	}
+
+	operand := expectsOperand[p.curToken.Type]
+	// a colon might be ending a switch case rather than starting a hash value,
+	// in which case a comment on the following line belongs to the case's block
+	if p.curTokenIs(token.COLON) && comments[0].Line > p.curToken.Line {
+		operand = false
+	}
+
+	if operand || continuesOperand[next.Type] {
+		p.inlineComments = append(p.inlineComments, comments...)
+		p.peekToken = next
+		return
+	}
+
+	buffered := append(comments[1:], next)
+	p.buffered = append(buffered, p.buffered...)
+}
+
+// tokens which can't end an expression, so a block comment after one of them
+// must be inside the expression
+var expectsOperand = map[token.TokenType]bool{
+	token.ASSIGN:       true,
+	token.PLUS:         true,
+	token.MINUS:        true,
+	token.BANG:         true,
+	token.ASTERISK:     true,
+	token.SLASH:        true,
+	token.PERCENT:      true,
+	token.GTEQ:         true,
+	token.AND:          true,
+	token.OR:           true,
+	token.COMMA:        true,
+	token.COLON:        true,
+	token.PERIOD:       true,
+	token.LPAREN:       true,
+	token.LBRACKET:     true,
+	token.INTERP_START: true,
+	token.INTERP_MID:   true,
+	token.RETURN:       true,
+	token.LAZY:         true,
+	token.NEW:          true,
+	token.SWITCH:       true,
+	token.CASE:         true,
+}
+
+// tokens which can't start a statement, so a block comment before one of them
+// must be inside an expression
+var continuesOperand = map[token.TokenType]bool{
+	token.ASSIGN:     true,
+	token.PLUS:       true,
+	token.ASTERISK:   true,
+	token.SLASH:      true,
+	token.PERCENT:    true,
+	token.GTEQ:       true,
+	token.AND:        true,
+	token.OR:         true,
+	token.COMMA:      true,
+	token.COLON:      true,
+	token.PERIOD:     true,
+	token.RPAREN:     true,
+	token.RBRACKET:   true,
+	token.INTERP_MID: true,
+	token.INTERP_END: true,
 }
 
 func (p *Parser) ParseProgram() *ast.Program {
@@ -177,29 +404,108 @@ func (p *Parser) ParseProgram() *ast.Program {
 	program.Statements = []ast.Statement{}
 
 	for p.curToken.Type != token.EOF {
+		start := p.curToken
 		stmt := p.parseStatement()
-		if stmt != nil {
+		if p.recovering {
+			// we don't keep broken statements around: they may be missing parts
+			if !p.synchronize(0, start) {
+				break
+			}
+		} else if stmt != nil {
 			program.Statements = append(program.Statements, stmt)
 		}
 		p.nextToken()
 	}
+	program.Comments = p.inlineComments
 
 	return program
 }
 
+// synchronize skips past the remainder of a statement that failed to parse,
+// leaving the current token on the statement's last token. depth is the
+// nesting depth of the block that the statement belongs to, and start is the
+// statement's first token. Returns false if there is nothing left to parse in
+// the block.
+func (p *Parser) synchronize(depth int, start token.Token) bool {
+	for {
+		if p.curTokenIs(token.EOF) {
+			// leaving p.recovering set so that any enclosing constructs don't
+			// complain about being unterminated
+			return false
+		}
+
+		// the block's closing brace was the broken statement's last token
+		if p.depth < depth {
+			p.recovering = false
+			return false
+		}
+
+		if p.depth == depth && p.atStatementBoundary() {
+			p.recovering = false
+			return true
+		}
+
+		// if somebody's left a bracket unclosed, we'd otherwise skip the
+		// remainder of the block, so we also treat a statement that starts on
+		// a new line, no further indented than the broken one, as a boundary.
+		if p.peekStartsStatement() && p.peekToken.Line > p.curToken.Line &&
+			p.peekToken.Column <= start.Column {
+			p.depth = depth
+			p.recovering = false
+			return true
+		}
+
+		p.nextToken()
+	}
+}
+
+func (p *Parser) atStatementBoundary() bool {
+	if p.curTokenIs(token.SEMICOLON) {
+		return true
+	}
+
+	if p.peekStartsStatement() || p.peekTokenIs(token.EOF) || p.peekTokenIs(token.RBRACE) {
+		return true
+	}
+
+	return p.peekToken.Line > p.curToken.Line
+}
+
+func (p *Parser) peekStartsStatement() bool {
+	return p.peekTokenIs(token.LET) || p.peekTokenIs(token.RETURN) || p.peekTokenIs(token.STRUCT) || p.peekTokenIs(token.INTERFACE) || p.peekTokenIs(token.IMPORT)
+}
+
 func (p *Parser) parseStatement() ast.Statement {
+	// the nil checks are here so that we don't return a typed nil, which would
+	// not equal nil once converted to an ast.Statement
 	switch p.curToken.Type {
 	case token.STRUCT:
-		return p.parseStruct()
+		if stmt := p.parseStruct(); stmt != nil {
+			return stmt
+		}
+	case token.INTERFACE:
+		if stmt := p.parseInterface(); stmt != nil {
+			return stmt
+		}
 	case token.LET:
-		return p.parseLetStatement()
+		if stmt := p.parseLetStatement(); stmt != nil {
+			return stmt
+		}
 	case token.RETURN:
 		return p.parseReturnStatement()
-	case token.COMMENT:
+	case token.IMPORT:
+		if stmt := p.parseImportStatement(); stmt != nil {
+			return stmt
+		}
+	case token.COMMENT, token.BLOCK_COMMENT:
 		return p.parseCommentStatement()
+	case token.DOC_COMMENT:
+		return p.parseDocComment()
 	default:
 		return p.parseExpressionStatement()
 	}
+
+	return nil
 }
 
 func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
@@ -223,6 +529,10 @@ func (p *Parser) parseExpression(precedence int) ast.Expression {
 	leftExp := prefix()
 
 	for !p.peekSemiColon() && precedence < p.peekPrecedence() {
+		if leftExp == nil {
+			return nil
+		}
+
 		infix := p.infixParseFns[p.peekToken.Type]
 		if infix == nil {
 			return leftExp
@@ -243,12 +553,20 @@ func (p *Parser) peekSemiColon() bool {
 func (p *Parser) parseLetStatement() *ast.LetStatement {
 	stmt := &ast.LetStatement{Token: p.curToken}
 
-	if !p.expectPeek(token.IDENT) {
-		return nil
-	}
+	if p.peekTokenIs(token.LBRACKET) || p.peekTokenIs(token.LBRACE) {
+		p.nextToken()
+		stmt.Pattern = p.parsePattern()
+		if stmt.Pattern == nil {
+			return nil
+		}
+	} else {
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
 
-	p.validateIdentifier(p.curToken.Literal)
-	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		p.validateIdentifier(p.curToken.Literal)
+		stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	}
 
 	if !p.expectPeek(token.ASSIGN) {
 		return nil
@@ -257,6 +575,9 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 	p.nextToken()
 
 	stmt.Value = p.parseExpression(LOWEST)
+	if fn, ok := stmt.Value.(*ast.FunctionLiteral); ok && stmt.Name != nil {
+		fn.Name = stmt.Name.Value
+	}
 
 	if p.peekSemiColon() {
 		p.nextToken()
@@ -265,6 +586,119 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 	return stmt
 }
 
+// parsePattern parses what a destructuring let binds to: a name, or an array
+// or hash of patterns. Returns nil if the pattern is invalid.
+func (p *Parser) parsePattern() ast.Pattern {
+	switch p.curToken.Type {
+	case token.IDENT:
+		p.validateIdentifier(p.curToken.Literal)
+		return &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	case token.LBRACKET:
+		pattern := &ast.ArrayPattern{Token: p.curToken}
+		for !p.peekTokenIs(token.RBRACKET) {
+			p.nextToken()
+			element := p.parsePattern()
+			if element == nil {
+				return nil
+			}
+			pattern.Elements = append(pattern.Elements, element)
+
+			if !p.peekTokenIs(token.RBRACKET) && !p.expectPeek(token.COMMA) {
+				return nil
+			}
+		}
+		p.nextToken()
+		pattern.EndToken = p.curToken
+
+		return pattern
+	case token.LBRACE:
+		pattern := &ast.HashPattern{Token: p.curToken}
+		for !p.peekTokenIs(token.RBRACE) {
+			p.nextToken()
+			var key ast.Expression
+			switch p.curToken.Type {
+			case token.STRING:
+				key = p.parseStringLiteral()
+			case token.INT:
+				key = p.parseIntegerLiteral()
+			case token.TRUE, token.FALSE:
+				key = p.parseBoolean()
+			default:
+				p.appendError(diagnostic.InvalidPattern, fmt.Sprintf("keys in a hash pattern must be strings, integers or booleans, got %s", p.curToken.Literal))
+				return nil
+			}
+			if key == nil || !p.expectPeek(token.COLON) {
+				return nil
+			}
+
+			p.nextToken()
+			value := p.parsePattern()
+			if value == nil {
+				return nil
+			}
+			pattern.Pairs = append(pattern.Pairs, ast.HashPatternPair{Key: key, Value: value})
+
+			if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
+				return nil
+			}
+		}
+		p.nextToken()
+		pattern.EndToken = p.curToken
+
+		return pattern
+	default:
+		p.appendError(diagnostic.InvalidPattern, fmt.Sprintf("expected a name, array pattern or hash pattern, got %s", p.curToken.Literal))
+		return nil
+	}
+}
+
+func (p *Parser) parseImportStatement() *ast.ImportStatement {
+	stmt := &ast.ImportStatement{Token: p.curToken}
+
+	if p.peekTokenIs(token.IDENT) {
+		p.nextToken()
+		p.validateIdentifier(p.curToken.Literal)
+		stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	}
+
+	if !p.expectPeek(token.STRING) {
+		return nil
+	}
+	stmt.Path = &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
+
+	if stmt.Name == nil {
+		name := strings.TrimSuffix(filepath.Base(stmt.Path.Value), filepath.Ext(stmt.Path.Value))
+		if !isValidModuleName(name) {
+			p.appendError(
+				diagnostic.InvalidImport,
+				fmt.Sprintf("'%s' can't be used as a module name. Name the import yourself, e.g. import helpers \"%s\"", name, stmt.Path.Value),
+			)
+			return nil
+		}
+		stmt.Name = &ast.Identifier{Token: p.curToken, Value: name}
+	}
+
+	if p.peekSemiColon() {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+// a module named after its file must be a valid identifier, so no uppercase,
+// underscores, or anything else that would upset validateIdentifier
+func isValidModuleName(name string) bool {
+	if name == "" || len(name) > MAX_IDENTIFIER_LENGTH {
+		return false
+	}
+	for _, ch := range name {
+		if ch < 'a' || ch > 'z' {
+			return false
+		}
+	}
+	return token.LookupIdent(name) == token.IDENT
+}
+
 func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 	stmt := &ast.ReturnStatement{Token: p.curToken}
 
@@ -310,7 +744,28 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 
 	value, err := strconv.ParseInt(p.curToken.Literal, 0, 64)
 	if err != nil {
-		p.appendError(fmt.Sprintf("'%s' is not a valid integer", p.curToken.Literal))
+		if numErr, ok := err.(*strconv.NumError); ok && numErr.Err == strconv.ErrRange {
+			if bigValue, ok := new(big.Int).SetString(p.curToken.Literal, 0); ok {
+				lit.BigValue = bigValue
+				return lit
+			}
+		}
+
+		p.appendError(diagnostic.InvalidNumber, fmt.Sprintf("'%s' is not a valid integer", p.curToken.Literal))
+		return nil
+	}
+
+	lit.Value = value
+
+	return lit
+}
+
+func (p *Parser) parseFloatLiteral() ast.Expression {
+	lit := &ast.FloatLiteral{Token: p.curToken}
+
+	value, err := strconv.ParseFloat(p.curToken.Literal, 64)
+	if err != nil {
+		p.appendError(diagnostic.InvalidNumber, fmt.Sprintf("'%s' is not a valid float", p.curToken.Literal))
 		return nil
 	}
 
@@ -325,16 +780,52 @@ func (p *Parser) parseNull() ast.Expression {
 
 func (p *Parser) handleUnexpectedToken(t token.Token) {
 	switch t.Literal {
+	case "/*":
+		p.appendError(diagnostic.UnterminatedComment, "Unterminated block comment: expected a closing '*/'")
 	case ">", "<", "<=", "==", "!=":
 		p.appendError(
+			diagnostic.ComparisonOperator,
 			fmt.Sprintf(
-				"Unexpected token '%s'. There is only one comparison operator: '>='.\nSee https://github.com/jesseduffield/ok#one-comparison-operator",
+				"Unexpected token '%s'. There is only one comparison operator: '>='.",
 				t.Literal,
 			),
 		)
 	default:
-		p.appendError(fmt.Sprintf("Unexpected token '%s'", t.Literal))
+		if t.Type == token.ILLEGAL && !isASCII(t.Literal) {
+			char, _ := utf8.DecodeRuneInString(t.Literal)
+			p.appendError(
+				diagnostic.NonASCIICharacter,
+				fmt.Sprintf(
+					"Unexpected character '%s' (%U). Non-ASCII characters are only permitted inside strings and comments",
+					t.Literal,
+					char,
+				),
+			)
+			return
+		}
+
+		if t.Type == token.ILLEGAL && strings.HasPrefix(t.Literal, "\\") {
+			p.appendError(
+				diagnostic.InvalidEscapeSequence,
+				fmt.Sprintf(
+					"Invalid escape sequence '%s' in string. Supported escape sequences are \\n, \\t, \\r, \\\", \\\\, \\$ and \\u{...}",
+					t.Literal,
+				),
+			)
+			return
+		}
+
+		p.appendError(diagnostic.UnexpectedToken, fmt.Sprintf("Unexpected token '%s'", t.Literal))
+	}
+}
+
+func isASCII(str string) bool {
+	for _, char := range str {
+		if char > unicode.MaxASCII {
+			return false
+		}
 	}
+	return true
 }
 
 func (p *Parser) noPrefixParseFnError(t token.Token) {
@@ -367,11 +858,24 @@ func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
 	precedence := p.curPrecedence()
 	p.nextToken()
 	expression.Right = p.parseExpression(precedence)
+	if expression.Right == nil {
+		return nil
+	}
 
 	return expression
 }
 
 func (p *Parser) parseCommentStatement() *ast.CommentStatement {
+	if p.curTokenIs(token.BLOCK_COMMENT) {
+		text := strings.TrimSuffix(strings.TrimPrefix(p.curToken.Literal, "/*"), "*/")
+
+		return &ast.CommentStatement{
+			Token: p.curToken,
+			Text:  strings.TrimSpace(text),
+			Block: true,
+		}
+	}
+
 	// TODO: handle when the two slashes aren't followed by a space
 	text := strings.TrimPrefix(p.curToken.Literal, "// ")
 
@@ -383,9 +887,65 @@ func (p *Parser) parseCommentStatement() *ast.CommentStatement {
 	return stmt
 }
 
+func (p *Parser) parseDocComment() ast.Statement {
+	doc := p.parseDocCommentLines()
+
+	switch p.peekToken.Type {
+	case token.LET:
+		p.nextToken()
+		stmt := p.parseLetStatement()
+		if stmt == nil {
+			return nil
+		}
+		stmt.Doc = doc
+		return stmt
+	case token.STRUCT:
+		p.nextToken()
+		str := p.parseStruct()
+		if str == nil {
+			return nil
+		}
+		str.Doc = doc
+		return str
+	case token.INTERFACE:
+		p.nextToken()
+		iface := p.parseInterface()
+		if iface == nil {
+			return nil
+		}
+		iface.Doc = doc
+		return iface
+	default:
+		return doc
+	}
+}
+
+// parseDocCommentLines gathers up consecutive doc comment lines
+func (p *Parser) parseDocCommentLines() *ast.DocComment {
+	doc := &ast.DocComment{Token: p.curToken}
+	doc.Lines = []string{docCommentLine(p.curToken.Literal)}
+
+	for p.peekTokenIs(token.DOC_COMMENT) {
+		p.nextToken()
+		doc.Lines = append(doc.Lines, docCommentLine(p.curToken.Literal))
+	}
+	doc.EndToken = p.curToken
+
+	return doc
+}
+
+func docCommentLine(literal string) string {
+	return strings.TrimPrefix(strings.TrimPrefix(literal, "///"), " ")
+}
+
 // this is for the '&&' and '||' operators
 func (p *Parser) parseLogicalInfixExpression(left ast.Expression) ast.Expression {
 	exp := p.parseInfixExpression(left)
+	// an operand that failed to parse may have nil parts, which we can't
+	// describe, and the error's already been reported anyway
+	if p.recovering {
+		return nil
+	}
 	castExp, ok := exp.(*ast.InfixExpression)
 	if !ok {
 		return nil
@@ -395,15 +955,19 @@ func (p *Parser) parseLogicalInfixExpression(left ast.Expression) ast.Expression
 		exp  ast.Expression
 		side string
 	}{{castExp.Left, "Left"}, {castExp.Right, "Right"}} {
+		if operand.exp == nil {
+			return nil
+		}
+
 		switch v := operand.exp.(type) {
 		case *ast.Identifier:
 		case *ast.InfixExpression:
 			if v.Operator != "&&" && v.Operator != "||" {
-				p.appendErrorForExpression(fmt.Sprintf("%s operand of logical expression must be a variable. Consider storing '%s' in a variable", operand.side, v.String()), v)
+				p.appendErrorForExpression(diagnostic.LogicalOperand, fmt.Sprintf("%s operand of logical expression must be a variable. Consider storing '%s' in a variable", operand.side, v.String()), v)
 				return nil
 			}
 		default:
-			p.appendErrorForExpression(fmt.Sprintf("%s operand of logical expression must be a variable. Consider storing '%s' in a variable", operand.side, v.String()), v)
+			p.appendErrorForExpression(diagnostic.LogicalOperand, fmt.Sprintf("%s operand of logical expression must be a variable. Consider storing '%s' in a variable", operand.side, v.String()), v)
 			return nil
 		}
 	}
@@ -489,6 +1053,12 @@ func (p *Parser) parseSwitchExpression() ast.Expression {
 		}
 	}
 
+	if !p.curTokenIs(token.RBRACE) {
+		p.appendError(diagnostic.ExpectedToken, fmt.Sprintf("expected %s to close the switch, got %s instead", token.RBRACE, p.curToken.Type))
+		return nil
+	}
+	expression.EndToken = p.curToken
+
 	return expression
 }
 
@@ -500,7 +1070,20 @@ func (p *Parser) parseSwitchCases() []ast.SwitchCase {
 
 		p.nextToken()
 
-		switchCase.Value = p.parseExpression(LOWEST)
+		for {
+			value := p.parseCaseValue()
+			if value == nil {
+				return nil
+			}
+			switchCase.Values = append(switchCase.Values, value)
+
+			// e.g. case 1, 2, 3:
+			if !p.peekTokenIs(token.COMMA) {
+				break
+			}
+			p.nextToken()
+			p.nextToken()
+		}
 
 		if !p.expectPeek(token.COLON) {
 			return nil
@@ -517,6 +1100,23 @@ func (p *Parser) parseSwitchCases() []ast.SwitchCase {
 	return cases
 }
 
+func (p *Parser) parseCaseValue() ast.Expression {
+	value := p.parseExpression(LOWEST)
+	if value == nil || !p.peekTokenIs(token.DOTDOT) {
+		return value
+	}
+
+	p.nextToken()
+	rangeExp := &ast.RangeExpression{Token: p.curToken, Low: value}
+	p.nextToken()
+	rangeExp.High = p.parseExpression(LOWEST)
+	if rangeExp.High == nil {
+		return nil
+	}
+
+	return rangeExp
+}
+
 func (p *Parser) parseSwitchBlockStatement() *ast.BlockStatement {
 	block := &ast.BlockStatement{Token: p.curToken}
 	block.Statements = []ast.Statement{}
@@ -528,14 +1128,17 @@ func (p *Parser) parseSwitchBlockStatement() *ast.BlockStatement {
 	for !p.curTokenIs(token.RBRACE) && !p.curTokenIs(token.EOF) && !p.curTokenIs(token.DEFAULT) && !p.curTokenIs(token.CASE) {
 		if statementCount >= maxAllowedStatements {
 			p.appendError(
-				"switch blocks can only contain a single statement. If you want to include multiple statements, use a function call\nSee https://github.com/jesseduffield/ok#readable-switches",
+				diagnostic.SwitchBlockTooLong,
+				"switch blocks can only contain a single statement. If you want to include multiple statements, use a function call",
 			)
 			return nil
 		}
 		stmt := p.parseStatement()
 		if stmt != nil {
 			block.Statements = append(block.Statements, stmt)
-			if _, ok := stmt.(*ast.CommentStatement); !ok {
+			switch stmt.(type) {
+			case *ast.CommentStatement, *ast.DocComment:
+			default:
 				statementCount += 1
 			}
 		}
@@ -546,19 +1149,34 @@ func (p *Parser) parseSwitchBlockStatement() *ast.BlockStatement {
 }
 
 func (p *Parser) parseBlockStatement() *ast.BlockStatement {
+	// if we're already skipping a broken statement, we leave the block to be
+	// skipped along with it
+	if p.recovering {
+		return nil
+	}
+
 	block := &ast.BlockStatement{Token: p.curToken}
 	block.Statements = []ast.Statement{}
+	// the depth inside the block, including its opening brace
+	depth := p.depth
 
 	p.nextToken()
 
 	for !p.curTokenIs(token.RBRACE) && !p.curTokenIs(token.EOF) {
+		start := p.curToken
 		stmt := p.parseStatement()
-		if stmt != nil {
+		if p.recovering {
+			if !p.synchronize(depth, start) {
+				break
+			}
+		} else if stmt != nil {
 			block.Statements = append(block.Statements, stmt)
 		}
 		p.nextToken()
 	}
 
+	block.EndToken = p.curToken
+
 	return block
 }
 
@@ -570,6 +1188,9 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 	}
 
 	lit.Parameters = p.parseFunctionParameters()
+	if lit.Parameters == nil {
+		return nil
+	}
 
 	if !p.expectPeek(token.LBRACE) {
 		return nil
@@ -584,85 +1205,437 @@ func (p *Parser) parseStruct() *ast.Struct {
 	str := &ast.Struct{Token: p.curToken}
 	str.Methods = map[string]ast.StructMethod{}
 
-	p.nextToken()
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
 
 	str.Name = p.curToken.Literal
 
+	if p.peekTokenIs(token.IMPLEMENTS) {
+		p.nextToken()
+		str.Interfaces = p.parseImplementedInterfaces()
+		if str.Interfaces == nil {
+			return nil
+		}
+	}
+
 	if !p.expectPeek(token.LBRACE) {
 		return nil
 	}
+	depth := p.depth
 
 	if p.peekTokenIs(token.PACK) {
 		p.nextToken()
-		p.nextToken()
+		if !p.expectPeek(token.STRING) {
+			return nil
+		}
 
 		str.PrivacyAcknowledgement = p.parseStringLiteral().String()
 	}
 
-	for p.peekTokenIs(token.FIELD) {
-		p.nextToken()
+	for !p.peekTokenIs(token.RBRACE) {
+		if p.peekTokenIs(token.EOF) {
+			p.peekError(token.RBRACE)
+			return nil
+		}
+
+		start := p.peekToken
+		ok := true
+		switch p.peekToken.Type {
+		case token.COMMENT, token.BLOCK_COMMENT:
+			p.nextToken()
+			str.Members = append(str.Members, p.parseCommentStatement())
+		case token.DOC_COMMENT:
+			p.nextToken()
+			str.Members = append(str.Members, p.parseDocCommentLines())
+		case token.FIELD:
+			ok = p.parseStructField(str)
+		case token.CARRY:
+			ok = p.parseStructCarry(str)
+		case token.PACK:
+			ok = p.parseStructPack(str)
+		default:
+			ok = p.parseStructMethod(str)
+		}
+
+		if !ok && !p.synchronizeStructMember(depth, start) {
+			return nil
+		}
+	}
+
+	p.nextToken()
+	str.EndToken = p.curToken
+
+	return str
+}
+
+// parseImplementedInterfaces parses the comma-separated interface names after
+// 'implements'. Each is either a name or a module member like
+// 'helpers.greeter'. Returns nil if any of them is something else.
+func (p *Parser) parseImplementedInterfaces() []ast.Expression {
+	interfaces := []ast.Expression{}
+
+	for {
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+
+		name := p.parseExpression(LOWEST)
+		switch name := name.(type) {
+		case *ast.Identifier:
+		case *ast.StructMemberAccessExpression:
+			if _, ok := name.Left.(*ast.Identifier); !ok {
+				p.appendErrorForExpression(diagnostic.UnexpectedToken, "expected the name of a notaninterface", name)
+				return nil
+			}
+		default:
+			if name != nil {
+				p.appendErrorForExpression(diagnostic.UnexpectedToken, "expected the name of a notaninterface", name)
+			}
+			return nil
+		}
+		interfaces = append(interfaces, name)
+
+		if !p.peekTokenIs(token.COMMA) {
+			return interfaces
+		}
 		p.nextToken()
-		fieldName := p.curToken.Literal
-		p.validateIdentifier(fieldName)
-		// no public struct fields for now
-		str.Fields = append(str.Fields, ast.StructField{Name: fieldName, Public: false})
+	}
+}
+
+func (p *Parser) parseInterface() *ast.Interface {
+	iface := &ast.Interface{Token: p.curToken}
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	iface.Name = p.curToken.Literal
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
 	}
 
 	for !p.peekTokenIs(token.RBRACE) {
-		isPublic := false
-		if p.peekTokenIs(token.PUBLIC) {
+		switch p.peekToken.Type {
+		case token.EOF:
+			p.peekError(token.RBRACE)
+			return nil
+		case token.COMMENT, token.BLOCK_COMMENT:
+			p.nextToken()
+			iface.Members = append(iface.Members, p.parseCommentStatement())
+		case token.DOC_COMMENT:
 			p.nextToken()
-			isPublic = true
-			if p.peekTokenIs(token.FIELD) {
-				p.appendError("public nac fields are not permitted")
+			iface.Members = append(iface.Members, p.parseDocCommentLines())
+		default:
+			method := p.parseInterfaceMethod()
+			if method == nil {
 				return nil
 			}
+			iface.Methods = append(iface.Methods, method)
+			iface.Members = append(iface.Members, method)
 		}
+	}
+
+	p.nextToken()
+	iface.EndToken = p.curToken
+
+	return iface
+}
+
+// parseInterfaceMethod parses a method signature like 'greet(name)'
+func (p *Parser) parseInterfaceMethod() *ast.InterfaceMethod {
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	method := &ast.InterfaceMethod{Token: p.curToken, Name: p.curToken.Literal}
+	p.validateIdentifier(method.Name)
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+	method.Parameters = p.parseFunctionParameters()
+	if method.Parameters == nil {
+		return nil
+	}
+	method.EndToken = p.curToken
+
+	for _, param := range method.Parameters {
+		if param.Default != nil {
+			p.appendParameterError(
+				param,
+				fmt.Sprintf("parameter %s can't have a default: a notaninterface only says how many arguments %s takes", param.Name.Value, method.Name),
+			)
+		}
+	}
 
+	return method
+}
+
+// returns false if the field could not be parsed
+func (p *Parser) parseStructField(str *ast.Struct) bool {
+	p.nextToken()
+	fieldToken := p.curToken
+	if !p.expectPeek(token.IDENT) {
+		return false
+	}
+
+	fieldName := p.curToken.Literal
+	p.validateIdentifier(fieldName)
+	// no public struct fields for now
+	field := ast.StructField{Token: fieldToken, EndToken: p.curToken, Name: fieldName, Public: false}
+
+	if p.peekTokenIs(token.ASSIGN) {
 		p.nextToken()
-		methodName := p.curToken.Literal
-		p.validateIdentifier(methodName)
 		p.nextToken()
-
-		fn := p.parseFunctionLiteral().(*ast.FunctionLiteral)
-		str.Methods[methodName] = ast.StructMethod{Public: isPublic, FunctionLiteral: fn}
+		field.Default = p.parseExpression(LOWEST)
+		if field.Default == nil || p.recovering {
+			return false
+		}
 	}
+	str.Fields = append(str.Fields, field)
+	str.Members = append(str.Members, &field)
 
+	return true
+}
+
+// parseStructPack parses a field's own privacy acknowledgement, like
+// 'pack email "I am reading somebody else's email"'. The nac's own pack, which
+// has no field name, is parsed before any members. Returns false if it could
+// not be parsed.
+func (p *Parser) parseStructPack(str *ast.Struct) bool {
 	p.nextToken()
+	pack := &ast.StructPack{Token: p.curToken}
 
-	return str
+	if !p.expectPeek(token.IDENT) {
+		return false
+	}
+	pack.Field = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	if !p.expectPeek(token.STRING) {
+		return false
+	}
+	pack.Acknowledgement = p.parseStringLiteral().String()
+	pack.EndToken = p.curToken
+
+	str.Packs = append(str.Packs, pack)
+	str.Members = append(str.Members, pack)
+
+	return true
 }
 
-func (p *Parser) parseFunctionParameters() []*ast.Identifier {
-	identifiers := []*ast.Identifier{}
+// parseStructCarry parses 'carry name, email into brgousie'. Returns false if
+// it could not be parsed.
+func (p *Parser) parseStructCarry(str *ast.Struct) bool {
+	p.nextToken()
+	carry := &ast.StructCarry{Token: p.curToken}
 
-	if p.peekTokenIs(token.RPAREN) {
+	for {
+		if !p.expectPeek(token.IDENT) {
+			return false
+		}
+		carry.Fields = append(carry.Fields, &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal})
+
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
 		p.nextToken()
-		return identifiers
 	}
 
+	// 'into' isn't a keyword, so that you can still use it as a name elsewhere
+	if !p.peekTokenIs(token.IDENT) || p.peekToken.Literal != "into" {
+		p.nextToken()
+		p.appendError(
+			diagnostic.ExpectedToken,
+			fmt.Sprintf("expected 'into' followed by the nac the fields are carried into, got %s instead", p.curToken.Literal),
+		)
+		return false
+	}
 	p.nextToken()
 
-	ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-	identifiers = append(identifiers, ident)
+	if !p.expectPeek(token.IDENT) {
+		return false
+	}
+	carry.Target = p.curToken.Literal
+	carry.EndToken = p.curToken
 
-	for p.peekTokenIs(token.COMMA) {
+	str.Carries = append(str.Carries, carry)
+	str.Members = append(str.Members, carry)
+
+	return true
+}
+
+// returns false if the method could not be parsed
+func (p *Parser) parseStructMethod(str *ast.Struct) bool {
+	start := p.peekToken
+	isPublic := false
+	if p.peekTokenIs(token.PUBLIC) {
 		p.nextToken()
+		isPublic = true
+		if p.peekTokenIs(token.FIELD) {
+			p.appendError(diagnostic.PublicField, "public nac fields are not permitted")
+			return false
+		}
+	}
+
+	if !p.expectPeek(token.IDENT) {
+		return false
+	}
+	methodName := p.curToken.Literal
+	p.validateIdentifier(methodName)
+
+	if !p.expectPeek(token.FUNCTION) {
+		return false
+	}
+
+	fn, ok := p.parseFunctionLiteral().(*ast.FunctionLiteral)
+	if !ok || p.recovering {
+		return false
+	}
+	method := ast.StructMethod{Token: start, Name: methodName, Public: isPublic, FunctionLiteral: fn}
+	str.Methods[methodName] = method
+	str.Members = append(str.Members, &method)
+
+	return true
+}
+
+// synchronizeStructMember skips to the end of a nac member that failed to
+// parse, so that we can carry on with the next one. depth is the nesting depth
+// of the nac's body and start is the member's first token. Returns false if
+// we've run out of tokens.
+func (p *Parser) synchronizeStructMember(depth int, start token.Token) bool {
+	for {
+		if p.curTokenIs(token.EOF) || p.peekTokenIs(token.EOF) {
+			return false
+		}
+
+		startsMember := p.peekTokenIs(token.PUBLIC) || p.peekTokenIs(token.FIELD) || p.peekTokenIs(token.CARRY) || p.peekTokenIs(token.PACK) || p.peekTokenIs(token.IDENT) || p.peekTokenIs(token.COMMENT) || p.peekTokenIs(token.BLOCK_COMMENT) || p.peekTokenIs(token.DOC_COMMENT)
+		onNewLine := p.peekToken.Line > p.curToken.Line
+
+		if p.depth == depth && (p.peekTokenIs(token.RBRACE) || (startsMember && (onNewLine || !p.peekTokenIs(token.IDENT)))) {
+			p.recovering = false
+			return true
+		}
+
+		// as with statements, we don't want an unclosed bracket to swallow
+		// the rest of the nac
+		if startsMember && onNewLine && p.peekToken.Column <= start.Column {
+			p.depth = depth
+			p.recovering = false
+			return true
+		}
+
+		// the nac's closing brace was the broken member's last token
+		if p.depth < depth {
+			return false
+		}
+
+		p.nextToken()
+	}
+}
+
+func (p *Parser) parseFunctionParameters() []*ast.Parameter {
+	params := []*ast.Parameter{}
+
+	if p.peekTokenIs(token.RPAREN) {
+		p.nextToken()
+		return params
+	}
+
+	for {
+		param := p.parseFunctionParameter()
+		if param == nil {
+			return nil
+		}
+		params = append(params, param)
+
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
 		p.nextToken()
-		ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-		identifiers = append(identifiers, ident)
 	}
 
 	if !p.expectPeek(token.RPAREN) {
 		return nil
 	}
 
-	return identifiers
+	p.validateParameterOrder(params)
+
+	return params
+}
+
+// parseFunctionParameter parses a name, a name with a default ('b = 2'), or a
+// rest parameter ('...rest'). It expects the next token to be the start of
+// the parameter.
+func (p *Parser) parseFunctionParameter() *ast.Parameter {
+	param := &ast.Parameter{}
+
+	if p.peekTokenIs(token.ELLIPSIS) {
+		p.nextToken()
+		param.Token = p.curToken
+		param.Rest = true
+	}
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	if !param.Rest {
+		param.Token = p.curToken
+	}
+	param.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	if p.peekTokenIs(token.ASSIGN) {
+		p.nextToken()
+		if param.Rest {
+			p.appendNonFatalError(
+				diagnostic.InvalidParameter,
+				fmt.Sprintf("rest parameter %s can't have a default: it's an empty array if there are no arguments left", param.Name.Value),
+			)
+		}
+		p.nextToken()
+		param.Default = p.parseExpression(LOWEST)
+		if param.Default == nil {
+			return nil
+		}
+	}
+
+	return param
+}
+
+// validateParameterOrder makes sure that optional parameters come after the
+// required ones, and that a rest parameter comes last, so that we always know
+// which parameter an argument belongs to.
+func (p *Parser) validateParameterOrder(params []*ast.Parameter) {
+	seenOptional := false
+	for i, param := range params {
+		switch {
+		case param.Rest && i != len(params)-1:
+			p.appendParameterError(param, fmt.Sprintf("rest parameter %s must be the last parameter", param.Name.Value))
+		case param.Default != nil:
+			seenOptional = true
+		case !param.Rest && seenOptional:
+			p.appendParameterError(param, fmt.Sprintf("required parameter %s can't come after a parameter with a default", param.Name.Value))
+		}
+	}
+}
+
+func (p *Parser) appendParameterError(param *ast.Parameter, msg string) {
+	if p.recovering {
+		return
+	}
+
+	p.diagnostics = append(p.diagnostics, diagnostic.Diagnostic{
+		Severity: diagnostic.Error,
+		Code:     diagnostic.InvalidParameter,
+		Span:     param.Span(),
+		Location: fmt.Sprintf("%s (%s)", param.Token.Location(), param.String()),
+		Message:  msg,
+	})
 }
 
 func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
 	exp := &ast.CallExpression{Token: p.curToken, Function: function}
 	exp.Arguments = p.parseExpressionList(token.RPAREN)
+	exp.EndToken = p.curToken
 	return exp
 }
 
@@ -670,6 +1643,7 @@ func (p *Parser) parseArrayLiteral() ast.Expression {
 	array := &ast.ArrayLiteral{Token: p.curToken}
 
 	array.Elements = p.parseExpressionList(token.RBRACKET)
+	array.EndToken = p.curToken
 
 	return array
 }
@@ -707,13 +1681,14 @@ func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
 	if !p.expectPeek(token.RBRACKET) {
 		return nil
 	}
+	exp.EndToken = p.curToken
 
 	return exp
 }
 
 func (p *Parser) parseHashLiteral() ast.Expression {
 	hash := &ast.HashLiteral{Token: p.curToken}
-	hash.Pairs = make(map[ast.Expression]ast.Expression)
+	hash.Pairs = []ast.HashPair{}
 
 	for !p.peekTokenIs(token.RBRACE) {
 		p.nextToken()
@@ -726,7 +1701,7 @@ func (p *Parser) parseHashLiteral() ast.Expression {
 		p.nextToken()
 		value := p.parseExpression(LOWEST)
 
-		hash.Pairs[key] = value
+		hash.Pairs = append(hash.Pairs, ast.HashPair{Key: key, Value: value})
 
 		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
 			return nil
@@ -736,6 +1711,7 @@ func (p *Parser) parseHashLiteral() ast.Expression {
 	if !p.expectPeek(token.RBRACE) {
 		return nil
 	}
+	hash.EndToken = p.curToken
 
 	return hash
 }
@@ -751,10 +1727,21 @@ func (p *Parser) parseStructInstantiation() ast.Expression {
 	}
 
 	exp.StructName = p.curToken.Literal
+	// e.g. new helpers.person()
+	if p.peekTokenIs(token.PERIOD) {
+		p.nextToken()
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		exp.Namespace = exp.StructName
+		exp.StructName = p.curToken.Literal
+	}
+
 	if !p.expectPeek(token.LPAREN) {
 		return nil
 	}
 	exp.Arguments = p.parseExpressionList(token.RPAREN)
+	exp.EndToken = p.curToken
 
 	return exp
 }
@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	"github.com/jesseduffield/OK/ok/ast"
+	"github.com/jesseduffield/OK/ok/diagnostic"
 	"github.com/jesseduffield/OK/ok/lexer"
 )
 
@@ -141,6 +142,85 @@ func TestIntegerLiteralExpression(t *testing.T) {
 	}
 }
 
+func TestIntegerLiteralFormats(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"0xff", 255},
+		{"0XFF", 255},
+		{"0b101", 5},
+		{"1_000_000", 1000000},
+		{"0xff_ff", 65535},
+		{"9223372036854775807", 9223372036854775807},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		literal, ok := stmt.Expression.(*ast.IntegerLiteral)
+		if !ok {
+			t.Fatalf("exp not *ast.IntegerLiteral. got=%T", stmt.Expression)
+		}
+		if literal.Value != tt.expected || literal.BigValue != nil {
+			t.Errorf("wrong value for %s. got=%d (big=%v), want=%d", tt.input, literal.Value, literal.BigValue, tt.expected)
+		}
+	}
+}
+
+func TestBigIntegerLiteral(t *testing.T) {
+	input := "999999999999999999999999999"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	literal, ok := stmt.Expression.(*ast.IntegerLiteral)
+	if !ok {
+		t.Fatalf("exp not *ast.IntegerLiteral. got=%T", stmt.Expression)
+	}
+	if literal.BigValue == nil || literal.BigValue.String() != input {
+		t.Errorf("literal.BigValue not %s. got=%v", input, literal.BigValue)
+	}
+}
+
+func TestFloatLiteralExpression(t *testing.T) {
+	input := "5.25;"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program has not enough statements. got=%d",
+			len(program.Statements))
+	}
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got=%T",
+			program.Statements[0])
+	}
+
+	literal, ok := stmt.Expression.(*ast.FloatLiteral)
+	if !ok {
+		t.Fatalf("exp not *ast.FloatLiteral. got=%T", stmt.Expression)
+	}
+	if literal.Value != 5.25 {
+		t.Errorf("literal.Value not %f. got=%f", 5.25, literal.Value)
+	}
+	if literal.TokenLiteral() != "5.25" {
+		t.Errorf("literal.TokenLiteral not %s. got=%s", "5.25",
+			literal.TokenLiteral())
+	}
+}
+
 func TestParsingPrefixExpressions(t *testing.T) {
 	prefixTests := []struct {
 		input    string
@@ -197,6 +277,7 @@ func TestParsingInfixExpressions(t *testing.T) {
 		{"5 - 5;", 5, "-", 5},
 		{"5 * 5;", 5, "*", 5},
 		{"5 / 5;", 5, "/", 5},
+		{"5 % 5;", 5, "%", 5},
 		{"5 >= 5;", 5, ">=", 5},
 		{"foobar + barfoo;", "foobar", "+", "barfoo"},
 		{"foobar - barfoo;", "foobar", "-", "barfoo"},
@@ -241,6 +322,10 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"-a * b",
 			"((-a) * b)",
 		},
+		{
+			"1.5 * -2.25 + a",
+			"((1.5 * (-2.25)) + a)",
+		},
 		{
 			"!-a",
 			"(!(-a))",
@@ -547,8 +632,8 @@ func TestFunctionLiteralParsing(t *testing.T) {
 			len(function.Parameters))
 	}
 
-	testLiteralExpression(t, function.Parameters[0], "x")
-	testLiteralExpression(t, function.Parameters[1], "y")
+	testLiteralExpression(t, function.Parameters[0].Name, "x")
+	testLiteralExpression(t, function.Parameters[1].Name, "y")
 
 	if len(function.Body.Statements) != 1 {
 		t.Fatalf("function.Body.Statements has not 1 statements. got=%d\n",
@@ -589,7 +674,62 @@ func TestFunctionParameterParsing(t *testing.T) {
 		}
 
 		for i, ident := range tt.expectedParams {
-			testLiteralExpression(t, function.Parameters[i], ident)
+			testLiteralExpression(t, function.Parameters[i].Name, ident)
+		}
+	}
+}
+
+func TestParsingDefaultAndRestParameters(t *testing.T) {
+	input := `let f = fn(a, b = 1 + 2, ...rest) {}`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	function := program.Statements[0].(*ast.LetStatement).Value.(*ast.FunctionLiteral)
+	if function.Name != "f" {
+		t.Errorf("expected function to be named f, got %q", function.Name)
+	}
+
+	expected := []string{"a", "b = (1 + 2)", "...rest"}
+	if len(function.Parameters) != len(expected) {
+		t.Fatalf("expected %d parameters, got %d", len(expected), len(function.Parameters))
+	}
+	for i, param := range function.Parameters {
+		if param.String() != expected[i] {
+			t.Errorf("parameter %d: expected %q, got %q", i, expected[i], param.String())
+		}
+	}
+
+	if !function.Parameters[2].Rest {
+		t.Errorf("expected last parameter to be a rest parameter")
+	}
+
+	min, max := ast.Arity(function.Parameters)
+	if min != 1 || max != -1 {
+		t.Errorf("expected arity of 1 and -1, got %d and %d", min, max)
+	}
+}
+
+func TestParsingInvalidParameters(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{"fn(...a, b) {}", "line 1, column 4 (...a): rest parameter a must be the last parameter"},
+		{"fn(a = 1, b) {}", "line 1, column 11 (b): required parameter b can't come after a parameter with a default"},
+		{"fn(...a = []) {}", "line 1, column 9 (=): rest parameter a can't have a default: it's an empty array if there are no arguments left"},
+		{"fn(a = ) {}", "line 1, column 8 ()): Unexpected token ')'"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		if len(p.Errors()) == 0 || p.Errors()[0] != tt.expectedError {
+			t.Errorf("expected error: %s\nGot: %s", tt.expectedError, strings.Join(p.Errors(), "\n"))
 		}
 	}
 }
@@ -866,6 +1006,36 @@ func TestStringLiteralExpression(t *testing.T) {
 	}
 }
 
+func TestParsingInterpolatedString(t *testing.T) {
+	input := `"hello ${name}!"; "${a + b} and ${"${c}"}"`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	expectStatements(t, program.Statements, []string{
+		`"hello ${name}!"`,
+		`"${(a + b)} and ${"${c}"}"`,
+	})
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	interpolated, ok := stmt.Expression.(*ast.InterpolatedString)
+	if !ok {
+		t.Fatalf("exp not *ast.InterpolatedString. got=%T", stmt.Expression)
+	}
+
+	if len(interpolated.Segments) != 2 || interpolated.Segments[0] != "hello " || interpolated.Segments[1] != "!" {
+		t.Errorf("interpolated.Segments wrong. got=%q", interpolated.Segments)
+	}
+
+	if len(interpolated.Expressions) != 1 {
+		t.Fatalf("len(interpolated.Expressions) not 1. got=%d", len(interpolated.Expressions))
+	}
+
+	testIdentifier(t, interpolated.Expressions[0], "name")
+}
+
 func TestParsingArrayLiterals(t *testing.T) {
 	input := "[1, 2 * 2, 3 + 3]"
 
@@ -930,21 +1100,27 @@ func TestParsingHashLiteralsStringKeys(t *testing.T) {
 		t.Errorf("hash.Pairs has wrong length. got=%d", len(hash.Pairs))
 	}
 
-	expected := map[string]int64{
-		"one":   1,
-		"two":   2,
-		"three": 3,
+	expected := []struct {
+		key   string
+		value int64
+	}{
+		{"one", 1},
+		{"two", 2},
+		{"three", 3},
 	}
 
-	for key, value := range hash.Pairs {
-		literal, ok := key.(*ast.StringLiteral)
+	for i, pair := range hash.Pairs {
+		literal, ok := pair.Key.(*ast.StringLiteral)
 		if !ok {
-			t.Errorf("key is not ast.StringLiteral. got=%T", key)
+			t.Errorf("key is not ast.StringLiteral. got=%T", pair.Key)
+			continue
 		}
 
-		expectedValue := expected[literal.String()]
+		if literal.String() != expected[i].key {
+			t.Errorf("pair %d has wrong key. expected=%q, got=%q", i, expected[i].key, literal.String())
+		}
 
-		testIntegerLiteral(t, value, expectedValue)
+		testIntegerLiteral(t, pair.Value, expected[i].value)
 	}
 }
 
@@ -997,10 +1173,10 @@ func TestParsingHashLiteralsWithExpressions(t *testing.T) {
 		},
 	}
 
-	for key, value := range hash.Pairs {
-		literal, ok := key.(*ast.StringLiteral)
+	for _, pair := range hash.Pairs {
+		literal, ok := pair.Key.(*ast.StringLiteral)
 		if !ok {
-			t.Errorf("key is not ast.StringLiteral. got=%T", key)
+			t.Errorf("key is not ast.StringLiteral. got=%T", pair.Key)
 			continue
 		}
 
@@ -1010,7 +1186,7 @@ func TestParsingHashLiteralsWithExpressions(t *testing.T) {
 			continue
 		}
 
-		testFunc(value)
+		testFunc(pair.Value)
 	}
 }
 
@@ -1047,7 +1223,7 @@ func TestParsingSwitchStatement(t *testing.T) {
 			2, len(exp.Cases))
 	}
 
-	if !testInfixExpression(t, exp.Cases[0].Value, 1, "+", 5) {
+	if !testInfixExpression(t, exp.Cases[0].Values[0], 1, "+", 5) {
 		return
 	}
 
@@ -1056,7 +1232,7 @@ func TestParsingSwitchStatement(t *testing.T) {
 		return
 	}
 
-	if !testBooleanLiteral(t, exp.Cases[1].Value, true) {
+	if !testBooleanLiteral(t, exp.Cases[1].Values[0], true) {
 		return
 	}
 
@@ -1114,7 +1290,7 @@ func TestParsingSwitchStatementWithComment(t *testing.T) {
 			2, len(exp.Cases))
 	}
 
-	if !testInfixExpression(t, exp.Cases[0].Value, 1, "+", 5) {
+	if !testInfixExpression(t, exp.Cases[0].Values[0], 1, "+", 5) {
 		return
 	}
 
@@ -1128,7 +1304,7 @@ func TestParsingSwitchStatementWithComment(t *testing.T) {
 		return
 	}
 
-	if !testBooleanLiteral(t, exp.Cases[1].Value, true) {
+	if !testBooleanLiteral(t, exp.Cases[1].Values[0], true) {
 		return
 	}
 
@@ -1163,8 +1339,6 @@ func TestParsingStructDefinition(t *testing.T) {
 	}
 
 	str := stmt.String()
-	// our methods are in a hashmap and we're not sorting by anything.
-	// I'm too lazy to fix right now
 	expected := `notaclass person {
 	pack "test"
 
@@ -1174,16 +1348,7 @@ func TestParsingStructDefinition(t *testing.T) {
 	public foo fn(selfish, a, b) { return 5; }
 	bar fn(selfish) { return 3; }
 }`
-	alternativeExpected := `notaclass person {
-	pack "test"
-
-	field name
-	field email
-
-	bar fn(selfish) { return 3; }
-	public foo fn(selfish, a, b) { return 5; }
-}`
-	if str != expected && str != alternativeExpected {
+	if str != expected {
 		t.Fatalf("unexpected struct got=\n%s\nexpected=\n%s\n", str, expected)
 	}
 
@@ -1202,6 +1367,369 @@ func TestParsingStructDefinition(t *testing.T) {
 	}
 }
 
+func TestParsingStructFieldDefaults(t *testing.T) {
+	input := `notaclass person { field name = "anon" field age = 1 + 2 field email greet fn(selfish) { 1 } }`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt, ok := program.Statements[0].(*ast.Struct)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.Struct. got=%T",
+			program.Statements[0])
+	}
+
+	if len(stmt.Fields) != 3 || stmt.Fields[2].Default != nil {
+		t.Fatalf("expected 3 fields, the last one without a default. got=%+v", stmt.Fields)
+	}
+
+	str := stmt.String()
+	expected := `notaclass person {
+	field name = anon
+	field age = (1 + 2)
+	field email
+
+	greet fn(selfish) { 1 }
+}`
+	if str != expected {
+		t.Fatalf("unexpected struct got=\n%s\nexpected=\n%s\n", str, expected)
+	}
+}
+
+func TestParsingStructCarries(t *testing.T) {
+	input := `notaclass person { field name field email carry name, email into brgousie carry name into rock }`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	str, ok := program.Statements[0].(*ast.Struct)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.Struct. got=%T",
+			program.Statements[0])
+	}
+
+	if len(str.Carries) != 2 {
+		t.Fatalf("expected 2 carries, got %d", len(str.Carries))
+	}
+	if str.Carries[0].Target != "brgousie" || len(str.Carries[0].Fields) != 2 {
+		t.Errorf("unexpected carry: %s", str.Carries[0].String())
+	}
+
+	expected := `notaclass person {
+	field name
+	field email
+	carry name, email into brgousie
+	carry name into rock
+}`
+	if str.String() != expected {
+		t.Errorf("unexpected struct got=\n%s\nexpected=\n%s\n", str.String(), expected)
+	}
+}
+
+func TestParsingStructPacks(t *testing.T) {
+	input := `notaclass person { pack "this is bad" field name field email pack email "this is worse" }`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	str, ok := program.Statements[0].(*ast.Struct)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.Struct. got=%T",
+			program.Statements[0])
+	}
+
+	if len(str.Packs) != 1 {
+		t.Fatalf("expected 1 pack, got %d", len(str.Packs))
+	}
+	if str.AcknowledgementFor("email") != "this is worse" {
+		t.Errorf("expected email's own acknowledgement, got %q", str.AcknowledgementFor("email"))
+	}
+	if str.AcknowledgementFor("name") != "this is bad" {
+		t.Errorf("expected the nac's acknowledgement for name, got %q", str.AcknowledgementFor("name"))
+	}
+
+	expected := `notaclass person {
+	pack "this is bad"
+
+	field name
+	field email
+	pack email "this is worse"
+}`
+	if str.String() != expected {
+		t.Errorf("unexpected struct got=\n%s\nexpected=\n%s\n", str.String(), expected)
+	}
+}
+
+func TestParsingInterfaces(t *testing.T) {
+	input := `
+notaninterface greeter {
+  // a comment
+  greet(name)
+  log(...lines)
+  bye()
+}
+notaclass person implements greeter, helpers.namer { field name }`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 2 {
+		t.Fatalf("program.Statements does not contain %d statements. got=%d\n",
+			2, len(program.Statements))
+	}
+
+	iface, ok := program.Statements[0].(*ast.Interface)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.Interface. got=%T",
+			program.Statements[0])
+	}
+	if len(iface.Methods) != 3 || len(iface.Members) != 4 {
+		t.Fatalf("expected 3 methods and 4 members, got %d and %d", len(iface.Methods), len(iface.Members))
+	}
+
+	expected := `notaninterface greeter {
+	// a comment
+	greet(name)
+	log(...lines)
+	bye()
+}`
+	if iface.String() != expected {
+		t.Errorf("unexpected interface got=\n%s\nexpected=\n%s\n", iface.String(), expected)
+	}
+
+	str, ok := program.Statements[1].(*ast.Struct)
+	if !ok {
+		t.Fatalf("program.Statements[1] is not ast.Struct. got=%T",
+			program.Statements[1])
+	}
+	if len(str.Interfaces) != 2 {
+		t.Fatalf("expected 2 interfaces, got %d", len(str.Interfaces))
+	}
+	testIdentifier(t, str.Interfaces[0], "greeter")
+	if str.Interfaces[1].String() != "helpers.namer" {
+		t.Errorf("expected helpers.namer, got %s", str.Interfaces[1].String())
+	}
+}
+
+func TestParsingInvalidInterfaces(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{"notaninterface greeter { greet(name = 1) }", "line 1, column 32 (name = 1): parameter name can't have a default: a notaninterface only says how many arguments greet takes"},
+		{"notaninterface greeter { greet }", "line 1, column 26 (greet): expected next token to be (, got } instead"},
+		{"notaclass person implements 5 {}", "line 1, column 18 (implements): expected next token to be IDENT, got INT instead"},
+		{"notaclass person implements a + b {}", "line 1, column 31 ((a + b)): expected the name of a notaninterface"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		if len(p.Errors()) == 0 || p.Errors()[0] != tt.expectedError {
+			t.Errorf("expected error: %s\nGot: %s", tt.expectedError, strings.Join(p.Errors(), "\n"))
+		}
+	}
+}
+
+func TestParsingDestructuringLetStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let [val, err] = divide(5, 0);", "let [val, err] = divide(5, 0);"},
+		{"let [] = x", "let [] = x;"},
+		{`let [[a, b], {"x": c, 1: [d], true: e}] = y`, "let [[a, b], {x: c, 1: [d], true: e}] = y;"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt, ok := program.Statements[0].(*ast.LetStatement)
+		if !ok {
+			t.Fatalf("program.Statements[0] is not ast.LetStatement. got=%T", program.Statements[0])
+		}
+		if stmt.Name != nil || stmt.Pattern == nil {
+			t.Errorf("expected a pattern and no name for %q", tt.input)
+		}
+		if stmt.String() != tt.expected {
+			t.Errorf("expected %q, got %q", tt.expected, stmt.String())
+		}
+	}
+}
+
+func TestParsingInvalidPatterns(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{"let [a, 1] = x", "line 1, column 9 (1): expected a name, array pattern or hash pattern, got 1"},
+		{"let {a: b} = x", "line 1, column 6 (a): keys in a hash pattern must be strings, integers or booleans, got a"},
+		{"let [a b] = x", "line 1, column 6 (a): expected next token to be ,, got IDENT instead"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		if len(p.Errors()) == 0 || p.Errors()[0] != tt.expectedError {
+			t.Errorf("expected error: %s\nGot: %s", tt.expectedError, strings.Join(p.Errors(), "\n"))
+		}
+	}
+}
+
+func TestParsingSwitchCasePatterns(t *testing.T) {
+	input := `switch x { case 1, 2, 3: a; case 1..5: b; case -5..f(1): c; case [y, ""], person: d }`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	exp := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.SwitchExpression)
+
+	expected := [][]string{
+		{"1", "2", "3"},
+		{"1..5"},
+		{"(-5)..f(1)"},
+		{"[y, ]", "person"},
+	}
+	if len(exp.Cases) != len(expected) {
+		t.Fatalf("expected %d cases, got %d", len(expected), len(exp.Cases))
+	}
+	for i, c := range exp.Cases {
+		if len(c.Values) != len(expected[i]) {
+			t.Errorf("case %d: expected %d values, got %d", i, len(expected[i]), len(c.Values))
+			continue
+		}
+		for j, value := range c.Values {
+			if value.String() != expected[i][j] {
+				t.Errorf("case %d value %d: expected %q, got %q", i, j, expected[i][j], value.String())
+			}
+		}
+	}
+
+	if _, ok := exp.Cases[1].Values[0].(*ast.RangeExpression); !ok {
+		t.Errorf("expected a range, got %T", exp.Cases[1].Values[0])
+	}
+}
+
+func TestParsingImportStatements(t *testing.T) {
+	tests := []struct {
+		input        string
+		expectedName string
+		expectedPath string
+	}{
+		{`import "lib/helpers.ok";`, "helpers", "lib/helpers.ok"},
+		{`import h "lib/helpers.ok"`, "h", "lib/helpers.ok"},
+		{`import "../util"`, "util", "../util"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt, ok := program.Statements[0].(*ast.ImportStatement)
+		if !ok {
+			t.Fatalf("program.Statements[0] is not ast.ImportStatement. got=%T", program.Statements[0])
+		}
+		if stmt.Name.Value != tt.expectedName {
+			t.Errorf("expected name %q, got %q", tt.expectedName, stmt.Name.Value)
+		}
+		if stmt.Path.Value != tt.expectedPath {
+			t.Errorf("expected path %q, got %q", tt.expectedPath, stmt.Path.Value)
+		}
+	}
+}
+
+func TestParsingInvalidImportStatements(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{`import "my_helpers.ok"`, `line 1, column 8 (my_helpers.ok): 'my_helpers' can't be used as a module name. Name the import yourself, e.g. import helpers "my_helpers.ok"`},
+		{`import helpers`, "line 1, column 8 (helpers): expected next token to be STRING, got EOF instead"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		if len(p.Errors()) == 0 || p.Errors()[0] != tt.expectedError {
+			t.Errorf("expected error: %s\nGot: %s", tt.expectedError, strings.Join(p.Errors(), "\n"))
+		}
+	}
+}
+
+func TestParsingNamespacedStructInstantiation(t *testing.T) {
+	l := lexer.New("new helpers.person(1)")
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	exp, ok := stmt.Expression.(*ast.StructInstantiation)
+	if !ok {
+		t.Fatalf("exp is not ast.StructInstantiation. got=%T", stmt.Expression)
+	}
+	if exp.Namespace != "helpers" || exp.StructName != "person" {
+		t.Errorf("expected helpers.person, got %s.%s", exp.Namespace, exp.StructName)
+	}
+	if exp.String() != "new helpers.person(1)" {
+		t.Errorf("unexpected String(): %s", exp.String())
+	}
+}
+
+func TestParsingStructMembersInSourceOrder(t *testing.T) {
+	input := `notaclass person {
+  greet fn(selfish) { return 1 }
+  // the name
+  field name
+  /// says bye
+  public bye fn(selfish) { return 2 }
+}`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	str, ok := program.Statements[0].(*ast.Struct)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.Struct. got=%T", program.Statements[0])
+	}
+
+	expected := []string{"greet fn(selfish) { return 1; }", "// the name", "field name", "/// says bye", "public bye fn(selfish) { return 2; }"}
+	if len(str.Members) != len(expected) {
+		t.Fatalf("expected %d members, got %d", len(expected), len(str.Members))
+	}
+	for i, member := range str.Members {
+		if member.String() != expected[i] {
+			t.Errorf("member %d: expected %q, got %q", i, expected[i], member.String())
+		}
+	}
+
+	if len(str.Fields) != 1 || len(str.Methods) != 2 {
+		t.Errorf("expected 1 field and 2 methods, got %d and %d", len(str.Fields), len(str.Methods))
+	}
+}
+
 func TestParsingInvalidStructDefinition(t *testing.T) {
 	input := `notaclass person { public field name }`
 	expectedError := "line 1, column 20 (public): public nac fields are not permitted"
@@ -1209,12 +1737,27 @@ func TestParsingInvalidStructDefinition(t *testing.T) {
 	l := lexer.New(input)
 	p := New(l)
 	p.ParseProgram()
-	for _, err := range p.errors {
+	for _, err := range p.Errors() {
+		if err == expectedError {
+			return
+		}
+	}
+	t.Fatalf("expected error: %s\nGot: %s", expectedError, strings.Join(p.Errors(), "\n"))
+}
+
+func TestParsingInvalidStructCarry(t *testing.T) {
+	input := `notaclass person { carry name to rock }`
+	expectedError := "line 1, column 31 (to): expected 'into' followed by the nac the fields are carried into, got to instead"
+
+	l := lexer.New(input)
+	p := New(l)
+	p.ParseProgram()
+	for _, err := range p.Errors() {
 		if err == expectedError {
 			return
 		}
 	}
-	t.Fatalf("expected error: %s\nGot: %s", expectedError, strings.Join(p.errors, "\n"))
+	t.Fatalf("expected error: %s\nGot: %s", expectedError, strings.Join(p.Errors(), "\n"))
 }
 
 func TestParsingStructInstantiation(t *testing.T) {
@@ -1251,12 +1794,12 @@ func TestParsingInvalidSwitch(t *testing.T) {
 	p := New(l)
 	p.ParseProgram()
 	expectedError := "line 1, column 26 (y): switch blocks can only contain a single statement. If you want to include multiple statements, use a function call\nSee https://github.com/jesseduffield/ok#readable-switches"
-	for _, err := range p.errors {
+	for _, err := range p.Errors() {
 		if err == expectedError {
 			return
 		}
 	}
-	t.Fatalf("expected error:\n%s\nActual errors:\n%s", expectedError, strings.Join(p.errors, "\n"))
+	t.Fatalf("expected error:\n%s\nActual errors:\n%s", expectedError, strings.Join(p.Errors(), "\n"))
 }
 
 func TestParsingInvalidExpressions(t *testing.T) {
@@ -1281,6 +1824,10 @@ func TestParsingInvalidExpressions(t *testing.T) {
 			input:         "REALLY_LONG_VARIABLE_NAME",
 			expectedError: "line 1, column 1 (REALLY_LONG_VARIABLE_NAME): Identifier must be at most eight characters long; consider using 'rlvn' instead.\nSee https://github.com/jesseduffield/ok#familiarity-admits-brevity",
 		},
+		{
+			input:         "let x = 1; /* oops",
+			expectedError: "line 1, column 12 (/*): Unterminated block comment: expected a closing '*/'",
+		},
 		{
 			input:         "a_b",
 			expectedError: "line 1, column 1 (a_b): Identifier must not contain underscores; consider using 'ab' instead.\nSee https://github.com/jesseduffield/ok#familiarity-admits-brevity",
@@ -1314,8 +1861,24 @@ func TestParsingInvalidExpressions(t *testing.T) {
 			expectedError: "line 2, column 4 (*): Unexpected token '*'",
 		},
 		{
-			input:         "999999999999999999999999999",
-			expectedError: "line 1, column 1 (999999999999999999999999999): '999999999999999999999999999' is not a valid integer",
+			input:         `"a\qb"`,
+			expectedError: "line 1, column 1 (\\q): Invalid escape sequence '\\q' in string. Supported escape sequences are \\n, \\t, \\r, \\\", \\\\, \\$ and \\u{...}",
+		},
+		{
+			input:         `let s = "é"; let café = s`,
+			expectedError: "line 1, column 21 (é): Unexpected character 'é' (U+00E9). Non-ASCII characters are only permitted inside strings and comments",
+		},
+		{
+			input:         "0b102",
+			expectedError: "line 1, column 1 (0b102): '0b102' is not a valid integer",
+		},
+		{
+			input:         "1__000",
+			expectedError: "line 1, column 1 (1__000): '1__000' is not a valid integer",
+		},
+		{
+			input:         "0x",
+			expectedError: "line 1, column 1 (0x): '0x' is not a valid integer",
 		},
 	}
 
@@ -1324,12 +1887,12 @@ outer:
 		l := lexer.New(test.input)
 		p := New(l)
 		p.ParseProgram()
-		for _, err := range p.errors {
+		for _, err := range p.Errors() {
 			if err == test.expectedError {
 				continue outer
 			}
 		}
-		t.Fatalf("expected error:\n%s\nActual errors:\n%s", test.expectedError, strings.Join(p.errors, "\n"))
+		t.Fatalf("expected error:\n%s\nActual errors:\n%s", test.expectedError, strings.Join(p.Errors(), "\n"))
 	}
 }
 
@@ -1366,6 +1929,214 @@ func TestParsingCommentExpression(t *testing.T) {
 	})
 }
 
+func TestParsingBlockComment(t *testing.T) {
+	input := `let x = 3; /* comment 1 */
+	/*
+	   comment 2
+	*/
+	let y = 4;`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	expectStatements(t, program.Statements, []string{
+		`let x = 3;`,
+		`/* comment 1 */`,
+		`/* comment 2 */`,
+		`let y = 4;`,
+	})
+}
+
+func TestParsingBlockCommentInsideExpression(t *testing.T) {
+	input := `let x = /* five */ 5;
+	let y = [1, /* two */ 2];
+	puts(x /* the x */);
+	/* a statement */`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	expectStatements(t, program.Statements, []string{
+		`let x = 5;`,
+		`let y = [1, 2];`,
+		`puts(x)`,
+		`/* a statement */`,
+	})
+
+	expected := []string{"/* five */", "/* two */", "/* the x */"}
+	if len(program.Comments) != len(expected) {
+		t.Fatalf("expected %d inline comments, got=%d", len(expected), len(program.Comments))
+	}
+	for i, comment := range program.Comments {
+		if comment.Literal != expected[i] {
+			t.Errorf("comments[%d] wrong. expected=%q, got=%q", i, expected[i], comment.Literal)
+		}
+	}
+}
+
+func TestParsingDocComments(t *testing.T) {
+	input := `/// adds one
+	///
+	///   to x
+	let inc = fn(x) { x + 1 };
+
+	/// a person
+	notaclass person {
+		field name
+	}
+
+	/// floating
+	// not a doc comment
+	let y = 4;`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 5 {
+		t.Fatalf("expected 5 statements, got=%d", len(program.Statements))
+	}
+
+	let, ok := program.Statements[0].(*ast.LetStatement)
+	if !ok {
+		t.Fatalf("expected *ast.LetStatement, got=%T", program.Statements[0])
+	}
+	if let.Doc == nil || let.Doc.Text() != "adds one\n\n  to x" {
+		t.Fatalf("unexpected doc on let statement: %#v", let.Doc)
+	}
+	if let.Doc.String() != "/// adds one\n///\n///   to x" {
+		t.Fatalf("unexpected doc string: %q", let.Doc.String())
+	}
+
+	str, ok := program.Statements[1].(*ast.Struct)
+	if !ok {
+		t.Fatalf("expected *ast.Struct, got=%T", program.Statements[1])
+	}
+	if str.Doc == nil || str.Doc.Text() != "a person" {
+		t.Fatalf("unexpected doc on nac: %#v", str.Doc)
+	}
+
+	doc, ok := program.Statements[2].(*ast.DocComment)
+	if !ok {
+		t.Fatalf("expected *ast.DocComment, got=%T", program.Statements[2])
+	}
+	if doc.Text() != "floating" {
+		t.Fatalf("unexpected doc text: %q", doc.Text())
+	}
+
+	testComment(t, program.Statements[3], "not a doc comment")
+
+	if program.Statements[4].(*ast.LetStatement).Doc != nil {
+		t.Fatalf("expected no doc on final let statement")
+	}
+}
+
+func TestNodeSpans(t *testing.T) {
+	input := `let add = fn(a, b) {
+	return a + b;
+};
+add(1, [2, 3][0]);
+let h = {"a": "${add(1, 2)}"};
+notaclass person {
+	field name
+}
+new person().name;
+switch x { case 1: 2; default: 3 }`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	let := program.Statements[0].(*ast.LetStatement)
+	fn := let.Value.(*ast.FunctionLiteral)
+	ret := fn.Body.Statements[0].(*ast.ReturnStatement)
+	call := program.Statements[1].(*ast.ExpressionStatement).Expression.(*ast.CallExpression)
+	hash := program.Statements[2].(*ast.LetStatement).Value.(*ast.HashLiteral)
+	str := program.Statements[3].(*ast.Struct)
+	access := program.Statements[4].(*ast.ExpressionStatement).Expression.(*ast.StructMemberAccessExpression)
+	switchExp := program.Statements[5].(*ast.ExpressionStatement).Expression
+
+	tests := []struct {
+		node     ast.Node
+		expected string
+	}{
+		{let, "let add = fn(a, b) {\n\treturn a + b;\n}"},
+		{fn.Body, "{\n\treturn a + b;\n}"},
+		{ret, "return a + b"},
+		{ret.ReturnValue, "a + b"},
+		{call, "add(1, [2, 3][0])"},
+		{call.Arguments[1], "[2, 3][0]"},
+		{hash, `{"a": "${add(1, 2)}"}`},
+		{str, "notaclass person {\n\tfield name\n}"},
+		{access, "new person().name"},
+		{switchExp, "switch x { case 1: 2; default: 3 }"},
+		{program, input},
+	}
+
+	for i, tt := range tests {
+		span := tt.node.Span()
+		actual := input[span.Start:span.End]
+		if actual != tt.expected {
+			t.Errorf("tests[%d] - span wrong. expected=%q, got=%q", i, tt.expected, actual)
+		}
+	}
+}
+
+func TestErrorSpans(t *testing.T) {
+	input := "let x = 1;\nlet y = a() && b;"
+
+	l := lexer.New(input)
+	p := New(l)
+	p.ParseProgram()
+
+	if len(p.Diagnostics()) != 1 {
+		t.Fatalf("expected one error, got=%v", p.Errors())
+	}
+
+	span := p.Diagnostics()[0].Span
+	if input[span.Start:span.End] != "a()" || span.Line != 1 {
+		t.Fatalf("unexpected error span %+v", span)
+	}
+}
+
+func TestIdentifierDiagnosticsHaveFixes(t *testing.T) {
+	input := "let MY_VAR = 1;\nlet REALLY_LONG_NAME = MY_VAR;"
+
+	l := lexer.New(input)
+	p := New(l)
+	p.ParseProgram()
+
+	diagnostics := p.Diagnostics()
+	expectedCodes := []diagnostic.Code{
+		diagnostic.IdentifierUppercase,
+		diagnostic.IdentifierTooLong,
+		diagnostic.IdentifierUppercase,
+	}
+	if len(diagnostics) != len(expectedCodes) {
+		t.Fatalf("expected %d diagnostics, got=%v", len(expectedCodes), p.Errors())
+	}
+
+	for i, code := range expectedCodes {
+		if diagnostics[i].Code != code {
+			t.Errorf("diagnostics[%d] - expected code %s, got %s", i, code, diagnostics[i].Code)
+		}
+		if diagnostics[i].Fix == nil {
+			t.Fatalf("diagnostics[%d] - expected a fix", i)
+		}
+	}
+
+	expected := "let my_var = 1;\nlet rln = my_var;"
+	if actual := diagnostic.ApplyFixes(input, diagnostics); actual != expected {
+		t.Errorf("expected %q, got %q", expected, actual)
+	}
+}
+
 func expectStatements(t *testing.T, statements []ast.Statement, expected []string) {
 	statementStrings := []string{}
 	for _, statement := range statements {
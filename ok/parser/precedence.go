@@ -19,17 +19,29 @@ const (
 )
 
 var precedences = map[token.TokenType]int{
-	token.GTEQ:     COMPARISON,
-	token.PLUS:     SUM_AND_PRODUCT,
-	token.MINUS:    SUM_AND_PRODUCT,
-	token.SLASH:    SUM_AND_PRODUCT,
-	token.ASTERISK: SUM_AND_PRODUCT,
-	token.LPAREN:   CALL,
-	token.LBRACKET: INDEX,
-	token.AND:      ANDOR,
-	token.OR:       ANDOR,
-	token.ASSIGN:   ASSIGN,
-	token.PERIOD:   MEMBERACCESS,
-	token.LAZY:     LAZY,
-	token.COMMENT:  COMMENT,
+	token.GTEQ:          COMPARISON,
+	token.PLUS:          SUM_AND_PRODUCT,
+	token.MINUS:         SUM_AND_PRODUCT,
+	token.SLASH:         SUM_AND_PRODUCT,
+	token.ASTERISK:      SUM_AND_PRODUCT,
+	token.PERCENT:       SUM_AND_PRODUCT,
+	token.LPAREN:        CALL,
+	token.LBRACKET:      INDEX,
+	token.AND:           ANDOR,
+	token.OR:            ANDOR,
+	token.ASSIGN:        ASSIGN,
+	token.PERIOD:        MEMBERACCESS,
+	token.LAZY:          LAZY,
+	token.COMMENT:       COMMENT,
+	token.BLOCK_COMMENT: COMMENT,
+}
+
+// Precedence returns how tightly the given infix operator binds, or LOWEST if
+// the token isn't an operator
+func Precedence(tokenType token.TokenType) int {
+	if p, ok := precedences[tokenType]; ok {
+		return p
+	}
+
+	return LOWEST
 }
@@ -0,0 +1,175 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jesseduffield/OK/ok/lexer"
+)
+
+const recoverySample = `/// a person
+notaclass person {
+	pack "I will not touch the email"
+
+	field name
+	field email
+
+	public greet fn(greeting) {
+		return "${greeting}, ${self.name}!";
+	}
+
+	evolve fn() {
+		return new adult();
+	}
+}
+
+let add = fn(a, b) { a + b };
+let arr = [1, 2.5, add(3, 4)];
+let h = {"one": arr[0], "two": -arr[1] % 2};
+/* block
+   comment */
+let x = switch add(1, 2) {
+	case 3: "three";
+	default: lazy add(1, 1);
+};
+// I acknowledge that I will not touch the email
+let p = new person();
+p.email = "a@b.c";
+if (x >= 3 && y) { print(x) } else { print(NO!) }
+`
+
+// Every prefix of a program is a plausible state for an editor buffer to be
+// in, so we make sure none of them make the parser panic or hang.
+func TestParsingPrefixesDoesNotPanic(t *testing.T) {
+	for i := range recoverySample {
+		expectParseToTerminate(t, recoverySample[:i])
+	}
+}
+
+func TestParsingWithMissingLinesDoesNotPanic(t *testing.T) {
+	lines := strings.Split(recoverySample, "\n")
+	for i := range lines {
+		remaining := append(append([]string{}, lines[:i]...), lines[i+1:]...)
+		expectParseToTerminate(t, strings.Join(remaining, "\n"))
+	}
+}
+
+// Operands that fail to parse used to leave nils behind for the logical
+// operator checks to trip over
+func TestParsingBrokenOperandsDoesNotPanic(t *testing.T) {
+	for _, input := range []string{
+		"!@ && b;",
+		"-... && b;",
+		"let a = x && y+;",
+		"f(notaninterface) && g",
+	} {
+		expectParseToTerminate(t, input)
+
+		p := New(lexer.New(input))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 {
+			t.Errorf("expected errors for input %q", input)
+		}
+	}
+}
+
+func expectParseToTerminate(t *testing.T, input string) {
+	done := make(chan interface{})
+	go func() {
+		defer func() { done <- recover() }()
+
+		p := New(lexer.New(input))
+		program := p.ParseProgram()
+		_ = program.String()
+	}()
+
+	select {
+	case r := <-done:
+		if r != nil {
+			t.Fatalf("parser panicked on input:\n%s\n\npanic: %v", input, r)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("parser did not terminate on input:\n%s", input)
+	}
+}
+
+func TestParserRecovery(t *testing.T) {
+	tests := []struct {
+		input              string
+		expectedErrors     []string
+		expectedStatements []string
+	}{
+		{
+			input: `let x = 1 +;
+let y = 2;`,
+			expectedErrors:     []string{"line 1, column 12 (;): Unexpected token ';'"},
+			expectedStatements: []string{"let y = 2;"},
+		},
+		{
+			input: `let x = add(1, 2
+let y = 2;
+y`,
+			expectedErrors:     []string{"line 1, column 16 (2): expected next token to be ), got LET instead"},
+			expectedStatements: []string{"let y = 2;", "y"},
+		},
+		{
+			input: `let f = fn() {
+	let a = * 2;
+	let b = 3;
+	b
+};
+f()`,
+			expectedErrors:     []string{"line 2, column 10 (*): Unexpected token '*'"},
+			expectedStatements: []string{"let f = fn() { let b = 3;b };", "f()"},
+		},
+		{
+			input: `notaclass person {
+	field name
+	greet fn( { return 1 }
+	public age fn() { return 2 }
+}
+let x = 1 > 2;
+new person()`,
+			expectedErrors: []string{
+				"line 3, column 10 ((): expected next token to be IDENT, got { instead",
+				"line 6, column 11 (>): Unexpected token '>'. There is only one comparison operator: '>='.\nSee https://github.com/jesseduffield/ok#one-comparison-operator",
+			},
+			expectedStatements: []string{
+				"notaclass person {\n\tfield name\n\n\tpublic age fn() { return 2; }\n}",
+				// the stray comparison is treated as a statement of its own
+				"let x = 1;",
+				"new person()",
+			},
+		},
+		{
+			input: `notaclass person {
+	public field name
+	field email
+}`,
+			expectedErrors: []string{"line 2, column 2 (public): public nac fields are not permitted"},
+			expectedStatements: []string{
+				"notaclass person {\n\tfield name\n\tfield email\n}",
+			},
+		},
+		{
+			input: `switch x { foo }
+5`,
+			expectedErrors:     []string{"line 1, column 12 (foo): expected } to close the switch, got IDENT instead"},
+			expectedStatements: []string{"5"},
+		},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+
+		if strings.Join(p.Errors(), "\n") != strings.Join(tt.expectedErrors, "\n") {
+			t.Errorf("unexpected errors for input:\n%s\n\nexpected:\n%s\n\ngot:\n%s",
+				tt.input, strings.Join(tt.expectedErrors, "\n"), strings.Join(p.Errors(), "\n"))
+			continue
+		}
+
+		expectStatements(t, program.Statements, tt.expectedStatements)
+	}
+}
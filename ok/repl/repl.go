@@ -5,8 +5,8 @@ import (
 	"fmt"
 	"io"
 	"os"
-	"strings"
 
+	"github.com/jesseduffield/OK/ok/diagnostic"
 	"github.com/jesseduffield/OK/ok/evaluator"
 	"github.com/jesseduffield/OK/ok/lexer"
 	"github.com/jesseduffield/OK/ok/object"
@@ -18,6 +18,11 @@ const PROMPT = ">> "
 func Start(in io.Reader, out io.Writer) {
 	scanner := bufio.NewScanner(in)
 	env := object.NewEnvironment()
+	// each line gets its own file name so that errors coming from functions
+	// defined on earlier lines can still be pointed at
+	sources := map[string]string{}
+	// sharing one evaluator so that modules are only loaded once
+	e := evaluator.New(os.Stdout)
 
 	for {
 		fmt.Fprintf(out, PROMPT)
@@ -27,27 +32,27 @@ func Start(in io.Reader, out io.Writer) {
 		}
 
 		line := scanner.Text()
-		l := lexer.New(line)
+		file := fmt.Sprintf("<input %d>", len(sources)+1)
+		sources[file] = line
+		l := lexer.NewWithFile(line, file)
 		p := parser.New(l)
 
 		program := p.ParseProgram()
-		if len(p.Errors()) != 0 {
-			printParserErrors(out, p.Errors())
+		if len(p.Diagnostics()) != 0 {
+			diagnostic.Print(out, p.Diagnostics(), line)
 			continue
 		}
 
-		evaluated := evaluator.New(os.Stdout).Eval(program, env)
-		if evaluated != nil {
+		evaluated := e.Eval(program, env)
+		if err, ok := evaluated.(*object.Error); ok && err.Diagnostic != nil {
+			source, ok := sources[err.Diagnostic.Span.File]
+			if !ok {
+				source, _ = e.ModuleSource(err.Diagnostic.Span.File)
+			}
+			diagnostic.Print(out, []diagnostic.Diagnostic{*err.Diagnostic}, source)
+		} else if evaluated != nil {
 			io.WriteString(out, evaluated.Inspect())
 			io.WriteString(out, "\n")
 		}
 	}
 }
-
-func printParserErrors(out io.Writer, errors []string) {
-	io.WriteString(out, " Parser errors:\n")
-	for _, msg := range errors {
-		indentedMsg := strings.Replace(msg, "\n", "\n\t", -1)
-		io.WriteString(out, "\t"+indentedMsg+"\n")
-	}
-}
@@ -0,0 +1,100 @@
+package token
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Span is a region of source code. Start and End are byte offsets, with End
+// being exclusive. Line and Column are those of the start of the span, using
+// the same convention as Token (zero-based line, one-based column).
+type Span struct {
+	File   string
+	Start  int
+	End    int
+	Line   int
+	Column int
+}
+
+// To returns a span running from the start of this span to the end of the
+// other one
+func (self Span) To(other Span) Span {
+	if other.End < self.End {
+		return self
+	}
+
+	result := self
+	result.End = other.End
+	return result
+}
+
+// Contains returns whether the other span lies entirely within this one
+func (self Span) Contains(other Span) bool {
+	return self.File == other.File && self.Start <= other.Start && other.End <= self.End
+}
+
+func (self Span) Location() string {
+	if self.File == "" {
+		return fmt.Sprintf("line %d, column %d", self.Line+1, self.Column)
+	}
+
+	return fmt.Sprintf("%s:%d:%d", self.File, self.Line+1, self.Column)
+}
+
+// Excerpt renders the line of source that the span starts on, with the span
+// underlined by carets, e.g.
+//
+//	 --> main.ok:2:9
+//	  |
+//	2 | let x = foo(1, 2);
+//	  |         ^^^^^^^^^
+//
+// If the span runs over multiple lines, only the first line is shown. An empty
+// string is returned if the span doesn't fit in the source.
+func (self Span) Excerpt(source string) string {
+	if self.Start < 0 || self.Start > len(source) || self.End < self.Start {
+		return ""
+	}
+
+	lineStart := strings.LastIndex(source[:self.Start], "\n") + 1
+	lineEnd := strings.Index(source[self.Start:], "\n")
+	if lineEnd == -1 {
+		lineEnd = len(source)
+	} else {
+		lineEnd += self.Start
+	}
+	line := strings.TrimRight(source[lineStart:lineEnd], "\r")
+
+	underlineEnd := self.End
+	if underlineEnd > lineStart+len(line) {
+		underlineEnd = lineStart + len(line)
+	}
+	caretCount := utf8.RuneCountInString(source[self.Start:underlineEnd])
+	if caretCount == 0 {
+		caretCount = 1
+	}
+
+	// preserving tabs so that the carets line up with the source
+	var padding strings.Builder
+	for _, ch := range source[lineStart:self.Start] {
+		if ch == '\t' {
+			padding.WriteRune('\t')
+		} else {
+			padding.WriteRune(' ')
+		}
+	}
+
+	lineNumber := fmt.Sprint(self.Line + 1)
+	gutter := strings.Repeat(" ", len(lineNumber))
+
+	var out strings.Builder
+	if self.File != "" {
+		fmt.Fprintf(&out, "%s--> %s\n", gutter, self.Location())
+	}
+	fmt.Fprintf(&out, "%s |\n", gutter)
+	fmt.Fprintf(&out, "%s | %s\n", lineNumber, line)
+	fmt.Fprintf(&out, "%s | %s%s", gutter, padding.String(), strings.Repeat("^", caretCount))
+
+	return out.String()
+}
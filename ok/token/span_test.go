@@ -0,0 +1,78 @@
+package token
+
+import "testing"
+
+func TestSpanExcerpt(t *testing.T) {
+	source := "let x = 1;\n\tlet y = foo(1, 2);\nlet z = \"héllo\" + 1;"
+
+	tests := []struct {
+		span     Span
+		expected string
+	}{
+		{
+			Span{Start: 4, End: 5, Line: 0, Column: 5},
+			"  |\n1 | let x = 1;\n  |     ^",
+		},
+		{
+			Span{File: "main.ok", Start: 20, End: 29, Line: 1, Column: 10},
+			" --> main.ok:2:10\n  |\n2 | \tlet y = foo(1, 2);\n  | \t        ^^^^^^^^^",
+		},
+		{
+			// the underline stops at the end of the line
+			Span{Start: 20, End: 40, Line: 1, Column: 10},
+			"  |\n2 | \tlet y = foo(1, 2);\n  | \t        ^^^^^^^^^^",
+		},
+		{
+			// multi-byte characters only get one caret each
+			Span{Start: 39, End: 47, Line: 2, Column: 9},
+			"  |\n3 | let z = \"héllo\" + 1;\n  |         ^^^^^^^",
+		},
+		{
+			Span{Start: 100, End: 101},
+			"",
+		},
+	}
+
+	for i, tt := range tests {
+		actual := tt.span.Excerpt(source)
+		if actual != tt.expected {
+			t.Errorf("tests[%d] - expected:\n%s\ngot:\n%s", i, tt.expected, actual)
+		}
+	}
+}
+
+func TestSpanTo(t *testing.T) {
+	start := Span{Start: 4, End: 5, Line: 0, Column: 5}
+	end := Span{Start: 10, End: 12, Line: 1, Column: 2}
+
+	actual := start.To(end)
+	expected := Span{Start: 4, End: 12, Line: 0, Column: 5}
+	if actual != expected {
+		t.Errorf("expected %+v, got %+v", expected, actual)
+	}
+
+	if start.To(Span{}) != start {
+		t.Errorf("expected a zero span to leave the span as is")
+	}
+}
+
+func TestSpanContains(t *testing.T) {
+	outer := Span{File: "a.ok", Start: 4, End: 20}
+
+	tests := []struct {
+		inner    Span
+		expected bool
+	}{
+		{Span{File: "a.ok", Start: 4, End: 20}, true},
+		{Span{File: "a.ok", Start: 10, End: 12}, true},
+		{Span{File: "a.ok", Start: 2, End: 12}, false},
+		{Span{File: "a.ok", Start: 10, End: 21}, false},
+		{Span{File: "b.ok", Start: 10, End: 12}, false},
+	}
+
+	for _, tt := range tests {
+		if outer.Contains(tt.inner) != tt.expected {
+			t.Errorf("expected %+v.Contains(%+v) to be %t", outer, tt.inner, tt.expected)
+		}
+	}
+}
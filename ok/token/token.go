@@ -9,19 +9,42 @@ type Token struct {
 	Literal string
 	Line    int
 	Column  int
+
+	// byte offsets into the source: Offset is where the token starts and
+	// EndOffset is just past where it ends
+	Offset    int
+	EndOffset int
+	// empty if the source didn't come from a file
+	File string
 }
 
 func (self Token) Location() string {
 	return fmt.Sprintf("line %d, column %d", self.Line+1, self.Column)
 }
 
+func (self Token) Span() Span {
+	return Span{
+		File:   self.File,
+		Start:  self.Offset,
+		End:    self.EndOffset,
+		Line:   self.Line,
+		Column: self.Column,
+	}
+}
+
 const (
 	ILLEGAL = "ILLEGAL"
 	EOF     = "EOF"
 	// Identifiers + literals
 	IDENT  = "IDENT" // add, foobar, x, y, ...
 	INT    = "INT"
+	FLOAT  = "FLOAT"
 	STRING = "STRING"
+	// an interpolated string like "a ${b} c ${d} e" is lexed as
+	// INTERP_START("a "), b, INTERP_MID(" c "), d, INTERP_END(" e")
+	INTERP_START = "INTERP_START"
+	INTERP_MID   = "INTERP_MID"
+	INTERP_END   = "INTERP_END"
 	// Operators
 	ASSIGN   = "="
 	PLUS     = "+"
@@ -29,11 +52,14 @@ const (
 	BANG     = "!"
 	ASTERISK = "*"
 	SLASH    = "/"
+	PERCENT  = "%"
 	AND      = "&&"
 	OR       = "||"
 	GTEQ     = ">="
 
-	COMMENT = "//"
+	COMMENT       = "//"
+	DOC_COMMENT   = "///"
+	BLOCK_COMMENT = "/*"
 
 	// Delimiters
 	COMMA     = ","
@@ -58,14 +84,24 @@ const (
 	DEFAULT  = "DEFAULT"
 	NULL     = "NO!"
 	LAZY     = "LAZY"
+	IMPORT   = "IMPORT"
 
 	// structs
 	STRUCT = "STRUCT"
-	PACK   = "PACK"
-	FIELD  = "FIELD"
-	PUBLIC = "PUBLIC"
-	NEW    = "NEW"
-	PERIOD = "PERIOD"
+	// 'notaninterface'
+	INTERFACE  = "INTERFACE"
+	IMPLEMENTS = "IMPLEMENTS"
+	PACK       = "PACK"
+	CARRY      = "CARRY"
+	FIELD      = "FIELD"
+	PUBLIC     = "PUBLIC"
+	NEW        = "NEW"
+	PERIOD     = "PERIOD"
+
+	// for ranges in switch cases, e.g. 'case 1..5:'
+	DOTDOT = ".."
+	// for rest parameters, e.g. 'fn(first, ...rest)'
+	ELLIPSIS = "..."
 )
 
 var keywords = map[string]TokenType{
@@ -82,10 +118,15 @@ var keywords = map[string]TokenType{
 	"default":   DEFAULT,
 	"notaclass": STRUCT,
 	"pack":      PACK,
+	"carry":     CARRY,
 	"field":     FIELD,
 	"public":    PUBLIC,
 	"new":       NEW,
 	"lazy":      LAZY,
+	"import":    IMPORT,
+
+	"notaninterface": INTERFACE,
+	"implements":     IMPLEMENTS,
 }
 
 func LookupIdent(ident string) TokenType {